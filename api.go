@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/antlinker/flow/schema"
 	"github.com/teambition/gear"
@@ -11,7 +12,8 @@ import (
 
 // API 提供API管理
 type API struct {
-	engine *Engine
+	engine   *Engine
+	operator func(ctx *gear.Context) string // 获取当前操作人
 }
 
 // Init 初始化
@@ -45,8 +47,20 @@ func (a *API) pageSize(ctx *gear.Context) uint {
 func (a *API) QueryFlowPage(ctx *gear.Context) error {
 	pageIndex, pageSize := a.pageIndex(ctx), a.pageSize(ctx)
 	params := schema.FlowQueryParam{
-		Code: ctx.Query("code"),
-		Name: ctx.Query("name"),
+		Code:       ctx.Query("code"),
+		Name:       ctx.Query("name"),
+		TypeCode:   ctx.Query("type_code"),
+		Properties: make(map[string]string),
+	}
+	if v, err := strconv.Atoi(ctx.Query("status")); err == nil {
+		params.Status = v
+	}
+
+	// 按流程扩展属性过滤(prop.属性名称=属性值)
+	for key, values := range ctx.Req.URL.Query() {
+		if name := strings.TrimPrefix(key, "prop."); name != key && len(values) > 0 {
+			params.Properties[name] = values[0]
+		}
 	}
 
 	total, items, err := a.engine.flowBll.QueryAllFlowPage(params, pageIndex, pageSize)
@@ -68,7 +82,7 @@ func (a *API) QueryFlowPage(ctx *gear.Context) error {
 
 // GetFlow 获取流程数据
 func (a *API) GetFlow(ctx *gear.Context) error {
-	item, err := a.engine.flowBll.GetFlow(ctx.Param("id"))
+	item, err := a.engine.GetFlow(ctx.Param("id"))
 	if err != nil {
 		return gear.ErrInternalServerError.From(err)
 	}
@@ -76,7 +90,8 @@ func (a *API) GetFlow(ctx *gear.Context) error {
 }
 
 type saveFlowRequest struct {
-	XML string `json:"xml"`
+	XML    string `json:"xml"`    // 流程定义数据
+	Format string `json:"format"` // 数据格式(bpmn/json，为空时自动识别)
 }
 
 func (a *saveFlowRequest) Validate() error {
@@ -93,13 +108,87 @@ func (a *API) SaveFlow(ctx *gear.Context) error {
 		return gear.ErrBadRequest.From(err)
 	}
 
-	_, err := a.engine.CreateFlow([]byte(req.XML))
+	_, err := a.engine.CreateFlowWithFormat([]byte(req.XML), Format(req.Format))
 	if err != nil {
 		return gear.ErrInternalServerError.From(err)
 	}
 	return ctx.JSON(http.StatusOK, "ok")
 }
 
+// ExportFlow 导出流程定义数据
+func (a *API) ExportFlow(ctx *gear.Context) error {
+	format := Format(ctx.Query("format"))
+	data, err := a.engine.ExportFlow(ctx.Param("id"), format)
+	if err != nil {
+		if err == ErrNotFound {
+			return gear.ErrNotFound.From(err)
+		}
+		return gear.ErrInternalServerError.From(err)
+	}
+
+	contentType := gear.MIMEApplicationXMLCharsetUTF8
+	if format == FormatJSON {
+		contentType = gear.MIMEApplicationJSONCharsetUTF8
+	}
+	ctx.SetHeader(gear.HeaderContentType, contentType)
+	return ctx.End(http.StatusOK, data)
+}
+
+type moveNodeRequest struct {
+	NodeCode   string   `json:"node_code"`  // 目标节点编号
+	Candidates []string `json:"candidates"` // 目标节点候选人(为空时重新计算)
+	Reason     string   `json:"reason"`     // 操作原因
+}
+
+func (a *moveNodeRequest) Validate() error {
+	if a.NodeCode == "" {
+		return errors.New("目标节点不能为空")
+	}
+	return nil
+}
+
+// 获取当前操作人(由ServerOperatorOption设定的方法从请求上下文中获取)
+func (a *API) getOperator(ctx *gear.Context) string {
+	if a.operator == nil {
+		return ""
+	}
+	return a.operator(ctx)
+}
+
+// MoveToNode 将流程实例移动到指定节点(目标节点必须是人工任务，操作人从请求上下文中获取)
+func (a *API) MoveToNode(ctx *gear.Context) error {
+	operator := a.getOperator(ctx)
+	if operator == "" {
+		return gear.ErrUnauthorized.From(errors.New("未获取到当前操作人"))
+	}
+
+	var req moveNodeRequest
+	if err := ctx.ParseBody(&req); err != nil {
+		return gear.ErrBadRequest.From(err)
+	}
+
+	next, err := a.engine.MoveToNode(ctx, ctx.Param("id"), req.NodeCode, req.Candidates, req.Reason, operator)
+	if err != nil {
+		if err == ErrNotFound {
+			return gear.ErrNotFound.From(err)
+		}
+		return gear.ErrInternalServerError.From(err)
+	}
+	return ctx.JSON(http.StatusOK, next)
+}
+
+// ExplainStep 查询节点实例的流转说明
+func (a *API) ExplainStep(ctx *gear.Context) error {
+	item, err := a.engine.ExplainStep(ctx.Param("id"))
+	if err != nil {
+		if err == ErrNotFound {
+			return gear.ErrNotFound.From(err)
+		}
+		return gear.ErrInternalServerError.From(err)
+	}
+	return ctx.JSON(http.StatusOK, item)
+}
+
 // DeleteFlow 删除流程数据
 func (a *API) DeleteFlow(ctx *gear.Context) error {
 	err := a.engine.flowBll.DeleteFlow(ctx.Param("id"))
@@ -0,0 +1,132 @@
+package bll
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/antlinker/flow/model"
+	"github.com/antlinker/flow/register"
+	"github.com/antlinker/flow/service/db"
+)
+
+// 测试用的数据库驱动：记录执行的语句，执行及查询的结果由测试设定
+type recordDB struct {
+	mu    sync.Mutex
+	execs []string
+	args  [][]driver.Value
+	exec  func(query string, args []driver.Value) (int64, error)
+	query func(query string, args []driver.Value) ([]string, [][]driver.Value)
+}
+
+var (
+	recordLock sync.Mutex
+	recordDBs  = make(map[string]*recordDB)
+)
+
+func init() {
+	sql.Register("recordbll", recordDriver{})
+}
+
+// 创建使用测试数据库的流程管理
+func newRecordFlow(t *testing.T, rdb *recordDB) *Flow {
+	recordLock.Lock()
+	name := fmt.Sprintf("%s_%d", t.Name(), len(recordDBs))
+	recordDBs[name] = rdb
+	recordLock.Unlock()
+
+	sqlDB, err := sql.Open("recordbll", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := db.NewWithDB(sqlDB, db.MySQL, false)
+	register.FlowDBMap(m)
+	return &Flow{FlowModel: &model.Flow{DB: m}}
+}
+
+// 按插入语句中的字段名称获取插入的值
+func insertValues(query string, args []driver.Value) map[string]driver.Value {
+	start, end := strings.Index(query, "("), strings.Index(query, ")")
+	values := make(map[string]driver.Value)
+	for i, col := range strings.Split(query[start+1:end], ",") {
+		values[strings.Trim(strings.TrimSpace(col), "`\"")] = args[i]
+	}
+	return values
+}
+
+type recordDriver struct{}
+
+func (recordDriver) Open(name string) (driver.Conn, error) {
+	recordLock.Lock()
+	defer recordLock.Unlock()
+	return recordConn{recordDBs[name]}, nil
+}
+
+type recordConn struct {
+	db *recordDB
+}
+
+func (c recordConn) Prepare(query string) (driver.Stmt, error) {
+	return recordStmt{db: c.db, query: query}, nil
+}
+func (recordConn) Close() error              { return nil }
+func (recordConn) Begin() (driver.Tx, error) { return recordConn{}, nil }
+func (recordConn) Commit() error             { return nil }
+func (recordConn) Rollback() error           { return nil }
+
+type recordStmt struct {
+	db    *recordDB
+	query string
+}
+
+func (recordStmt) Close() error  { return nil }
+func (recordStmt) NumInput() int { return -1 }
+
+func (s recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.execs = append(s.db.execs, s.query)
+	s.db.args = append(s.db.args, args)
+
+	if s.db.exec == nil {
+		return driver.RowsAffected(1), nil
+	}
+	n, err := s.db.exec(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (s recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.db.query == nil {
+		return &recordRows{}, nil
+	}
+	cols, rows := s.db.query(s.query, args)
+	return &recordRows{cols: cols, rows: rows}, nil
+}
+
+type recordRows struct {
+	cols []string
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *recordRows) Columns() []string { return r.cols }
+func (r *recordRows) Close() error      { return nil }
+
+func (r *recordRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
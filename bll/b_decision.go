@@ -0,0 +1,34 @@
+package bll
+
+import (
+	"time"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/antlinker/flow/util"
+)
+
+// DeployDecision 部署决策表(版本号在最近部署的版本号基础上递增)
+func (a *Flow) DeployDecision(item *schema.Decision) error {
+	latest, err := a.FlowModel.GetLatestDecision(item.Code)
+	if err != nil {
+		return err
+	}
+
+	item.Version = 1
+	if latest != nil {
+		item.Version = latest.Version + 1
+	}
+	item.RecordID = util.UUID()
+	item.Created = time.Now().Unix()
+	return a.FlowModel.CreateDecision(item)
+}
+
+// GetLatestDecisionVersion 获取决策表编号最近部署的版本号
+func (a *Flow) GetLatestDecisionVersion(code string) (int64, error) {
+	return a.FlowModel.GetLatestDecisionVersion(code)
+}
+
+// GetLatestDecision 获取决策表编号最近的部署记录
+func (a *Flow) GetLatestDecision(code string) (*schema.Decision, error) {
+	return a.FlowModel.GetLatestDecision(code)
+}
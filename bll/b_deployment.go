@@ -0,0 +1,49 @@
+package bll
+
+import (
+	"fmt"
+
+	"github.com/antlinker/flow/schema"
+)
+
+// DeployFlow 部署流程
+func (a *Flow) DeployFlow(flow *schema.Flow, nodes *schema.NodeOperating, forms *schema.FormOperating, deployment *schema.FlowDeployment) error {
+	if flow.Flag == 0 {
+		flow.Flag = 1
+	}
+	return a.FlowModel.DeployFlow(flow, nodes, forms, deployment)
+}
+
+// ActivateFlowVersion 启用流程版本
+func (a *Flow) ActivateFlowVersion(code string, version int64) error {
+	flow, err := a.FlowModel.GetFlowByCodeAndVersion(code, version)
+	if err != nil {
+		return err
+	} else if flow == nil {
+		return fmt.Errorf("流程(%s)的版本(%d)不存在", code, version)
+	} else if flow.Flag != 1 || flow.Status != 1 {
+		return fmt.Errorf("流程(%s)的版本(%d)不可用", code, version)
+	}
+
+	return a.FlowModel.ActivateFlow(code, flow.RecordID)
+}
+
+// GetFlowByCodeAndVersion 根据流程编号及版本号获取流程数据
+func (a *Flow) GetFlowByCodeAndVersion(code string, version int64) (*schema.Flow, error) {
+	return a.FlowModel.GetFlowByCodeAndVersion(code, version)
+}
+
+// GetMaxFlowVersion 获取流程编号已部署的最大版本号
+func (a *Flow) GetMaxFlowVersion(code string) (int64, error) {
+	return a.FlowModel.GetMaxFlowVersion(code)
+}
+
+// QueryDeployments 查询流程部署记录
+func (a *Flow) QueryDeployments(code string) ([]*schema.FlowDeploymentResult, error) {
+	return a.FlowModel.QueryDeployments(code)
+}
+
+// GetLatestDeployment 获取流程编号最近的部署记录
+func (a *Flow) GetLatestDeployment(code string) (*schema.FlowDeployment, error) {
+	return a.FlowModel.GetLatestDeployment(code)
+}
@@ -13,7 +13,11 @@ import (
 // Flow 流程管理
 type Flow struct {
 	sync.RWMutex
-	FlowModel *model.Flow `inject:""`
+	FlowModel        *model.Flow `inject:""`
+	payloadStore     PayloadStore
+	payloadThreshold int
+	requestTimeout   time.Duration
+	requestIndex     int32
 }
 
 // GetFlow 获取流程数据
@@ -21,7 +25,7 @@ func (a *Flow) GetFlow(recordID string) (*schema.Flow, error) {
 	return a.FlowModel.GetFlow(recordID)
 }
 
-// GetFlowByCode 根据编号查询流程数据
+// GetFlowByCode 根据编号查询启用版本的流程数据
 func (a *Flow) GetFlowByCode(code string) (*schema.Flow, error) {
 	return a.FlowModel.GetFlowByCode(code)
 }
@@ -31,11 +35,14 @@ func (a *Flow) QueryFlowByCode(flowCode string) ([]*schema.Flow, error) {
 	return a.FlowModel.QueryFlowByCode(flowCode)
 }
 
-// CreateFlow 创建流程数据
+// CreateFlow 创建流程数据(主流程创建后即为启用的版本)
 func (a *Flow) CreateFlow(flow *schema.Flow, nodes *schema.NodeOperating, forms *schema.FormOperating) error {
 	if flow.Flag == 0 {
 		flow.Flag = 1
 	}
+	if flow.Flag == 1 {
+		flow.Activated = 1
+	}
 	return a.FlowModel.CreateFlow(flow, nodes, forms)
 }
 
@@ -44,6 +51,11 @@ func (a *Flow) GetNode(recordID string) (*schema.Node, error) {
 	return a.FlowModel.GetNode(recordID)
 }
 
+// GetNodeByCode 根据节点编号获取流程节点
+func (a *Flow) GetNodeByCode(flowID, nodeCode string) (*schema.Node, error) {
+	return a.FlowModel.GetNodeByCode(flowID, nodeCode)
+}
+
 // GetFlowInstance 获取流程实例
 func (a *Flow) GetFlowInstance(recordID string) (*schema.FlowInstance, error) {
 	return a.FlowModel.GetFlowInstance(recordID)
@@ -56,7 +68,16 @@ func (a *Flow) GetFlowInstanceByNode(nodeInstanceID string) (*schema.FlowInstanc
 
 // GetNodeInstance 获取流程节点实例
 func (a *Flow) GetNodeInstance(recordID string) (*schema.NodeInstance, error) {
-	return a.FlowModel.GetNodeInstance(recordID)
+	nodeInstance, err := a.FlowModel.GetNodeInstance(recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.loadPayload(nodeInstance)
+	if err != nil {
+		return nil, err
+	}
+	return nodeInstance, nil
 }
 
 // QueryNodeRouters 查询节点路由
@@ -80,6 +101,11 @@ func (a *Flow) CreateNodeInstance(flowInstanceID, nodeID string, inputData []byt
 		Created:        time.Now().Unix(),
 	}
 
+	err := a.storePayload(nodeInstance)
+	if err != nil {
+		return "", err
+	}
+
 	var nodeCandidates []*schema.NodeCandidate
 	for _, c := range candidates {
 		nodeCandidates = append(nodeCandidates, &schema.NodeCandidate{
@@ -90,8 +116,9 @@ func (a *Flow) CreateNodeInstance(flowInstanceID, nodeID string, inputData []byt
 		})
 	}
 
-	err := a.FlowModel.CreateNodeInstance(nodeInstance, nodeCandidates)
+	err = a.FlowModel.CreateNodeInstance(nodeInstance, nodeCandidates)
 	if err != nil {
+		a.discardPayload(nodeInstance)
 		return "", err
 	}
 
@@ -107,7 +134,8 @@ func (a *Flow) DoneNodeInstance(nodeInstanceID, processor string, outData []byte
 	nodeInstance, err := a.FlowModel.GetNodeInstance(nodeInstanceID)
 	if err != nil {
 		return err
-	} else if nodeInstance == nil || nodeInstance.Status == 2 {
+	} else if nodeInstance == nil || nodeInstance.Status != 1 {
+		// 已完成或已取消(例如流程实例被移动到其他节点)的节点实例不能再处理
 		return fmt.Errorf("无效的处理节点")
 	}
 
@@ -118,7 +146,15 @@ func (a *Flow) DoneNodeInstance(nodeInstanceID, processor string, outData []byte
 		"status":       2,
 		"updated":      time.Now().Unix(),
 	}
-	return a.FlowModel.UpdateNodeInstance(nodeInstanceID, info)
+
+	// 按待处理状态更新，避免与其他进程中的移动或处理操作交错
+	ok, err := a.FlowModel.DoneNodeInstance(nodeInstanceID, info)
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("无效的处理节点")
+	}
+	return nil
 }
 
 // CheckFlowInstanceTodo 检查流程实例待办事项
@@ -169,8 +205,14 @@ func (a *Flow) LaunchFlowInstance2(flowID, userID string, status int, inputData
 		Created:        flowInstance.Created,
 	}
 
+	err = a.storePayload(nodeInstance)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	err = a.FlowModel.CreateFlowInstance(flowInstance, nodeInstance)
 	if err != nil {
+		a.discardPayload(nodeInstance)
 		return nil, nil, err
 	}
 
@@ -211,8 +253,14 @@ func (a *Flow) LaunchFlowInstance(flowCode, nodeCode, launcher string, inputData
 		Created:        flowInstance.Created,
 	}
 
+	err = a.storePayload(nodeInstance)
+	if err != nil {
+		return nil, err
+	}
+
 	err = a.FlowModel.CreateFlowInstance(flowInstance, nodeInstance)
 	if err != nil {
+		a.discardPayload(nodeInstance)
 		return nil, err
 	}
 
@@ -231,12 +279,30 @@ func (a *Flow) CheckNodeCandidate(nodeInstanceID, userID string) (bool, error) {
 
 // QueryTodo 查询用户的待办节点实例数据
 func (a *Flow) QueryTodo(typeCode, flowCode, userID string, count int) ([]*schema.FlowTodoResult, error) {
-	return a.FlowModel.QueryTodo(typeCode, flowCode, userID, count)
+	items, err := a.FlowModel.QueryTodo(typeCode, flowCode, userID, count)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.loadTodoPayloads(items...)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 // GetTodoByID 根据ID获取待办
 func (a *Flow) GetTodoByID(nodeInstanceID string) (*schema.FlowTodoResult, error) {
-	return a.FlowModel.GetTodoByID(nodeInstanceID)
+	item, err := a.FlowModel.GetTodoByID(nodeInstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.loadTodoPayloads(item)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
 }
 
 // GetDoneByID 根据ID获取已办
@@ -266,7 +332,16 @@ func (a *Flow) DeleteFlow(flowID string) error {
 
 // QueryHistory 查询流程实例历史数据
 func (a *Flow) QueryHistory(flowInstanceID string) ([]*schema.FlowHistoryResult, error) {
-	return a.FlowModel.QueryHistory(flowInstanceID)
+	items, err := a.FlowModel.QueryHistory(flowInstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.loadHistoryPayloads(items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 // QueryDoneIDs 查询已办理的流程实例ID列表
@@ -350,6 +425,21 @@ func (a *Flow) GetNodeProperty(nodeID string) (map[string]string, error) {
 	return data, nil
 }
 
+// QueryFlowProperties 查询流程扩展属性
+func (a *Flow) QueryFlowProperties(flowID string) ([]*schema.FlowProperty, error) {
+	return a.FlowModel.QueryFlowProperties(flowID)
+}
+
+// QueryNodesByFlowID 查询流程的所有节点
+func (a *Flow) QueryNodesByFlowID(flowID string) ([]*schema.Node, error) {
+	return a.FlowModel.QueryNodesByFlowID(flowID)
+}
+
+// QueryNodeProperty 查询节点属性
+func (a *Flow) QueryNodeProperty(nodeID string) ([]*schema.NodeProperty, error) {
+	return a.FlowModel.QueryNodeProperty(nodeID)
+}
+
 // CreateNodeTiming 创建定时节点
 func (a *Flow) CreateNodeTiming(item *schema.NodeTiming) error {
 	item.ID = 0
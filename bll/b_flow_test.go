@@ -0,0 +1,30 @@
+package bll
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antlinker/flow/schema"
+)
+
+func TestCreateFlowActivated(t *testing.T) {
+	rdb := &recordDB{}
+	a := newRecordFlow(t, rdb)
+
+	flow := &schema.Flow{RecordID: "f2", Code: "leave", Version: 2}
+	err := a.CreateFlow(flow, &schema.NodeOperating{}, &schema.FormOperating{})
+	if err != nil {
+		t.Fatal(err)
+	} else if flow.Flag != 1 || flow.Activated != 1 {
+		t.Errorf("flow = %+v, want activated main flow", flow)
+	}
+
+	// 先停用同一流程编号的其它版本，再插入流程
+	if len(rdb.execs) != 2 ||
+		!strings.HasPrefix(rdb.execs[0], "UPDATE "+schema.FlowTableName+" SET activated=0") ||
+		!strings.HasPrefix(rdb.execs[1], "insert") {
+		t.Errorf("execs = %v", rdb.execs)
+	} else if rdb.args[0][1] != "leave" {
+		t.Errorf("deactivate args = %v", rdb.args[0])
+	}
+}
@@ -0,0 +1,90 @@
+package bll
+
+import (
+	"time"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/antlinker/flow/util"
+)
+
+// QueryRunningFlowInstances 查询流程下未结束的流程实例
+func (a *Flow) QueryRunningFlowInstances(flowID string) ([]*schema.FlowInstance, error) {
+	return a.FlowModel.QueryRunningFlowInstances(flowID)
+}
+
+// QueryTodoNodeInstances 查询流程实例中待处理的节点实例
+func (a *Flow) QueryTodoNodeInstances(flowInstanceID string) ([]*schema.NodeInstance, error) {
+	return a.FlowModel.QueryTodoNodeInstances(flowInstanceID)
+}
+
+// QueryDoneNodeInstances 查询流程实例中已完成的节点实例(按完成顺序)
+func (a *Flow) QueryDoneNodeInstances(flowInstanceID string) ([]*schema.FlowHistoryResult, error) {
+	items, err := a.FlowModel.QueryDoneNodeInstances(flowInstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.loadHistoryPayloads(items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// MigrateFlowInstance 迁移流程实例
+// migrate 根据锁定后的待处理节点实例返回节点实例内码与目标节点内码的映射及操作日志
+func (a *Flow) MigrateFlowInstance(flowInstanceID, fromFlowID, toFlowID string, migrate func([]*schema.NodeInstance) (map[string]string, *schema.InstanceLog, error)) error {
+	// 与节点实例的处理过程互斥
+	a.Lock()
+	defer a.Unlock()
+
+	return a.FlowModel.MigrateFlowInstance(flowInstanceID, fromFlowID, toFlowID, migrate)
+}
+
+// QueryInstanceLogs 查询流程实例操作日志
+func (a *Flow) QueryInstanceLogs(flowInstanceID string) ([]*schema.InstanceLog, error) {
+	return a.FlowModel.QueryInstanceLogs(flowInstanceID)
+}
+
+// CreateExpressionTrace 创建表达式执行记录
+func (a *Flow) CreateExpressionTrace(item *schema.ExpressionTrace) error {
+	item.RecordID = util.UUID()
+	item.Created = time.Now().Unix()
+	return a.FlowModel.CreateExpressionTrace(item)
+}
+
+// QueryExpressionTraces 查询节点实例流转时的表达式执行记录
+func (a *Flow) QueryExpressionTraces(nodeInstanceID string) ([]*schema.ExpressionTrace, error) {
+	return a.FlowModel.QueryExpressionTraces(nodeInstanceID)
+}
+
+// MoveFlowInstance 将流程实例移动到指定节点
+func (a *Flow) MoveFlowInstance(flowInstanceID, flowID, nodeID string, candidates []string, log *schema.InstanceLog) (*schema.NodeInstance, error) {
+	// 与节点实例的处理过程互斥
+	a.Lock()
+	defer a.Unlock()
+
+	nodeInstance := &schema.NodeInstance{
+		RecordID:       util.UUID(),
+		FlowInstanceID: flowInstanceID,
+		NodeID:         nodeID,
+		Status:         1,
+		Created:        log.Created,
+	}
+
+	var nodeCandidates []*schema.NodeCandidate
+	for _, c := range candidates {
+		nodeCandidates = append(nodeCandidates, &schema.NodeCandidate{
+			RecordID:       util.UUID(),
+			NodeInstanceID: nodeInstance.RecordID,
+			CandidateID:    c,
+			Created:        nodeInstance.Created,
+		})
+	}
+
+	err := a.FlowModel.MoveFlowInstance(flowID, nodeInstance, nodeCandidates, log)
+	if err != nil {
+		return nil, err
+	}
+	return nodeInstance, nil
+}
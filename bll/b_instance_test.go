@@ -0,0 +1,190 @@
+package bll
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/antlinker/flow/schema"
+)
+
+// 模拟流程实例(flow_id:f1 status:1)及其待处理的节点实例(n1)
+func migrateDB(flowID string, affected int64) *recordDB {
+	return &recordDB{
+		exec: func(query string, args []driver.Value) (int64, error) {
+			if strings.Contains(query, schema.NodeInstanceTableName) {
+				return affected, nil
+			}
+			return 1, nil
+		},
+		query: func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+			if strings.Contains(query, schema.NodeInstanceTableName) {
+				return []string{"id", "record_id", "flow_instance_id", "node_id", "status"},
+					[][]driver.Value{{int64(1), "n1", "i1", "a", int64(1)}}
+			}
+			return []string{"id", "record_id", "flow_id", "status"},
+				[][]driver.Value{{int64(1), "i1", flowID, int64(1)}}
+		},
+	}
+}
+
+func migrateNodes(nodeInstances []*schema.NodeInstance) (map[string]string, *schema.InstanceLog, error) {
+	nodes := make(map[string]string)
+	for _, ni := range nodeInstances {
+		nodes[ni.RecordID] = ni.NodeID + "2"
+	}
+	return nodes, &schema.InstanceLog{RecordID: "l1", FlowInstanceID: "i1", Action: "migrate", Created: 1}, nil
+}
+
+func countExecs(rdb *recordDB, prefix string) int {
+	var n int
+	for _, query := range rdb.execs {
+		if strings.HasPrefix(query, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMigrateFlowInstance(t *testing.T) {
+	rdb := migrateDB("f1", 1)
+	a := newRecordFlow(t, rdb)
+
+	var locked []*schema.NodeInstance
+	err := a.MigrateFlowInstance("i1", "f1", "f2", func(nodeInstances []*schema.NodeInstance) (map[string]string, *schema.InstanceLog, error) {
+		locked = nodeInstances
+		return migrateNodes(nodeInstances)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(locked) != 1 || locked[0].RecordID != "n1" {
+		t.Errorf("locked node instances = %v", locked)
+	}
+	if n := countExecs(rdb, "UPDATE"); n != 2 {
+		t.Errorf("updates = %d, want 2", n)
+	}
+	if rdb.args[1][0] != "a2" || rdb.args[1][2] != "n1" {
+		t.Errorf("node update args = %v", rdb.args[1])
+	}
+	if n := countExecs(rdb, "insert"); n != 1 {
+		t.Errorf("inserts = %d, want 1", n)
+	}
+}
+
+func TestMigrateFlowInstanceRejected(t *testing.T) {
+	// 流程实例已迁移到其他流程
+	rdb := migrateDB("f3", 1)
+	a := newRecordFlow(t, rdb)
+	err := a.MigrateFlowInstance("i1", "f1", "f2", migrateNodes)
+	if err == nil || !strings.Contains(err.Error(), "已迁移") {
+		t.Errorf("moved instance: err = %v", err)
+	} else if len(rdb.execs) != 0 {
+		t.Errorf("execs = %v, want none", rdb.execs)
+	}
+
+	// 节点映射失败时不更新
+	rdb = migrateDB("f1", 1)
+	a = newRecordFlow(t, rdb)
+	err = a.MigrateFlowInstance("i1", "f1", "f2", func([]*schema.NodeInstance) (map[string]string, *schema.InstanceLog, error) {
+		return nil, nil, errors.New("no target node")
+	})
+	if err == nil || err.Error() != "no target node" {
+		t.Errorf("mapping: err = %v", err)
+	} else if len(rdb.execs) != 0 {
+		t.Errorf("execs = %v, want none", rdb.execs)
+	}
+
+	// 节点实例未更新时不记录日志
+	rdb = migrateDB("f1", 0)
+	a = newRecordFlow(t, rdb)
+	err = a.MigrateFlowInstance("i1", "f1", "f2", migrateNodes)
+	if err == nil || !strings.Contains(err.Error(), "已处理") {
+		t.Errorf("node done: err = %v", err)
+	} else if n := countExecs(rdb, "insert"); n != 0 {
+		t.Errorf("inserts = %d, want 0", n)
+	}
+}
+
+func TestDoneNodeInstance(t *testing.T) {
+	for _, status := range []int64{1, 2, 3} {
+		rdb := &recordDB{
+			query: func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+				return []string{"id", "record_id", "status"}, [][]driver.Value{{int64(1), "n1", status}}
+			},
+		}
+		a := newRecordFlow(t, rdb)
+
+		err := a.DoneNodeInstance("n1", "u1", nil)
+		if status == 1 {
+			if err != nil {
+				t.Errorf("status %d: %v", status, err)
+			} else if len(rdb.execs) != 1 {
+				t.Errorf("status %d: execs = %v", status, rdb.execs)
+			}
+			continue
+		}
+
+		// 已完成或已取消的节点实例不能再处理
+		if err == nil {
+			t.Errorf("status %d: want error", status)
+		} else if len(rdb.execs) != 0 {
+			t.Errorf("status %d: execs = %v, want none", status, rdb.execs)
+		}
+	}
+
+	// 读取后节点实例被其他进程处理或取消
+	rdb := &recordDB{
+		exec: func(query string, args []driver.Value) (int64, error) {
+			return 0, nil
+		},
+		query: func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+			return []string{"id", "record_id", "status"}, [][]driver.Value{{int64(1), "n1", int64(1)}}
+		},
+	}
+	a := newRecordFlow(t, rdb)
+	if err := a.DoneNodeInstance("n1", "u1", nil); err == nil {
+		t.Error("changed node instance: want error")
+	} else if len(rdb.execs) != 1 || !strings.Contains(rdb.execs[0][strings.Index(rdb.execs[0], "WHERE"):], "status=?") {
+		t.Errorf("execs = %v", rdb.execs)
+	}
+}
+
+func TestMoveFlowInstance(t *testing.T) {
+	rdb := migrateDB("f1", 1)
+	a := newRecordFlow(t, rdb)
+
+	log := &schema.InstanceLog{RecordID: "l1", FlowInstanceID: "i1", Action: "move", Created: 1}
+	nodeInstance, err := a.MoveFlowInstance("i1", "f1", "b", []string{"u1"}, log)
+	if err != nil {
+		t.Fatal(err)
+	} else if nodeInstance.NodeID != "b" || nodeInstance.Status != 1 {
+		t.Errorf("node instance = %+v", nodeInstance)
+	}
+
+	// 先删除待处理节点实例的定时任务，再取消节点实例
+	if len(rdb.execs) < 2 ||
+		!strings.HasPrefix(rdb.execs[0], "UPDATE "+schema.NodeTimingTableName) ||
+		!strings.HasPrefix(rdb.execs[1], "UPDATE "+schema.NodeInstanceTableName+" SET status=3") {
+		t.Errorf("execs = %v", rdb.execs)
+	}
+	if n := countExecs(rdb, "insert"); n != 3 {
+		t.Errorf("inserts = %d, want 3", n)
+	}
+}
+
+func TestMoveFlowInstanceRejected(t *testing.T) {
+	// 流程实例已迁移到其他流程时不能移动到原流程的节点
+	rdb := migrateDB("f2", 1)
+	a := newRecordFlow(t, rdb)
+
+	log := &schema.InstanceLog{RecordID: "l1", FlowInstanceID: "i1", Action: "move", Created: 1}
+	_, err := a.MoveFlowInstance("i1", "f1", "b", []string{"u1"}, log)
+	if err == nil || !strings.Contains(err.Error(), "已迁移") {
+		t.Errorf("moved instance: err = %v", err)
+	} else if len(rdb.execs) != 0 {
+		t.Errorf("execs = %v, want none", rdb.execs)
+	}
+}
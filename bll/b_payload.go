@@ -0,0 +1,136 @@
+package bll
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/pkg/errors"
+)
+
+// PayloadStore 外部数据存储(用于存放较大的节点实例输入数据)
+type PayloadStore interface {
+	// 存储数据，返回数据引用
+	Put(key string, data []byte) (string, error)
+	// 根据数据引用获取数据
+	Get(ref string) ([]byte, error)
+	// 删除数据(数据不存在时不返回错误)
+	Delete(ref string) error
+}
+
+// NewFilePayloadStore 创建基于文件目录的外部数据存储
+func NewFilePayloadStore(dir string) PayloadStore {
+	return &filePayloadStore{dir: dir}
+}
+
+type filePayloadStore struct {
+	dir string
+}
+
+func (s *filePayloadStore) Put(key string, data []byte) (string, error) {
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return "", errors.Wrapf(err, "创建数据存储目录发生错误")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(s.dir, key), data, 0644)
+	if err != nil {
+		return "", errors.Wrapf(err, "写入数据文件发生错误")
+	}
+	return key, nil
+}
+
+func (s *filePayloadStore) Get(ref string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, filepath.Base(ref)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "读取数据文件发生错误")
+	}
+	return data, nil
+}
+
+func (s *filePayloadStore) Delete(ref string) error {
+	err := os.Remove(filepath.Join(s.dir, filepath.Base(ref)))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "删除数据文件发生错误")
+	}
+	return nil
+}
+
+// SetPayloadStore 设定外部数据存储，输入数据超过threshold字节时将转存到外部存储
+// 获取节点实例、待办及流程历史时按需加载转存的输入数据，但转存的输入数据不再参与按输入数据字段的检索
+func (a *Flow) SetPayloadStore(store PayloadStore, threshold int) {
+	a.payloadStore = store
+	a.payloadThreshold = threshold
+}
+
+// 将超过阈值的输入数据转存到外部存储
+func (a *Flow) storePayload(nodeInstance *schema.NodeInstance) error {
+	if a.payloadStore == nil || len(nodeInstance.InputData) <= a.payloadThreshold {
+		return nil
+	}
+
+	ref, err := a.payloadStore.Put(nodeInstance.RecordID, []byte(nodeInstance.InputData))
+	if err != nil {
+		return err
+	}
+	nodeInstance.InputRef = ref
+	nodeInstance.InputData = ""
+	return nil
+}
+
+// 删除已转存的输入数据(节点实例写入失败时调用，避免遗留无效的数据)
+func (a *Flow) discardPayload(nodeInstance *schema.NodeInstance) {
+	if a.payloadStore == nil || nodeInstance.InputRef == "" {
+		return
+	}
+	_ = a.payloadStore.Delete(nodeInstance.InputRef)
+}
+
+// 从外部存储加载输入数据
+func (a *Flow) loadPayload(nodeInstance *schema.NodeInstance) error {
+	if nodeInstance == nil {
+		return nil
+	}
+	return a.loadPayloadData(nodeInstance.RecordID, nodeInstance.InputRef, &nodeInstance.InputData)
+}
+
+// 加载待办数据中转存的输入数据
+func (a *Flow) loadTodoPayloads(items ...*schema.FlowTodoResult) error {
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		err := a.loadPayloadData(item.RecordID, item.InputRef, &item.InputData)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 加载流程历史数据中转存的输入数据
+func (a *Flow) loadHistoryPayloads(items []*schema.FlowHistoryResult) error {
+	for _, item := range items {
+		err := a.loadPayloadData(item.RecordID, item.InputRef, &item.InputData)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Flow) loadPayloadData(nodeInstanceID, ref string, data *string) error {
+	if ref == "" || *data != "" {
+		return nil
+	} else if a.payloadStore == nil {
+		return errors.Errorf("未设定外部数据存储，无法加载节点实例(%s)的输入数据", nodeInstanceID)
+	}
+
+	buf, err := a.payloadStore.Get(ref)
+	if err != nil {
+		return err
+	}
+	*data = string(buf)
+	return nil
+}
@@ -0,0 +1,111 @@
+package bll
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/antlinker/flow/schema"
+)
+
+func newPayloadDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFilePayloadStore(t *testing.T) {
+	dir := newPayloadDir(t)
+	defer os.RemoveAll(dir)
+
+	store := NewFilePayloadStore(dir)
+	ref, err := store.Put("a", []byte(`{"day":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := store.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(data) != `{"day":1}` {
+		t.Errorf("Get = %s", data)
+	}
+
+	if err = store.Delete(ref); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Delete(ref); err != nil {
+		t.Errorf("Delete missing payload: %v", err)
+	}
+	if _, err = store.Get(ref); err == nil {
+		t.Error("Get deleted payload: want error")
+	}
+}
+
+func TestCreateNodeInstanceDiscardPayload(t *testing.T) {
+	dir := newPayloadDir(t)
+	defer os.RemoveAll(dir)
+
+	a := newRecordFlow(t, &recordDB{
+		exec: func(query string, args []driver.Value) (int64, error) {
+			return 0, errors.New("insert failed")
+		},
+	})
+	a.SetPayloadStore(NewFilePayloadStore(dir), 4)
+
+	_, err := a.CreateNodeInstance("flow_instance", "node", []byte(`{"day":1}`), []string{"u1"})
+	if err == nil {
+		t.Fatal("CreateNodeInstance: want error")
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) != 0 {
+		t.Errorf("payload files = %d, want 0", len(files))
+	}
+}
+
+func TestLoadPayloads(t *testing.T) {
+	dir := newPayloadDir(t)
+	defer os.RemoveAll(dir)
+
+	a := &Flow{}
+	a.SetPayloadStore(NewFilePayloadStore(dir), 4)
+
+	nodeInstance := &schema.NodeInstance{RecordID: "n1", InputData: `{"day":1}`}
+	if err := a.storePayload(nodeInstance); err != nil {
+		t.Fatal(err)
+	} else if nodeInstance.InputData != "" || nodeInstance.InputRef == "" {
+		t.Fatalf("payload not stored: %+v", nodeInstance)
+	}
+
+	todo := &schema.FlowTodoResult{RecordID: "n1", InputRef: nodeInstance.InputRef}
+	if err := a.loadTodoPayloads(todo, nil); err != nil {
+		t.Fatal(err)
+	} else if todo.InputData != `{"day":1}` {
+		t.Errorf("todo input = %s", todo.InputData)
+	}
+
+	history := []*schema.FlowHistoryResult{
+		{RecordID: "n1", InputRef: nodeInstance.InputRef},
+		{RecordID: "n2", InputData: `{"day":2}`},
+	}
+	if err := a.loadHistoryPayloads(history); err != nil {
+		t.Fatal(err)
+	} else if history[0].InputData != `{"day":1}` || history[1].InputData != `{"day":2}` {
+		t.Errorf("history input = %s, %s", history[0].InputData, history[1].InputData)
+	}
+
+	a.SetPayloadStore(nil, 0)
+	if err := a.loadHistoryPayloads(history[:1]); err != nil {
+		t.Errorf("loaded payload should not be reloaded: %v", err)
+	}
+	if err := a.loadTodoPayloads(&schema.FlowTodoResult{RecordID: "n1", InputRef: "n1"}); err == nil {
+		t.Error("load payload without store: want error")
+	}
+}
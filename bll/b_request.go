@@ -0,0 +1,106 @@
+package bll
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/antlinker/flow/schema"
+)
+
+// DefaultRequestTimeout 幂等请求的默认处理超时时间
+const DefaultRequestTimeout = 5 * time.Minute
+
+// SetRequestTimeout 设定幂等请求的处理超时时间
+// 超过超时时间仍处于处理中的请求(例如处理过程中进程退出或保存处理结果失败)视为处理失败，允许使用相同的幂等键重试
+func (a *Flow) SetRequestTimeout(timeout time.Duration) {
+	a.requestTimeout = timeout
+}
+
+// 检查幂等键的唯一索引(幂等键的占用依赖唯一索引，检查通过后不再重复检查)
+func (a *Flow) checkRequestIndex() error {
+	if atomic.LoadInt32(&a.requestIndex) == 1 {
+		return nil
+	}
+
+	exists, err := a.FlowModel.RequestIndexExists()
+	if err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("幂等请求表(%s)缺少request_key唯一索引，请执行数据库迁移", schema.RequestTableName)
+	}
+
+	atomic.StoreInt32(&a.requestIndex, 1)
+	return nil
+}
+
+// ReserveRequest 占用幂等键
+// 如果幂等键已被占用，则返回已存在的请求(ok为false)；处理超时的请求重新占用(ok为true)
+func (a *Flow) ReserveRequest(requestKey, action, userID string) (*schema.IdempotentRequest, bool, error) {
+	err := a.checkRequestIndex()
+	if err != nil {
+		return nil, false, err
+	}
+
+	item := &schema.IdempotentRequest{
+		RequestKey: requestKey,
+		Action:     action,
+		UserID:     userID,
+		Status:     1,
+		Created:    time.Now().Unix(),
+	}
+
+	err = a.FlowModel.CreateIdempotentRequest(item)
+	if err == nil {
+		return item, true, nil
+	}
+
+	// 插入失败时检查是否由唯一索引冲突导致
+	exists, verr := a.FlowModel.GetIdempotentRequest(requestKey)
+	if verr != nil {
+		return nil, false, verr
+	} else if exists == nil {
+		return nil, false, err
+	}
+
+	if exists.Action != action || exists.UserID != userID {
+		return nil, false, fmt.Errorf("幂等键已被其他请求使用")
+	} else if exists.Status == 2 {
+		return exists, false, nil
+	}
+
+	timeout := a.requestTimeout
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	if time.Since(time.Unix(exists.Created, 0)) < timeout {
+		return nil, false, fmt.Errorf("请求正在处理中")
+	}
+
+	// 重新占用超时的请求(同时重试的请求只有一个可以占用成功)
+	ok, err := a.FlowModel.ReclaimIdempotentRequest(requestKey, exists.Created, item.Created)
+	if err != nil {
+		return nil, false, err
+	} else if !ok {
+		return nil, false, fmt.Errorf("请求正在处理中")
+	}
+	exists.Created = item.Created
+	return exists, true, nil
+}
+
+// DoneRequest 完成幂等请求，保存处理结果
+func (a *Flow) DoneRequest(requestKey, flowInstanceID, nodeInstanceID string, result []byte) error {
+	info := map[string]interface{}{
+		"flow_instance_id": flowInstanceID,
+		"node_instance_id": nodeInstanceID,
+		"result":           string(result),
+		"status":           2,
+		"updated":          time.Now().Unix(),
+	}
+	return a.FlowModel.UpdateIdempotentRequest(requestKey, info)
+}
+
+// ReleaseRequest 释放幂等键(请求处理失败时调用，允许客户端使用相同的幂等键重试)
+func (a *Flow) ReleaseRequest(requestKey string) error {
+	return a.FlowModel.DeleteIdempotentRequest(requestKey)
+}
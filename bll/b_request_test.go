@@ -0,0 +1,116 @@
+package bll
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var requestCols = []string{"id", "request_key", "action", "user_id", "flow_instance_id", "node_instance_id", "result", "status", "created", "updated"}
+
+// 模拟幂等请求表(request_key唯一)
+type requestTable struct {
+	index bool
+	row   []driver.Value
+}
+
+func (r *requestTable) recordDB() *recordDB {
+	return &recordDB{
+		exec: func(query string, args []driver.Value) (int64, error) {
+			switch {
+			case strings.HasPrefix(query, "insert"):
+				if r.row != nil {
+					return 0, errors.New("duplicate entry")
+				}
+				values := insertValues(query, args)
+				r.row = make([]driver.Value, len(requestCols))
+				for i, col := range requestCols {
+					r.row[i] = values[col]
+				}
+				r.row[0] = int64(1)
+			case strings.HasPrefix(query, "UPDATE"):
+				if r.row == nil || r.row[7] != int64(1) || r.row[8] != args[3] {
+					return 0, nil
+				}
+				r.row[8], r.row[9] = args[0], args[1]
+			}
+			return 1, nil
+		},
+		query: func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+			if strings.Contains(query, "information_schema") {
+				var n int64
+				if r.index {
+					n = 1
+				}
+				return []string{"count"}, [][]driver.Value{{n}}
+			}
+
+			if r.row == nil {
+				return requestCols, nil
+			}
+			return requestCols, [][]driver.Value{r.row}
+		},
+	}
+}
+
+func TestReserveRequest(t *testing.T) {
+	table := &requestTable{}
+	a := newRecordFlow(t, table.recordDB())
+
+	_, _, err := a.ReserveRequest("k1", "handle", "u1")
+	if err == nil || !strings.Contains(err.Error(), "唯一索引") {
+		t.Fatalf("missing index: err = %v", err)
+	}
+
+	table.index = true
+	_, reserved, err := a.ReserveRequest("k1", "handle", "u1")
+	if err != nil || !reserved {
+		t.Fatalf("reserve: reserved = %v, err = %v", reserved, err)
+	}
+
+	_, _, err = a.ReserveRequest("k1", "handle", "u2")
+	if err == nil || !strings.Contains(err.Error(), "其他请求") {
+		t.Errorf("other user: err = %v", err)
+	}
+
+	_, _, err = a.ReserveRequest("k1", "handle", "u1")
+	if err == nil || !strings.Contains(err.Error(), "正在处理中") {
+		t.Errorf("in progress: err = %v", err)
+	}
+
+	// 处理超时的请求可以重新占用
+	table.row[8] = time.Now().Add(-DefaultRequestTimeout - time.Minute).Unix()
+	_, reserved, err = a.ReserveRequest("k1", "handle", "u1")
+	if err != nil || !reserved {
+		t.Fatalf("reclaim: reserved = %v, err = %v", reserved, err)
+	}
+	_, _, err = a.ReserveRequest("k1", "handle", "u1")
+	if err == nil || !strings.Contains(err.Error(), "正在处理中") {
+		t.Errorf("reclaimed request in progress: err = %v", err)
+	}
+
+	table.row[6], table.row[7] = `{"ok":true}`, int64(2)
+	item, reserved, err := a.ReserveRequest("k1", "handle", "u1")
+	if err != nil || reserved || item.Result != `{"ok":true}` {
+		t.Errorf("done: item = %+v, reserved = %v, err = %v", item, reserved, err)
+	}
+}
+
+func TestReserveRequestTimeout(t *testing.T) {
+	table := &requestTable{index: true}
+	a := newRecordFlow(t, table.recordDB())
+	a.SetRequestTimeout(time.Hour)
+
+	_, _, err := a.ReserveRequest("k1", "launch", "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table.row[8] = time.Now().Add(-DefaultRequestTimeout - time.Minute).Unix()
+	_, _, err = a.ReserveRequest("k1", "launch", "u1")
+	if err == nil || !strings.Contains(err.Error(), "正在处理中") {
+		t.Errorf("err = %v, want in progress", err)
+	}
+}
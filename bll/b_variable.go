@@ -0,0 +1,158 @@
+package bll
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/antlinker/flow/util"
+)
+
+// 定义变量类型
+const (
+	varTypeNull   = "null"
+	varTypeString = "string"
+	varTypeNumber = "number"
+	varTypeBool   = "bool"
+	varTypeJSON   = "json"
+)
+
+// 将变量值编码为类型及字符串值
+func encodeVariable(v interface{}) (string, string, error) {
+	switch vv := v.(type) {
+	case nil:
+		return varTypeNull, "", nil
+	case string:
+		return varTypeString, vv, nil
+	case bool:
+		return varTypeBool, strconv.FormatBool(vv), nil
+	case float64:
+		return varTypeNumber, strconv.FormatFloat(vv, 'f', -1, 64), nil
+	case float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return varTypeNumber, fmt.Sprint(vv), nil
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", "", err
+	}
+	return varTypeJSON, string(buf), nil
+}
+
+// 根据变量类型解码变量值
+func decodeVariable(typeCode, value string) interface{} {
+	switch typeCode {
+	case varTypeNull:
+		return nil
+	case varTypeBool:
+		b, _ := strconv.ParseBool(value)
+		return b
+	case varTypeNumber:
+		f, _ := strconv.ParseFloat(value, 64)
+		return f
+	case varTypeJSON:
+		var v interface{}
+		_ = json.Unmarshal([]byte(value), &v)
+		return v
+	}
+	return value
+}
+
+// GetVariables 获取流程实例变量
+func (a *Flow) GetVariables(flowInstanceID string) (map[string]interface{}, error) {
+	items, err := a.FlowModel.QueryFlowVariables(flowInstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	for _, item := range items {
+		data[item.Name] = decodeVariable(item.TypeCode, item.Value)
+	}
+	return data, nil
+}
+
+// SetVariables 设定流程实例变量，仅保存发生变化的变量并记录变更历史
+// 变量按流程实例及变量名称唯一(多个引擎进程同时设定同一变量时以最后保存的值为准)
+// nodeInstanceID 产生变更的节点实例内码(通过接口直接修改时为空)
+// operator 操作人
+func (a *Flow) SetVariables(flowInstanceID, nodeInstanceID, operator string, vars map[string]interface{}) error {
+	a.Lock()
+	defer a.Unlock()
+
+	items, err := a.FlowModel.QueryFlowVariables(flowInstanceID)
+	if err != nil {
+		return err
+	}
+
+	exists := make(map[string]*schema.FlowVariable)
+	for _, item := range items {
+		exists[item.Name] = item
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		changes   []*schema.FlowVariable
+		histories []*schema.VariableHistory
+		now       = time.Now().Unix()
+	)
+
+	for _, name := range names {
+		typeCode, value, err := encodeVariable(vars[name])
+		if err != nil {
+			return fmt.Errorf("无效的流程变量(%s):%s", name, err.Error())
+		}
+
+		var oldValue string
+		if item, ok := exists[name]; ok {
+			if item.TypeCode == typeCode && item.Value == value {
+				continue
+			}
+			oldValue = item.Value
+			item.TypeCode = typeCode
+			item.Value = value
+			item.Updated = now
+			changes = append(changes, item)
+		} else {
+			changes = append(changes, &schema.FlowVariable{
+				RecordID:       util.UUID(),
+				FlowInstanceID: flowInstanceID,
+				Name:           name,
+				TypeCode:       typeCode,
+				Value:          value,
+				Created:        now,
+				Updated:        now,
+			})
+		}
+
+		histories = append(histories, &schema.VariableHistory{
+			RecordID:       util.UUID(),
+			FlowInstanceID: flowInstanceID,
+			NodeInstanceID: nodeInstanceID,
+			Name:           name,
+			TypeCode:       typeCode,
+			OldValue:       oldValue,
+			Value:          value,
+			Operator:       operator,
+			Created:        now,
+		})
+	}
+
+	if len(histories) == 0 {
+		return nil
+	}
+	return a.FlowModel.SaveFlowVariables(changes, histories)
+}
+
+// QueryVariableHistories 查询流程实例变量的变更历史
+func (a *Flow) QueryVariableHistories(flowInstanceID string) ([]*schema.VariableHistory, error) {
+	return a.FlowModel.QueryVariableHistories(flowInstanceID)
+}
@@ -0,0 +1,45 @@
+package bll
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestSetVariables(t *testing.T) {
+	rdb := &recordDB{
+		query: func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+			return []string{"id", "record_id", "flow_instance_id", "name", "type_code", "value", "created", "updated", "deleted"}, [][]driver.Value{
+				{int64(1), "v1", "fi", "day", "number", "3", int64(1), int64(1), int64(0)},
+				{int64(2), "v2", "fi", "leader", "bool", "true", int64(1), int64(1), int64(0)},
+			}
+		},
+	}
+	a := newRecordFlow(t, rdb)
+
+	err := a.SetVariables("fi", "ni", "u1", map[string]interface{}{
+		"day":    5,
+		"leader": true,
+		"title":  "请假",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var upserts []string
+	for i, query := range rdb.execs {
+		if !strings.HasPrefix(query, "INSERT INTO f_flow_variable(") {
+			continue
+		}
+		if !strings.Contains(query, "ON DUPLICATE KEY UPDATE type_code=VALUES(type_code),value=VALUES(value),updated=VALUES(updated)") {
+			t.Errorf("variable is not upserted: %s", query)
+		}
+		// 按字段名称排序：created,deleted,flow_instance_id,name,record_id,type_code,updated,value
+		upserts = append(upserts, rdb.args[i][3].(string)+"="+rdb.args[i][7].(string))
+	}
+
+	// 未变化的变量不保存
+	if strings.Join(upserts, ",") != "day=5,title=请假" {
+		t.Errorf("upserts = %v, want [day=5 title=请假]", upserts)
+	}
+}
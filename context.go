@@ -7,8 +7,10 @@ import (
 )
 
 type (
-	expKey  struct{}
-	flagKey struct{}
+	expKey         struct{}
+	flagKey        struct{}
+	idempotencyKey struct{}
+	orgKey         struct{}
 )
 
 // NewExpContext 创建表达式的上下文值
@@ -32,3 +34,26 @@ func FromFlagContext(ctx context.Context) (string, bool) {
 	flag, ok := ctx.Value(flagKey{}).(string)
 	return flag, ok
 }
+
+// NewIdempotencyKeyContext 创建幂等键的上下文值
+// 使用相同幂等键重复发起的StartFlow/LaunchFlow/HandleFlow请求将直接返回首次处理的结果
+func NewIdempotencyKeyContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// FromIdempotencyKeyContext 获取幂等键的上下文
+func FromIdempotencyKeyContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKey{}).(string)
+	return key, ok && key != ""
+}
+
+// NewOrgContext 创建组织机构数据提供者的上下文值
+func NewOrgContext(ctx context.Context, provider OrgProvider) context.Context {
+	return context.WithValue(ctx, orgKey{}, provider)
+}
+
+// FromOrgContext 获取组织机构数据提供者的上下文
+func FromOrgContext(ctx context.Context) (OrgProvider, bool) {
+	provider, ok := ctx.Value(orgKey{}).(OrgProvider)
+	return provider, ok && provider != nil
+}
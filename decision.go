@@ -0,0 +1,191 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/antlinker/flow/dmn"
+	"github.com/antlinker/flow/schema"
+	"github.com/pkg/errors"
+)
+
+// 业务规则任务的节点属性
+const (
+	DecisionRefProperty    = "decisionRef"    // 决策表编号
+	ResultVariableProperty = "resultVariable" // 决策结果写入的流程实例变量(未设定时与决策表编号相同)
+)
+
+// 获取属性值
+func propertyValue(properties []*PropertyResult, name string) string {
+	for _, p := range properties {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// 业务规则任务的结果变量
+func resultVariable(decisionRef, variable string) string {
+	if variable == "" {
+		return decisionRef
+	}
+	return variable
+}
+
+type decisionKey struct{}
+
+// 根据决策表编号获取已部署的决策表
+type decisionGetter func(code string) (*dmn.Decision, error)
+
+func newDecisionContext(ctx context.Context, get decisionGetter) context.Context {
+	return context.WithValue(ctx, decisionKey{}, get)
+}
+
+func fromDecisionContext(ctx context.Context) (decisionGetter, bool) {
+	get, ok := ctx.Value(decisionKey{}).(decisionGetter)
+	return get, ok
+}
+
+// 执行决策表(输入数据不是map时按JSON转换)
+func decide(ctx context.Context, code string, input interface{}) (interface{}, error) {
+	get, ok := fromDecisionContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("执行决策表(%s)失败：未设定决策表提供者", code)
+	}
+
+	d, err := get(code)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := input.(map[string]interface{})
+	if !ok && input != nil {
+		b, err := json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(b, &m)
+		if err != nil {
+			return nil, fmt.Errorf("决策表(%s)的输入数据不是对象", code)
+		}
+	}
+	return d.Evaluate(m)
+}
+
+// 将决策表内置函数decide(code, input)加入表达式变量，执行出错时panic(由表达式执行器转换为错误)
+func withDecisionFuncs(ctx context.Context, vars map[string]interface{}) map[string]interface{} {
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	vars["decide"] = func(code string, input interface{}) interface{} {
+		out, err := decide(ctx, code, input)
+		if err != nil {
+			panic(errors.Wrapf(err, "调用decide发生错误"))
+		}
+		return out
+	}
+	return vars
+}
+
+// 将内置函数(组织机构、决策表)加入表达式变量
+func withFuncs(ctx context.Context, vars map[string]interface{}) map[string]interface{} {
+	return withDecisionFuncs(ctx, withOrgFuncs(ctx, vars))
+}
+
+// DeployDecisions 部署DMN文件中的所有决策表，文件内容与决策表最近的部署相同时不重新部署
+// 部署后新执行的decide函数及业务规则任务使用最新的版本
+func (e *Engine) DeployDecisions(data []byte, opts DeployOptions) ([]*schema.Decision, error) {
+	e.deployLock.Lock()
+	defer e.deployLock.Unlock()
+
+	return e.deployDecisions(data, opts)
+}
+
+func (e *Engine) deployDecisions(data []byte, opts DeployOptions) ([]*schema.Decision, error) {
+	decisions, err := dmn.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := checksum(data)
+	items := make([]*schema.Decision, len(decisions))
+	for i, d := range decisions {
+		latest, err := e.flowBll.GetLatestDecision(d.ID)
+		if err != nil {
+			return nil, err
+		} else if latest != nil && latest.Checksum == sum {
+			items[i] = latest
+			continue
+		}
+
+		item := &schema.Decision{
+			Code:     d.ID,
+			Name:     d.Name,
+			Data:     string(data),
+			Checksum: sum,
+			Comment:  opts.Comment,
+			Author:   opts.Author,
+		}
+		err = e.flowBll.DeployDecision(item)
+		if err != nil {
+			return nil, err
+		}
+		e.decisions.Store(d.ID, &cachedDecision{version: item.Version, decision: d})
+		items[i] = item
+	}
+	return items, nil
+}
+
+// 缓存的决策表及其版本号
+type cachedDecision struct {
+	version  int64
+	decision *dmn.Decision
+}
+
+// 获取决策表最近部署的版本(按决策表编号及版本号缓存)
+// 每次获取时检查最近部署的版本号，其他引擎进程部署新版本后使用新的版本
+func (e *Engine) getDecision(code string) (*dmn.Decision, error) {
+	version, err := e.flowBll.GetLatestDecisionVersion(code)
+	if err != nil {
+		return nil, err
+	} else if version == 0 {
+		return nil, fmt.Errorf("决策表(%s)未部署", code)
+	}
+
+	if v, ok := e.decisions.Load(code); ok {
+		if c := v.(*cachedDecision); c.version == version {
+			return c.decision, nil
+		}
+	}
+
+	item, err := e.flowBll.GetLatestDecision(code)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, fmt.Errorf("决策表(%s)未部署", code)
+	}
+
+	decisions, err := dmn.Parse([]byte(item.Data))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range decisions {
+		if d.ID == code {
+			e.decisions.Store(code, &cachedDecision{version: item.Version, decision: d})
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("决策表(%s)未部署", code)
+}
+
+// Decide 执行已部署的决策表
+// 单个输出列时返回输出值，多个输出列时返回以输出名称为键的map，COLLECT命中策略返回列表或聚合值
+func (e *Engine) Decide(code string, input map[string]interface{}) (interface{}, error) {
+	d, err := e.getDecision(code)
+	if err != nil {
+		return nil, err
+	}
+	return d.Evaluate(input)
+}
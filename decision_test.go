@@ -0,0 +1,105 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/antlinker/flow/dmn"
+)
+
+func testDecisionContext(t *testing.T) context.Context {
+	data, err := ioutil.ReadFile("test_data/approval_level.dmn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decisions, err := dmn.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newDecisionContext(context.Background(), func(code string) (*dmn.Decision, error) {
+		for _, d := range decisions {
+			if d.ID == code {
+				return d, nil
+			}
+		}
+		return nil, fmt.Errorf("决策表(%s)未部署", code)
+	})
+}
+
+func TestDecide(t *testing.T) {
+	ctx := testDecisionContext(t)
+
+	out, err := decide(ctx, "approvalLevel", map[string]interface{}{"amount": 8000, "dept": "hr", "type": "office"})
+	if err != nil {
+		t.Fatal(err)
+	} else if out != 2.0 {
+		t.Errorf("level = %v, want 2", out)
+	}
+
+	// 非map类型的输入按JSON转换
+	out, err = decide(ctx, "approvalLevel", struct {
+		Amount int `json:"amount"`
+	}{60000})
+	if err != nil {
+		t.Fatal(err)
+	} else if out != 4.0 {
+		t.Errorf("level = %v, want 4", out)
+	}
+
+	if _, err = decide(ctx, "unknown", nil); err == nil {
+		t.Error("expected error for unknown decision")
+	}
+	if _, err = decide(context.Background(), "approvalLevel", nil); err == nil {
+		t.Error("expected error without decision provider")
+	}
+
+	fn := withDecisionFuncs(ctx, nil)["decide"].(func(string, interface{}) interface{})
+	roles, ok := fn("approvers", map[string]interface{}{"amount": 20000}).([]interface{})
+	if !ok || len(roles) != 2 || roles[0] != "manager" || roles[1] != "finance" {
+		t.Errorf("roles = %v", roles)
+	}
+}
+
+const decisionFlow = `{
+  "id": "decision",
+  "nodes": [
+    {"id": "start", "type": "startEvent", "routers": [{"target": "rule"}]},
+    {"id": "rule", "type": "businessRuleTask", "properties": [
+      {"name": "decisionRef", "value": "approvalLevel"},
+      {"name": "resultVariable", "value": "level"}
+    ], "routers": [{"target": "end"}]},
+    {"id": "end", "type": "endEvent"}
+  ]
+}`
+
+func TestSimulateBusinessRuleTask(t *testing.T) {
+	result, err := NewJSONParser().Parse(context.Background(), []byte(decisionFlow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := newSimulator(simulateExecer{}, result).run(testDecisionContext(t), []byte(`{"amount":3000}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := simulatePath(out); path != "start:done,rule:done,end:done" {
+		t.Errorf("path = %s", path)
+	}
+	if !out.Ended {
+		t.Error("expected flow to end")
+	}
+	if level := out.Vars["level"]; level != 1.0 {
+		t.Errorf("level = %v, want 1", level)
+	}
+
+	out, err = newSimulator(simulateExecer{}, result).run(context.Background(), []byte(`{"amount":3000}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Errors) != 1 || out.Errors[0].NodeID != "rule" {
+		t.Errorf("errors = %v", out.Errors)
+	}
+}
@@ -0,0 +1,217 @@
+package dmn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// HitPolicy 决策表的命中策略
+type HitPolicy string
+
+// 支持的命中策略
+const (
+	Unique  HitPolicy = "UNIQUE"  // 最多只能匹配一条规则(默认)
+	First   HitPolicy = "FIRST"   // 按规则顺序返回第一条匹配的规则
+	Collect HitPolicy = "COLLECT" // 返回所有匹配的规则(可以使用SUM、MIN、MAX、COUNT聚合)
+)
+
+// Decision 决策
+type Decision struct {
+	ID    string         // 决策ID(决策表编号)
+	Name  string         // 决策名称
+	Table *DecisionTable // 决策表
+}
+
+// DecisionTable 决策表
+type DecisionTable struct {
+	HitPolicy   HitPolicy // 命中策略
+	Aggregation string    // COLLECT的聚合方式(SUM、MIN、MAX、COUNT，为空时返回列表)
+	Inputs      []*Input  // 输入列
+	Outputs     []*Output // 输出列
+	Rules       []*Rule   // 规则
+}
+
+// Input 决策表的输入列
+type Input struct {
+	ID         string // 输入ID
+	Label      string // 输入标签
+	Expression string // 输入表达式(输入数据中的字段路径，例如amount、applicant.dept)
+}
+
+// Output 决策表的输出列
+type Output struct {
+	ID    string // 输出ID
+	Name  string // 输出名称(多个输出列时作为结果的键)
+	Label string // 输出标签
+}
+
+// Rule 决策表的规则
+type Rule struct {
+	ID            string        // 规则ID
+	InputEntries  []string      // 输入条件(FEEL一元测试)
+	OutputEntries []string      // 输出值(FEEL字面量)
+	tests         []unaryTests  // 解析后的输入条件
+	outputs       []interface{} // 解析后的输出值
+}
+
+// Parse 解析DMN 1.x文件中的所有决策表
+func Parse(data []byte) ([]*Decision, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("无效的DMN文件：%s", err.Error())
+	}
+
+	root := doc.Root()
+	if root == nil || root.Tag != "definitions" {
+		return nil, fmt.Errorf("无效的DMN文件：未找到definitions元素")
+	}
+
+	var decisions []*Decision
+	for _, element := range root.SelectElements("decision") {
+		d, err := parseDecision(element)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			decisions = append(decisions, d)
+		}
+	}
+
+	if len(decisions) == 0 {
+		return nil, fmt.Errorf("无效的DMN文件：未找到决策表")
+	}
+	return decisions, nil
+}
+
+// 解析决策(没有决策表的决策返回nil)
+func parseDecision(element *etree.Element) (*Decision, error) {
+	d := &Decision{
+		ID:   element.SelectAttrValue("id", ""),
+		Name: element.SelectAttrValue("name", ""),
+	}
+	if d.ID == "" {
+		return nil, fmt.Errorf("决策缺少id属性")
+	}
+
+	table := element.SelectElement("decisionTable")
+	if table == nil {
+		return nil, nil
+	}
+
+	t, err := parseTable(table)
+	if err != nil {
+		return nil, fmt.Errorf("决策表(%s)：%s", d.ID, err.Error())
+	}
+	d.Table = t
+	return d, nil
+}
+
+func parseTable(element *etree.Element) (*DecisionTable, error) {
+	t := &DecisionTable{
+		HitPolicy:   HitPolicy(strings.ToUpper(element.SelectAttrValue("hitPolicy", string(Unique)))),
+		Aggregation: strings.ToUpper(element.SelectAttrValue("aggregation", "")),
+	}
+
+	switch t.HitPolicy {
+	case Unique, First:
+		if t.Aggregation != "" {
+			return nil, fmt.Errorf("命中策略%s不支持聚合", t.HitPolicy)
+		}
+	case Collect:
+		switch t.Aggregation {
+		case "", "SUM", "MIN", "MAX", "COUNT":
+		default:
+			return nil, fmt.Errorf("不支持的聚合方式%s", t.Aggregation)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的命中策略%s", t.HitPolicy)
+	}
+
+	for _, e := range element.SelectElements("input") {
+		input := &Input{
+			ID:    e.SelectAttrValue("id", ""),
+			Label: e.SelectAttrValue("label", ""),
+		}
+		if exp := e.SelectElement("inputExpression"); exp != nil {
+			input.Expression = elementText(exp)
+		}
+		if input.Expression == "" {
+			return nil, fmt.Errorf("输入列(%s)缺少输入表达式", input.ID)
+		}
+		t.Inputs = append(t.Inputs, input)
+	}
+
+	for _, e := range element.SelectElements("output") {
+		output := &Output{
+			ID:    e.SelectAttrValue("id", ""),
+			Name:  e.SelectAttrValue("name", ""),
+			Label: e.SelectAttrValue("label", ""),
+		}
+		if output.Name == "" {
+			output.Name = output.Label
+		}
+		if output.Name == "" {
+			output.Name = output.ID
+		}
+		t.Outputs = append(t.Outputs, output)
+	}
+
+	if len(t.Outputs) == 0 {
+		return nil, fmt.Errorf("缺少输出列")
+	} else if t.Aggregation != "" && len(t.Outputs) > 1 {
+		return nil, fmt.Errorf("多个输出列时不支持聚合")
+	}
+
+	for _, e := range element.SelectElements("rule") {
+		rule, err := parseRule(e, len(t.Inputs), len(t.Outputs))
+		if err != nil {
+			return nil, err
+		}
+		t.Rules = append(t.Rules, rule)
+	}
+	return t, nil
+}
+
+func parseRule(element *etree.Element, inputs, outputs int) (*Rule, error) {
+	rule := &Rule{
+		ID: element.SelectAttrValue("id", ""),
+	}
+
+	for _, e := range element.SelectElements("inputEntry") {
+		rule.InputEntries = append(rule.InputEntries, elementText(e))
+	}
+	for _, e := range element.SelectElements("outputEntry") {
+		rule.OutputEntries = append(rule.OutputEntries, elementText(e))
+	}
+
+	if len(rule.InputEntries) != inputs || len(rule.OutputEntries) != outputs {
+		return nil, fmt.Errorf("规则(%s)的条件或输出数量与列数不一致", rule.ID)
+	}
+
+	for _, entry := range rule.InputEntries {
+		tests, err := parseUnaryTests(entry)
+		if err != nil {
+			return nil, fmt.Errorf("规则(%s)的条件(%s)：%s", rule.ID, entry, err.Error())
+		}
+		rule.tests = append(rule.tests, tests)
+	}
+
+	for _, entry := range rule.OutputEntries {
+		v, err := parseLiteral(entry)
+		if err != nil {
+			return nil, fmt.Errorf("规则(%s)的输出(%s)：%s", rule.ID, entry, err.Error())
+		}
+		rule.outputs = append(rule.outputs, v)
+	}
+	return rule, nil
+}
+
+// 获取元素中text子元素的内容
+func elementText(element *etree.Element) string {
+	if text := element.SelectElement("text"); text != nil {
+		return strings.TrimSpace(text.Text())
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package dmn
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func parseFile(t *testing.T) map[string]*Decision {
+	data, err := ioutil.ReadFile("../test_data/approval_level.dmn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decisions, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := make(map[string]*Decision)
+	for _, d := range decisions {
+		m[d.ID] = d
+	}
+	return m
+}
+
+func TestEvaluateFirst(t *testing.T) {
+	d := parseFile(t)["approvalLevel"]
+	if d == nil || d.Table.HitPolicy != First || len(d.Table.Rules) != 4 {
+		t.Fatalf("approvalLevel = %+v", d)
+	}
+
+	tests := []struct {
+		input map[string]interface{}
+		want  interface{}
+	}{
+		{map[string]interface{}{"amount": 1000}, float64(1)},
+		{map[string]interface{}{"amount": 20000.0, "dept": "finance", "type": "purchase"}, float64(2)},
+		{map[string]interface{}{"amount": "20000", "dept": "hr", "type": "travel"}, float64(3)},
+		{map[string]interface{}{"amount": 20000, "dept": "it"}, float64(3)},
+		{map[string]interface{}{"amount": 50000, "dept": "finance"}, float64(4)},
+		{map[string]interface{}{"dept": "finance"}, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := d.Evaluate(tt.input)
+		if err != nil {
+			t.Errorf("Evaluate(%v): %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%v) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateCollect(t *testing.T) {
+	d := parseFile(t)["approvers"]
+
+	got, err := d.Evaluate(map[string]interface{}{"amount": 20000})
+	if err != nil || !reflect.DeepEqual(got, []interface{}{"manager", "finance"}) {
+		t.Errorf("Evaluate = %v, %v", got, err)
+	}
+
+	d.Table.Aggregation = "COUNT"
+	got, err = d.Evaluate(map[string]interface{}{"amount": 100})
+	if err != nil || got != float64(1) {
+		t.Errorf("Evaluate(COUNT) = %v, %v", got, err)
+	}
+}
+
+func TestEvaluateUnique(t *testing.T) {
+	data := []byte(`<definitions xmlns="http://www.omg.org/spec/DMN/20180521/MODEL/">
+  <decision id="discount">
+    <decisionTable>
+      <input><inputExpression><text>customer.level</text></inputExpression></input>
+      <input><inputExpression><text>total</text></inputExpression></input>
+      <output name="rate" /><output name="reason" />
+      <rule id="a"><inputEntry><text>"gold"</text></inputEntry><inputEntry><text>-</text></inputEntry><outputEntry><text>0.2</text></outputEntry><outputEntry><text>"金牌"</text></outputEntry></rule>
+      <rule id="b"><inputEntry><text>-</text></inputEntry><inputEntry><text>]1000..2000]</text></inputEntry><outputEntry><text>0.1</text></outputEntry><outputEntry><text>"满额"</text></outputEntry></rule>
+    </decisionTable>
+  </decision>
+</definitions>`)
+
+	decisions, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := decisions[0]
+
+	got, err := d.Evaluate(map[string]interface{}{"customer": map[string]interface{}{"level": "gold"}, "total": 500})
+	if err != nil || !reflect.DeepEqual(got, map[string]interface{}{"rate": 0.2, "reason": "金牌"}) {
+		t.Errorf("Evaluate = %v, %v", got, err)
+	}
+
+	got, err = d.Evaluate(map[string]interface{}{"total": 1000})
+	if err != nil || got != nil {
+		t.Errorf("Evaluate(1000) = %v, %v, want nil", got, err)
+	}
+
+	_, err = d.Evaluate(map[string]interface{}{"customer": map[string]interface{}{"level": "gold"}, "total": 2000})
+	if err == nil {
+		t.Error("Evaluate: want UNIQUE error")
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []string{
+		`<definitions><decision id="a"><decisionTable hitPolicy="PRIORITY"><output name="x"/></decisionTable></decision></definitions>`,
+		`<definitions><decision id="a"><decisionTable><input><inputExpression><text>x</text></inputExpression></input><output name="x"/>` +
+			`<rule><inputEntry><text>x + 1</text></inputEntry><outputEntry><text>1</text></outputEntry></rule></decisionTable></decision></definitions>`,
+		`<definitions><decision id="a"><decisionTable><output name="x"/><rule><inputEntry><text>1</text></inputEntry><outputEntry><text>1</text></outputEntry></rule></decisionTable></decision></definitions>`,
+		`<definitions></definitions>`,
+	}
+
+	for _, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s): want error", data)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package dmn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Evaluate 使用输入数据执行决策表
+// 单个输出列时返回输出值，多个输出列时返回以输出名称为键的map；没有匹配的规则时返回nil
+// COLLECT返回所有匹配规则的输出列表，设定聚合方式时返回聚合值
+func (d *Decision) Evaluate(input map[string]interface{}) (interface{}, error) {
+	t := d.Table
+
+	values := make([]interface{}, len(t.Inputs))
+	for i, item := range t.Inputs {
+		values[i] = lookup(input, item.Expression)
+	}
+
+	var matched []*Rule
+	for _, rule := range t.Rules {
+		if rule.match(values) {
+			matched = append(matched, rule)
+			if t.HitPolicy == First {
+				break
+			}
+		}
+	}
+
+	if t.HitPolicy != Collect {
+		if len(matched) > 1 {
+			ids := make([]string, len(matched))
+			for i, rule := range matched {
+				ids[i] = rule.ID
+			}
+			return nil, fmt.Errorf("决策表(%s)的命中策略为UNIQUE，但匹配了多条规则(%s)", d.ID, strings.Join(ids, ","))
+		} else if len(matched) == 0 {
+			return nil, nil
+		}
+		return t.result(matched[0]), nil
+	}
+
+	if t.Aggregation == "COUNT" {
+		return float64(len(matched)), nil
+	} else if t.Aggregation == "" {
+		out := make([]interface{}, len(matched))
+		for i, rule := range matched {
+			out[i] = t.result(rule)
+		}
+		return out, nil
+	} else if len(matched) == 0 {
+		return nil, nil
+	}
+
+	var out float64
+	for i, rule := range matched {
+		n, ok := toNumber(rule.outputs[0])
+		if !ok {
+			return nil, fmt.Errorf("决策表(%s)的规则(%s)的输出不是数字，不能使用%s聚合", d.ID, rule.ID, t.Aggregation)
+		}
+
+		switch {
+		case i == 0:
+			out = n
+		case t.Aggregation == "SUM":
+			out += n
+		case t.Aggregation == "MIN" && n < out, t.Aggregation == "MAX" && n > out:
+			out = n
+		}
+	}
+	return out, nil
+}
+
+// 获取规则的输出
+func (t *DecisionTable) result(rule *Rule) interface{} {
+	if len(t.Outputs) == 1 {
+		return rule.outputs[0]
+	}
+
+	out := make(map[string]interface{}, len(t.Outputs))
+	for i, item := range t.Outputs {
+		out[item.Name] = rule.outputs[i]
+	}
+	return out
+}
+
+func (r *Rule) match(values []interface{}) bool {
+	for i, tests := range r.tests {
+		if !tests.match(values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// 按字段路径(以.分隔)获取输入数据中的值
+func lookup(input map[string]interface{}, path string) interface{} {
+	var v interface{} = input
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[strings.TrimSpace(key)]
+	}
+	return v
+}
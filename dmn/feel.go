@@ -0,0 +1,253 @@
+package dmn
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 一元测试(FEEL unary tests的子集)
+// 支持：-(任意值)、字面量("a"、1、true、null)、比较(< 1、>= 1)、区间([1..10]、(1..10]、]1..10[)、
+// 多个测试(逗号分隔，满足任意一个即可)及not(...)
+type unaryTests struct {
+	any   bool
+	not   bool
+	tests []*unaryTest
+}
+
+type unaryTest struct {
+	op       string      // =、<、<=、>、>=、..(区间)
+	value    interface{} // 比较值(float64、string、bool或nil)
+	high     interface{} // 区间的结束值
+	lowOpen  bool        // 区间不包含起始值
+	highOpen bool        // 区间不包含结束值
+}
+
+func parseUnaryTests(s string) (unaryTests, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "-" {
+		return unaryTests{any: true}, nil
+	}
+
+	var ut unaryTests
+	if strings.HasPrefix(s, "not(") && strings.HasSuffix(s, ")") {
+		ut.not = true
+		s = s[4 : len(s)-1]
+	}
+
+	for _, part := range splitList(s) {
+		t, err := parseUnaryTest(strings.TrimSpace(part))
+		if err != nil {
+			return ut, err
+		}
+		ut.tests = append(ut.tests, t)
+	}
+	return ut, nil
+}
+
+func parseUnaryTest(s string) (*unaryTest, error) {
+	for _, op := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(s, op) {
+			v, err := parseLiteral(s[len(op):])
+			if err != nil {
+				return nil, err
+			}
+			return &unaryTest{op: op, value: v}, nil
+		}
+	}
+
+	if len(s) > 1 && strings.ContainsRune("[(]", rune(s[0])) && strings.ContainsRune("])[", rune(s[len(s)-1])) {
+		parts := strings.SplitN(s[1:len(s)-1], "..", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("无效的区间")
+		}
+		low, err := parseLiteral(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		high, err := parseLiteral(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return &unaryTest{
+			op:       "..",
+			value:    low,
+			high:     high,
+			lowOpen:  s[0] != '[',
+			highOpen: s[len(s)-1] != ']',
+		}, nil
+	}
+
+	v, err := parseLiteral(s)
+	if err != nil {
+		return nil, err
+	}
+	return &unaryTest{op: "=", value: v}, nil
+}
+
+// 按逗号拆分(忽略字符串及区间中的逗号)
+func splitList(s string) []string {
+	var (
+		parts []string
+		start int
+		depth int
+		quote bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quote:
+			i++
+		case c == '"':
+			quote = !quote
+		case quote:
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// 解析FEEL字面量(字符串、数字、布尔值及null)
+func parseLiteral(s string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "", "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	if strings.HasPrefix(s, `"`) {
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的字符串")
+		}
+		return v, nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("不支持的表达式")
+	}
+	return v, nil
+}
+
+func (ut unaryTests) match(v interface{}) bool {
+	if ut.any {
+		return true
+	}
+
+	matched := false
+	for _, t := range ut.tests {
+		if t.match(v) {
+			matched = true
+			break
+		}
+	}
+	return matched != ut.not
+}
+
+func (t *unaryTest) match(v interface{}) bool {
+	if t.op == "=" {
+		return equal(v, t.value)
+	}
+
+	c, ok := compare(v, t.value)
+	if !ok {
+		return false
+	}
+
+	switch t.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	if c < 0 || c == 0 && t.lowOpen {
+		return false
+	}
+	c, ok = compare(v, t.high)
+	return ok && (c < 0 || c == 0 && !t.highOpen)
+}
+
+// 转换为数字(数字字符串也作为数字)
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// 比较输入值与测试值，类型不兼容时ok为false
+func compare(v, test interface{}) (int, bool) {
+	switch t := test.(type) {
+	case float64:
+		n, ok := toNumber(v)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case n < t:
+			return -1, true
+		case n > t:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(s, t), true
+	}
+	return 0, false
+}
+
+func equal(v, test interface{}) bool {
+	switch t := test.(type) {
+	case nil:
+		return v == nil
+	case bool:
+		b, ok := v.(bool)
+		return ok && b == t
+	}
+	c, ok := compare(v, test)
+	return ok && c == 0
+}
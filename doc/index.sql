@@ -1,3 +1,4 @@
+-- 该脚本已由引擎内置的数据库迁移(f_schema_version版本1)自动执行，仅供参考
 ALTER TABLE `f_node_instance` ADD UNIQUE INDEX `record_id` (`record_id`);
 ALTER TABLE `f_node_instance` ADD INDEX `flow_instance_id` (`flow_instance_id`);
 ALTER TABLE `f_node_instance` ADD INDEX `node_id` (`node_id`);
@@ -1,3 +1,4 @@
+-- 该脚本已由引擎内置的数据库迁移(f_schema_version版本2)自动执行，仅供参考
 -- 增加流程状态
 ALTER TABLE f_flow ADD status INT DEFAULT 1 NULL;
 ALTER TABLE f_flow
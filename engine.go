@@ -2,6 +2,7 @@ package flow
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -29,28 +30,93 @@ type Logger interface {
 // AutoCallbackHandler 自动执行节点回调处理
 type AutoCallbackHandler func(action, flag, userID string, input []byte, result *HandleResult) error
 
+type engineOptions struct {
+	dialect          db.Dialect
+	autoMigrate      bool
+	payloadStore     bll.PayloadStore
+	payloadThreshold int
+	exprTrace        bool
+	requestTimeout   time.Duration
+}
+
+// EngineOption 流程引擎配置
+type EngineOption func(*engineOptions)
+
+// DialectOption 数据库方言(默认为MySQL)
+func DialectOption(dialect db.Dialect) EngineOption {
+	return func(o *engineOptions) {
+		o.dialect = dialect
+	}
+}
+
+// AutoMigrateOption 初始化时是否自动执行数据库迁移(默认为true)
+func AutoMigrateOption(autoMigrate bool) EngineOption {
+	return func(o *engineOptions) {
+		o.autoMigrate = autoMigrate
+	}
+}
+
+// PayloadStoreOption 节点实例的输入数据超过threshold字节时转存到外部存储
+func PayloadStoreOption(store bll.PayloadStore, threshold int) EngineOption {
+	return func(o *engineOptions) {
+		o.payloadStore = store
+		o.payloadThreshold = threshold
+	}
+}
+
+// ExpressionTraceOption 是否记录节点流转时执行的表达式(默认为false)
+// 记录表达式、表达式数据、执行结果或错误及执行耗时，可以通过ExplainStep查看流转的原因
+func ExpressionTraceOption(enable bool) EngineOption {
+	return func(o *engineOptions) {
+		o.exprTrace = enable
+	}
+}
+
+// IdempotencyTimeoutOption 幂等请求的处理超时时间(默认为5分钟)
+// 超过超时时间仍未完成的请求(例如处理过程中进程退出)允许使用相同的幂等键重试
+func IdempotencyTimeoutOption(timeout time.Duration) EngineOption {
+	return func(o *engineOptions) {
+		o.requestTimeout = timeout
+	}
+}
+
 // Engine 流程引擎
 type Engine struct {
+	db           *db.DB
 	flowBll      *bll.Flow
 	parser       Parser
 	execer       Execer
+	execers      map[string]Execer
+	execerLock   sync.RWMutex
+	flowExecers  sync.Map
 	logger       Logger
 	timingStart  bool
 	timingTicker *time.Ticker
 	timingWg     *sync.WaitGroup
 	getDBContext func(flag string) context.Context
 	autoCallback AutoCallbackHandler
+	orgProvider  OrgProvider
+	exprTrace    bool
+	decisions    sync.Map
+	deployLock   sync.Mutex // 串行化部署过程(避免并发部署计算出相同的版本号)
 }
 
 // Init 初始化流程引擎
-func (e *Engine) Init(parser Parser, execer Execer, sqlDB *sql.DB, trace bool) (*Engine, error) {
+func (e *Engine) Init(parser Parser, execer Execer, sqlDB *sql.DB, trace bool, opts ...EngineOption) (*Engine, error) {
+	o := engineOptions{
+		dialect:     db.MySQL,
+		autoMigrate: true,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	var (
 		g       inject.Graph
 		flowBll bll.Flow
 	)
 
-	db := db.NewMySQLWithDB(sqlDB, trace)
+	db := db.NewWithDB(sqlDB, o.dialect, trace)
 	err := g.Provide(&inject.Object{Value: db},
 		&inject.Object{Value: &flowBll})
 	if err != nil {
@@ -68,12 +134,35 @@ func (e *Engine) Init(parser Parser, execer Execer, sqlDB *sql.DB, trace bool) (
 		return e, err
 	}
 
+	if o.payloadStore != nil {
+		flowBll.SetPayloadStore(o.payloadStore, o.payloadThreshold)
+	}
+	flowBll.SetRequestTimeout(o.requestTimeout)
+
+	e.db = db
+	if o.autoMigrate {
+		err = e.Migrate()
+		if err != nil {
+			return e, err
+		}
+	}
+
 	e.flowBll = &flowBll
 	e.parser = parser
 	e.execer = execer
+	e.exprTrace = o.exprTrace
+	e.execers = map[string]Execer{
+		ExecerQLang: NewQLangExecer(),
+		ExecerExpr:  NewExprExecer(),
+	}
 	return e, nil
 }
 
+// Migrate 执行数据库迁移(创建索引、增加字段等)
+func (e *Engine) Migrate() error {
+	return e.db.Migrate(register.FlowMigrations()...)
+}
+
 // SetParser 设定解析器
 func (e *Engine) SetParser(parser Parser) {
 	e.parser = parser
@@ -84,6 +173,63 @@ func (e *Engine) SetExecer(execer Execer) {
 	e.execer = execer
 }
 
+// RegisterExecer 注册表达式执行器，流程可以通过扩展属性execer指定使用的表达式执行器
+func (e *Engine) RegisterExecer(name string, execer Execer) {
+	e.execerLock.Lock()
+	defer e.execerLock.Unlock()
+	if e.execers == nil {
+		e.execers = make(map[string]Execer)
+	}
+	e.execers[name] = execer
+}
+
+// 根据流程扩展属性获取表达式执行器(未指定时使用引擎的表达式执行器)
+func (e *Engine) getExecer(name string) (Execer, error) {
+	if name == "" {
+		return e.execer, nil
+	}
+
+	e.execerLock.RLock()
+	defer e.execerLock.RUnlock()
+	execer, ok := e.execers[name]
+	if !ok {
+		return nil, fmt.Errorf("表达式执行器(%s)未注册", name)
+	}
+	return execer, nil
+}
+
+// 获取流程使用的表达式执行器(流程定义部署后不可修改，按流程内码缓存)
+func (e *Engine) flowExecer(flowID string) (Execer, error) {
+	if v, ok := e.flowExecers.Load(flowID); ok {
+		return e.getExecer(v.(string))
+	}
+
+	properties, err := e.flowBll.QueryFlowProperties(flowID)
+	if err != nil {
+		return nil, err
+	}
+
+	var name string
+	for _, p := range properties {
+		if p.Name == ExecerProperty {
+			name = p.Value
+			break
+		}
+	}
+	e.flowExecers.Store(flowID, name)
+	return e.getExecer(name)
+}
+
+// 获取流程解析结果中指定的表达式执行器
+func (e *Engine) resultExecer(result *ParseResult) (Execer, error) {
+	for _, p := range result.FlowProperties {
+		if p.Name == ExecerProperty {
+			return e.getExecer(p.Value)
+		}
+	}
+	return e.execer, nil
+}
+
 // SetLogger 设定日志接口
 func (e *Engine) SetLogger(logger Logger) {
 	e.logger = logger
@@ -99,6 +245,22 @@ func (e *Engine) SetAutoCallback(callback AutoCallbackHandler) {
 	e.autoCallback = callback
 }
 
+// SetOrgProvider 设定组织机构数据提供者(表达式中可以使用manager、usersInRole、deptHead等内置函数)
+func (e *Engine) SetOrgProvider(provider OrgProvider) {
+	e.orgProvider = provider
+}
+
+// 将组织机构数据提供者及已部署的决策表加入表达式执行的上下文(上下文中已存在时不覆盖)
+func (e *Engine) exprContext(ctx context.Context) context.Context {
+	if _, ok := FromOrgContext(ctx); !ok && e.orgProvider != nil {
+		ctx = NewOrgContext(ctx, e.orgProvider)
+	}
+	if _, ok := fromDecisionContext(ctx); !ok {
+		ctx = newDecisionContext(ctx, e.getDecision)
+	}
+	return ctx
+}
+
 // FlowBll 流程业务
 func (e *Engine) FlowBll() *bll.Flow {
 	return e.flowBll
@@ -159,7 +321,8 @@ func (e *Engine) handleExpiredNodeTiming(item *schema.NodeTiming) error {
 	if err != nil {
 		return err
 	} else if ni == nil || ni.Status != 1 {
-		return nil
+		// 节点实例已处理或已取消，删除定时任务
+		return e.flowBll.DeleteNodeTiming(item.NodeInstanceID)
 	}
 
 	ctx := context.Background()
@@ -235,12 +398,17 @@ func (e *Engine) parseFile(name string) ([]byte, error) {
 	return data, nil
 }
 
-// LoadFile 加载文件数据
+// LoadFile 加载文件数据(*.dmn文件部署为决策表)
 func (e *Engine) LoadFile(name string) error {
 	data, err := e.parseFile(name)
 	if err != nil {
 		return err
 	}
+
+	if isDecisionFile(name) {
+		_, err = e.DeployDecisions(data, DeployOptions{})
+		return err
+	}
 	_, err = e.CreateFlow(data)
 	return err
 }
@@ -399,35 +567,237 @@ func (e *Engine) parseOperating(flow *schema.Flow, nodeResults []*NodeResult) (*
 	return nodeOperating, formOperating
 }
 
-// CreateFlow 创建流程数据
+// CreateFlow 创建流程数据(根据数据内容自动识别BPMN或JSON格式)
+// 如果文件中包含多个可执行流程，则分别创建，返回第一个流程的内码
 func (e *Engine) CreateFlow(data []byte) (string, error) {
-	result, err := e.parser.Parse(context.Background(), data)
+	return e.CreateFlowWithFormat(data, FormatAuto)
+}
+
+// CreateFlowWithFormat 使用指定的格式创建流程数据
+func (e *Engine) CreateFlowWithFormat(data []byte, format Format) (string, error) {
+	flowIDs, err := e.CreateFlowsWithFormat(data, format)
 	if err != nil {
 		return "", err
 	}
+	return flowIDs[0], nil
+}
+
+// CreateFlows 创建流程数据，文件中的每个可执行流程分别创建为一个流程，返回流程内码列表
+func (e *Engine) CreateFlows(data []byte) ([]string, error) {
+	return e.CreateFlowsWithFormat(data, FormatAuto)
+}
+
+// CreateFlowsWithFormat 使用指定的格式创建流程数据，返回流程内码列表
+func (e *Engine) CreateFlowsWithFormat(data []byte, format Format) ([]string, error) {
+	results, format, err := e.parseFlows(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	// 仅BPMN格式保存原始数据(用于流程设计器)
+	var xml string
+	if format == FormatBPMN {
+		xml = string(data)
+	}
+
+	flowIDs := make([]string, len(results))
+	for i, result := range results {
+		flowID, err := e.createFlow(result, xml, checksum(data))
+		if err != nil {
+			return nil, err
+		}
+		flowIDs[i] = flowID
+	}
+	return flowIDs, nil
+}
+
+// 解析并校验流程定义数据，返回解析结果及识别的格式
+func (e *Engine) parseFlows(data []byte, format Format) ([]*ParseResult, Format, error) {
+	if format == FormatAuto {
+		format = DetectFormat(data)
+	}
+
+	parser := e.parser
+	if format == FormatJSON {
+		parser = NewJSONParser()
+	}
+
+	var results []*ParseResult
+	if p, ok := parser.(MultiParser); ok {
+		items, err := p.ParseAll(context.Background(), data)
+		if err != nil {
+			return nil, format, err
+		}
+		results = items
+	} else {
+		result, err := parser.Parse(context.Background(), data)
+		if err != nil {
+			return nil, format, err
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		return nil, format, errors.New("未找到流程定义")
+	}
+
+	// 校验流程数据及表达式，存在错误级别的诊断时不允许创建流程
+	for _, result := range results {
+		diagnostics := Validate(result)
+
+		execer, err := e.resultExecer(result)
+		if err != nil {
+			diagnostics = append(diagnostics, Diagnostic{
+				FlowID:   result.FlowID,
+				Severity: SeverityError,
+				Message:  err.Error(),
+			})
+		} else {
+			diagnostics = append(diagnostics, CheckExpressions(execer, result)...)
+		}
 
-	// 检查流程是否存在，如果存在则检查版本号是否一致，如果不一致则创建新流程
-	oldFlow, err := e.flowBll.GetFlowByCode(result.FlowID)
+		if HasError(diagnostics) {
+			return nil, format, &ValidationError{Diagnostics: diagnostics}
+		}
+	}
+	return results, format, nil
+}
+
+// 计算流程定义数据的校验和
+func checksum(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+func (e *Engine) createFlow(result *ParseResult, xml, sum string) (string, error) {
+	e.deployLock.Lock()
+	defer e.deployLock.Unlock()
+
+	// 检查流程是否存在，如果存在则检查版本号是否高于已部署的版本，如果高于则部署并启用新版本
+	maxVersion, err := e.flowBll.GetMaxFlowVersion(result.FlowID)
 	if err != nil {
 		return "", err
-	} else if oldFlow != nil {
-		if result.FlowVersion <= oldFlow.Version {
+	} else if maxVersion > 0 && result.FlowVersion <= maxVersion {
+		oldFlow, err := e.flowBll.GetFlowByCode(result.FlowID)
+		if err != nil {
+			return "", err
+		} else if oldFlow != nil {
 			return oldFlow.RecordID, nil
 		}
 	}
 
+	deployment, err := e.deployFlow(result, xml, sum, DeployOptions{Activate: true})
+	if err != nil {
+		return "", err
+	}
+	return deployment.FlowID, nil
+}
+
+// DeployOptions 流程部署选项
+type DeployOptions struct {
+	Format   Format // 流程定义格式(为空时自动识别)
+	Activate bool   // 是否启用部署的版本(启用后新发起的流程使用该版本，运行中的流程实例不受影响)
+	Comment  string // 部署说明
+	Author   string // 部署人
+}
+
+// DeployFlow 部署流程(流程定义数据中只能包含一个流程)
+// 流程定义中未设定版本号时，版本号在已部署的最大版本号基础上自动递增；设定的版本号已存在时返回错误
+func (e *Engine) DeployFlow(data []byte, opts DeployOptions) (*schema.FlowDeployment, error) {
+	results, format, err := e.parseFlows(data, opts.Format)
+	if err != nil {
+		return nil, err
+	} else if len(results) != 1 {
+		// 在写入任何流程之前检查，避免返回错误时部分流程已部署
+		return nil, fmt.Errorf("流程定义数据中包含%d个流程，请使用DeployFlows部署", len(results))
+	}
+
+	deployments, err := e.deployResults(results, format, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return deployments[0], nil
+}
+
+// DeployFlows 部署流程定义数据中的所有流程
+func (e *Engine) DeployFlows(data []byte, opts DeployOptions) ([]*schema.FlowDeployment, error) {
+	results, format, err := e.parseFlows(data, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	return e.deployResults(results, format, data, opts)
+}
+
+func (e *Engine) deployResults(results []*ParseResult, format Format, data []byte, opts DeployOptions) ([]*schema.FlowDeployment, error) {
+	e.deployLock.Lock()
+	defer e.deployLock.Unlock()
+
+	var xml string
+	if format == FormatBPMN {
+		xml = string(data)
+	}
+
+	deployments := make([]*schema.FlowDeployment, len(results))
+	for i, result := range results {
+		deployment, err := e.deployFlow(result, xml, checksum(data), opts)
+		if err != nil {
+			return nil, err
+		}
+		deployments[i] = deployment
+	}
+	return deployments, nil
+}
+
+func (e *Engine) deployFlow(result *ParseResult, xml, sum string, opts DeployOptions) (*schema.FlowDeployment, error) {
+	_, err := e.resultExecer(result)
+	if err != nil {
+		return nil, err
+	}
+
+	maxVersion, err := e.flowBll.GetMaxFlowVersion(result.FlowID)
+	if err != nil {
+		return nil, err
+	}
+
+	version := result.FlowVersion
+	if version == 0 {
+		version = maxVersion + 1
+	} else if version <= maxVersion {
+		exists, err := e.flowBll.GetFlowByCodeAndVersion(result.FlowID, version)
+		if err != nil {
+			return nil, err
+		} else if exists != nil {
+			return nil, fmt.Errorf("流程(%s)的版本(%d)已存在", result.FlowID, version)
+		}
+	}
+
 	flow := &schema.Flow{
 		RecordID: util.UUID(),
 		Code:     result.FlowID,
 		Name:     result.FlowName,
-		Version:  result.FlowVersion,
-		XML:      string(data),
+		Version:  version,
+		TypeCode: result.FlowCategory,
+		XML:      xml,
+		Memo:     result.FlowDocumentation,
 		Status:   result.FlowStatus,
 		Created:  time.Now().Unix(),
 	}
+	if opts.Activate {
+		flow.Activated = 1
+	}
 
 	nodeOperating, formOperating := e.parseOperating(flow, result.Nodes)
 
+	// 增加流程扩展属性
+	for _, p := range result.FlowProperties {
+		nodeOperating.FlowPropertyGroup = append(nodeOperating.FlowPropertyGroup, &schema.FlowProperty{
+			RecordID: util.UUID(),
+			FlowID:   flow.RecordID,
+			Name:     p.Name,
+			Value:    p.Value,
+			Created:  flow.Created,
+		})
+	}
+
 	// 解析节点表单数据
 	for _, node := range result.Nodes {
 		// 查找表单ID不为空并且不包含表单字段的节点
@@ -451,11 +821,200 @@ func (e *Engine) CreateFlow(data []byte) (string, error) {
 		}
 	}
 
-	err = e.flowBll.CreateFlow(flow, nodeOperating, formOperating)
+	deployment := &schema.FlowDeployment{
+		RecordID: util.UUID(),
+		FlowID:   flow.RecordID,
+		FlowCode: flow.Code,
+		Version:  flow.Version,
+		Checksum: sum,
+		Comment:  opts.Comment,
+		Author:   opts.Author,
+		Created:  flow.Created,
+	}
+
+	err = e.flowBll.DeployFlow(flow, nodeOperating, formOperating, deployment)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return flow.RecordID, nil
+	return deployment, nil
+}
+
+// ActivateFlowVersion 启用流程版本(新发起的流程使用启用的版本，可用于回滚到旧版本，运行中的流程实例不受影响)
+// code 流程编号
+// version 版本号
+func (e *Engine) ActivateFlowVersion(code string, version int64) error {
+	return e.flowBll.ActivateFlowVersion(code, version)
+}
+
+// QueryDeployments 查询流程的部署记录(按部署时间倒序)
+// code 流程编号
+func (e *Engine) QueryDeployments(code string) ([]*schema.FlowDeploymentResult, error) {
+	return e.flowBll.QueryDeployments(code)
+}
+
+// GetFlow 获取流程数据(包括流程扩展属性)
+func (e *Engine) GetFlow(flowID string) (*schema.Flow, error) {
+	flow, err := e.flowBll.GetFlow(flowID)
+	if err != nil || flow == nil {
+		return flow, err
+	}
+
+	properties, err := e.flowBll.QueryFlowProperties(flowID)
+	if err != nil {
+		return nil, err
+	}
+
+	flow.Properties = make(map[string]string)
+	for _, p := range properties {
+		flow.Properties[p.Name] = p.Value
+	}
+	return flow, nil
+}
+
+// ExportFlow 导出流程定义数据(根据存储的流程数据重建BPMN或JSON格式的流程定义)
+// flowID 流程内码
+// format 导出格式(为空时导出BPMN)
+func (e *Engine) ExportFlow(flowID string, format Format) ([]byte, error) {
+	flow, err := e.flowBll.GetFlow(flowID)
+	if err != nil {
+		return nil, err
+	} else if flow == nil {
+		return nil, ErrNotFound
+	}
+
+	result, err := e.loadParseResult(flow)
+	if err != nil {
+		return nil, err
+	}
+
+	var serializer Serializer
+	switch format {
+	case FormatAuto, FormatBPMN:
+		serializer = NewXMLSerializer(DiagramSourceOption([]byte(flow.XML)))
+	case FormatJSON:
+		serializer = NewJSONSerializer()
+	default:
+		return nil, fmt.Errorf("不支持的导出格式：%s", format)
+	}
+	return serializer.Serialize(context.Background(), result)
+}
+
+// 根据存储的流程数据重建流程解析结果
+func (e *Engine) loadParseResult(flow *schema.Flow) (*ParseResult, error) {
+	result := &ParseResult{
+		FlowID:            flow.Code,
+		FlowName:          flow.Name,
+		FlowVersion:       flow.Version,
+		FlowStatus:        flow.Status,
+		FlowCategory:      flow.TypeCode,
+		FlowDocumentation: flow.Memo,
+	}
+
+	flowProperties, err := e.flowBll.QueryFlowProperties(flow.RecordID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range flowProperties {
+		result.FlowProperties = append(result.FlowProperties, &PropertyResult{Name: p.Name, Value: p.Value})
+	}
+
+	nodes, err := e.flowBll.QueryNodesByFlowID(flow.RecordID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeCodes := make(map[string]string)
+	for _, node := range nodes {
+		nodeCodes[node.RecordID] = node.Code
+	}
+
+	forms := make(map[string]*NodeFormResult)
+	for _, node := range nodes {
+		nodeType, err := GetNodeTypeByName(node.TypeCode)
+		if err != nil {
+			return nil, err
+		}
+
+		nodeResult := &NodeResult{
+			NodeID:     node.Code,
+			NodeName:   node.Name,
+			NodeType:   nodeType,
+			FormResult: new(NodeFormResult),
+		}
+
+		routers, err := e.flowBll.QueryNodeRouters(node.RecordID)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range routers {
+			nodeResult.Routers = append(nodeResult.Routers, &RouterResult{
+				TargetNodeID: nodeCodes[r.TargetNodeID],
+				Explain:      r.Explain,
+				Expression:   r.Expression,
+			})
+		}
+
+		assignments, err := e.flowBll.QueryNodeAssignments(node.RecordID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range assignments {
+			nodeResult.CandidateExpressions = append(nodeResult.CandidateExpressions, a.Expression)
+		}
+
+		properties, err := e.flowBll.QueryNodeProperty(node.RecordID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range properties {
+			nodeResult.Properties = append(nodeResult.Properties, &PropertyResult{Name: p.Name, Value: p.Value})
+		}
+
+		if node.FormID != "" {
+			form, ok := forms[node.FormID]
+			if !ok {
+				form, err = e.loadFormResult(node.FormID)
+				if err != nil {
+					return nil, err
+				}
+				forms[node.FormID] = form
+			}
+			if form != nil {
+				nodeResult.FormResult = form
+			}
+		}
+
+		result.Nodes = append(result.Nodes, nodeResult)
+	}
+
+	return result, nil
+}
+
+// 根据存储的表单数据重建节点表单
+func (e *Engine) loadFormResult(formID string) (*NodeFormResult, error) {
+	form, err := e.flowBll.GetForm(formID)
+	if err != nil {
+		return nil, err
+	} else if form == nil {
+		return nil, nil
+	}
+
+	result := &NodeFormResult{ID: form.Code}
+	if form.TypeCode == "URL" {
+		result.Fields = []*FormFieldResult{
+			{ID: "type_code", DefaultValue: "URL"},
+			{ID: "data", DefaultValue: form.Data},
+		}
+		return result, nil
+	}
+
+	if form.Data != "" && form.Data != "null" {
+		err = json.Unmarshal([]byte(form.Data), &result.Fields)
+		if err != nil {
+			return nil, errors.Wrapf(err, "解析表单(%s)数据发生错误", form.Code)
+		}
+	}
+	return result, nil
 }
 
 // HandleResult 处理结果
@@ -549,23 +1108,27 @@ func (e *Engine) nextFlowHandle(ctx context.Context, nodeInstanceID, userID stri
 // userID 发起人
 // inputData 输入数据
 func (e *Engine) StartFlow(ctx context.Context, flowCode, nodeCode, userID string, inputData []byte) (*HandleResult, error) {
-	nodeInstance, err := e.flowBll.LaunchFlowInstance(flowCode, nodeCode, userID, inputData)
-	if err != nil {
-		return nil, err
-	} else if nodeInstance == nil {
-		return nil, errors.New("未找到流程信息")
-	}
+	return e.idempotentHandle(ctx, "start", userID, func() (*HandleResult, error) {
+		nodeInstance, err := e.flowBll.LaunchFlowInstance(flowCode, nodeCode, userID, inputData)
+		if err != nil {
+			return nil, err
+		} else if nodeInstance == nil {
+			return nil, errors.New("未找到流程信息")
+		}
 
-	return e.nextFlowHandle(ctx, nodeInstance.RecordID, userID, inputData)
+		return e.nextFlowHandle(ctx, nodeInstance.RecordID, userID, inputData)
+	})
 }
 
 // LaunchFlow 发起流程（基于流程ID）
 func (e *Engine) LaunchFlow(ctx context.Context, flowID, userID string, inputData []byte) (*HandleResult, error) {
-	_, ni, err := e.flowBll.LaunchFlowInstance2(flowID, userID, 1, inputData)
-	if err != nil {
-		return nil, err
-	}
-	return e.nextFlowHandle(ctx, ni.RecordID, userID, inputData)
+	return e.idempotentHandle(ctx, "launch", userID, func() (*HandleResult, error) {
+		_, ni, err := e.flowBll.LaunchFlowInstance2(flowID, userID, 1, inputData)
+		if err != nil {
+			return nil, err
+		}
+		return e.nextFlowHandle(ctx, ni.RecordID, userID, inputData)
+	})
 }
 
 // HandleFlow 处理流程节点
@@ -573,22 +1136,67 @@ func (e *Engine) LaunchFlow(ctx context.Context, flowID, userID string, inputDat
 // userID 处理人
 // inputData 输入数据
 func (e *Engine) HandleFlow(ctx context.Context, nodeInstanceID, userID string, inputData []byte) (*HandleResult, error) {
-	// 检查是否是节点候选人
-	exists, err := e.flowBll.CheckNodeCandidate(nodeInstanceID, userID)
+	return e.idempotentHandle(ctx, "handle", userID, func() (*HandleResult, error) {
+		// 检查是否是节点候选人
+		exists, err := e.flowBll.CheckNodeCandidate(nodeInstanceID, userID)
+		if err != nil {
+			return nil, err
+		} else if !exists {
+			return nil, fmt.Errorf("无效的节点处理人")
+		}
+
+		nodeInstance, err := e.flowBll.GetNodeInstance(nodeInstanceID)
+		if err != nil {
+			return nil, err
+		} else if nodeInstance == nil || nodeInstance.Status != 1 {
+			return nil, fmt.Errorf("无效的处理节点")
+		}
+
+		return e.nextFlowHandle(ctx, nodeInstanceID, userID, inputData)
+	})
+}
+
+// 幂等处理：上下文中存在幂等键时，相同幂等键的重复请求直接返回首次处理的结果
+func (e *Engine) idempotentHandle(ctx context.Context, action, userID string, handle func() (*HandleResult, error)) (*HandleResult, error) {
+	key, ok := FromIdempotencyKeyContext(ctx)
+	if !ok {
+		return handle()
+	}
+
+	req, reserved, err := e.flowBll.ReserveRequest(key, action, userID)
 	if err != nil {
 		return nil, err
-	} else if !exists {
-		return nil, fmt.Errorf("无效的节点处理人")
+	} else if !reserved {
+		var result HandleResult
+		err = json.Unmarshal([]byte(req.Result), &result)
+		if err != nil {
+			return nil, errors.Wrapf(err, "解析幂等请求的处理结果发生错误")
+		}
+		return &result, nil
 	}
 
-	nodeInstance, err := e.flowBll.GetNodeInstance(nodeInstanceID)
+	result, err := handle()
 	if err != nil {
+		if rerr := e.flowBll.ReleaseRequest(key); rerr != nil {
+			e.errorf("%+v", rerr)
+		}
 		return nil, err
-	} else if nodeInstance == nil || nodeInstance.Status != 1 {
-		return nil, fmt.Errorf("无效的处理节点")
 	}
 
-	return e.nextFlowHandle(ctx, nodeInstanceID, userID, inputData)
+	var flowInstanceID, nodeInstanceID string
+	if result.FlowInstance != nil {
+		flowInstanceID = result.FlowInstance.RecordID
+	}
+	if len(result.NextNodes) > 0 && result.NextNodes[0].NodeInstance != nil {
+		nodeInstanceID = result.NextNodes[0].NodeInstance.RecordID
+	}
+
+	// 保存处理结果失败时幂等键仍处于处理中，超过处理超时时间后允许重试
+	err = e.flowBll.DoneRequest(key, flowInstanceID, nodeInstanceID, []byte(result.String()))
+	if err != nil {
+		e.errorf("%+v", err)
+	}
+	return result, nil
 }
 
 // StopFlow 停止流程
@@ -621,6 +1229,111 @@ func (e *Engine) StopFlowInstance(flowInstanceID string, allowStop func(*schema.
 	return e.flowBll.StopFlowInstance(flowInstanceID)
 }
 
+// MoveToNode 将流程实例移动到指定节点(管理操作：取消所有待处理的节点实例及其定时任务，激活目标节点)
+// 仅支持移动到人工任务节点，目标节点为其他类型时返回错误
+// flowInstanceID 流程实例内码
+// targetNodeCode 目标节点编号(必须是人工任务)
+// candidates 目标节点的候选人(为空时根据节点的指派表达式重新计算)
+// reason 操作原因
+// operator 操作人
+func (e *Engine) MoveToNode(ctx context.Context, flowInstanceID, targetNodeCode string, candidates []string, reason, operator string) (*NextNode, error) {
+	flowInstance, err := e.flowBll.GetFlowInstance(flowInstanceID)
+	if err != nil {
+		return nil, err
+	} else if flowInstance == nil {
+		return nil, ErrNotFound
+	} else if flowInstance.Status != 1 && flowInstance.Status != 2 {
+		return nil, errors.New("流程实例已结束")
+	}
+
+	node, err := e.flowBll.GetNodeByCode(flowInstance.FlowID, targetNodeCode)
+	if err != nil {
+		return nil, err
+	} else if node == nil {
+		return nil, fmt.Errorf("目标节点(%s)不存在", targetNodeCode)
+	} else if node.TypeCode != UserTask.String() {
+		return nil, fmt.Errorf("目标节点(%s)不是人工任务", targetNodeCode)
+	}
+
+	if len(candidates) == 0 {
+		candidates, err = e.evalNodeCandidates(ctx, flowInstance, node)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("目标节点(%s)没有候选人", targetNodeCode)
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"node_code":  targetNodeCode,
+		"candidates": candidates,
+		"reason":     reason,
+	})
+
+	log := &schema.InstanceLog{
+		RecordID:       util.UUID(),
+		FlowInstanceID: flowInstanceID,
+		Action:         "move",
+		Operator:       operator,
+		Data:           string(data),
+		Created:        time.Now().Unix(),
+	}
+
+	nodeInstance, err := e.flowBll.MoveFlowInstance(flowInstanceID, flowInstance.FlowID, node.RecordID, candidates, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NextNode{
+		Node:         node,
+		CandidateIDs: candidates,
+		NodeInstance: nodeInstance,
+	}, nil
+}
+
+// 根据节点的指派表达式计算候选人(没有节点输入数据，input与vars均为流程实例变量)
+func (e *Engine) evalNodeCandidates(ctx context.Context, flowInstance *schema.FlowInstance, node *schema.Node) ([]string, error) {
+	assigns, err := e.flowBll.QueryNodeAssignments(node.RecordID)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := e.flowBll.GetVariables(flowInstance.RecordID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := e.flowBll.QueryDoneNodeInstances(flowInstance.RecordID)
+	if err != nil {
+		return nil, err
+	}
+
+	expData, _ := json.Marshal(map[string]interface{}{
+		"input":    vars,
+		"vars":     vars,
+		"flow":     flowInstance,
+		"history":  newHistory(items),
+		"launcher": newLauncher(flowInstance),
+	})
+
+	execer, err := e.flowExecer(flowInstance.FlowID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = e.exprContext(ctx)
+	var candidates []string
+	for _, assign := range assigns {
+		ss, err := execer.ExecReturnStringSlice(ctx, []byte(assign.Expression), expData)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, ss...)
+	}
+	return candidates, nil
+}
+
 // QueryTodoFlows 查询流程待办数据
 // flowCode 流程编号
 // userID 待办人
@@ -659,3 +1372,27 @@ func (e *Engine) QueryNodeCandidates(nodeInstanceID string) ([]string, error) {
 func (e *Engine) GetNodeInstance(nodeInstanceID string) (*schema.NodeInstance, error) {
 	return e.flowBll.GetNodeInstance(nodeInstanceID)
 }
+
+// GetVariables 获取流程实例变量
+func (e *Engine) GetVariables(flowInstanceID string) (map[string]interface{}, error) {
+	return e.flowBll.GetVariables(flowInstanceID)
+}
+
+// SetVariables 设定流程实例变量
+// flowInstanceID 流程实例内码
+// operator 操作人
+func (e *Engine) SetVariables(flowInstanceID, operator string, vars map[string]interface{}) error {
+	flowInstance, err := e.flowBll.GetFlowInstance(flowInstanceID)
+	if err != nil {
+		return err
+	} else if flowInstance == nil {
+		return errors.New("流程实例不存在")
+	}
+
+	return e.flowBll.SetVariables(flowInstanceID, "", operator, vars)
+}
+
+// QueryVariableHistories 查询流程实例变量的变更历史
+func (e *Engine) QueryVariableHistories(flowInstanceID string) ([]*schema.VariableHistory, error) {
+	return e.flowBll.QueryVariableHistories(flowInstanceID)
+}
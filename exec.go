@@ -3,10 +3,20 @@ package flow
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/antlinker/flow/expression"
 )
 
+// 内置的表达式执行器名称
+const (
+	ExecerQLang = "qlang" // 基于qlang的表达式执行器(可以导入脚本模块、访问数据库)
+	ExecerExpr  = "expr"  // 基于expr的表达式执行器(沙箱执行，只能访问流程数据)
+)
+
+// ExecerProperty 指定表达式执行器的流程扩展属性名称
+const ExecerProperty = "execer"
+
 // Execer 表达式执行器
 type Execer interface {
 	// 执行表达式返回布尔类型的值
@@ -14,40 +24,137 @@ type Execer interface {
 
 	// 执行表达式返回字符串切片类型的值
 	ExecReturnStringSlice(ctx context.Context, exp, params []byte) ([]string, error)
+
+	// 执行表达式返回JSON兼容的值(map[string]interface{}、[]interface{}、string、float64、bool或nil)
+	ExecReturnValue(ctx context.Context, exp, params []byte) (interface{}, error)
+
+	// 执行表达式返回整数类型的值
+	ExecReturnInt(ctx context.Context, exp, params []byte) (int, error)
+
+	// 执行表达式返回时间类型的值(支持时间、Unix时间戳及常用格式的时间字符串)
+	ExecReturnTime(ctx context.Context, exp, params []byte) (time.Time, error)
+
+	// 执行表达式返回键为字符串的映射
+	ExecReturnMap(ctx context.Context, exp, params []byte) (map[string]interface{}, error)
+
+	// 检查表达式(语法错误及未定义的变量)，部署流程时调用
+	Check(exp []byte, kind ExpKind) error
+}
+
+// ExpKind 表达式的类型，检查表达式时用于确定返回值的类型
+type ExpKind int
+
+const (
+	// ExpCondition 连线条件，返回布尔类型的值
+	ExpCondition ExpKind = iota + 1
+	// ExpCandidate 指派表达式，返回字符串切片类型的值
+	ExpCandidate
+)
+
+// 表达式中可以使用的变量(与NodeRouter.getExpData一致)
+var expVars = []string{"input", "vars", "flow", "node", "history", "launcher"}
+
+// DefaultExecTimeout 上下文没有设定截止时间时，执行qlang表达式的默认超时时间
+const DefaultExecTimeout = 5 * time.Second
+
+// QLangOption qlang表达式执行器配置
+type QLangOption func(*execer)
+
+// ExecTimeoutOption 设定上下文没有截止时间时的执行超时时间(小于等于0时不限制)
+func ExecTimeoutOption(timeout time.Duration) QLangOption {
+	return func(e *execer) {
+		e.timeout = timeout
+	}
+}
+
+// ExpVarsOption 声明通过表达式上下文(NewExpContext)提供的变量，检查表达式时不作为未定义的变量
+func ExpVarsOption(vars ...string) QLangOption {
+	return func(e *execer) {
+		e.vars = append(e.vars, vars...)
+	}
 }
 
 // NewQLangExecer 创建基于qlang的表达式执行器
-func NewQLangExecer() Execer {
-	return &execer{}
+// 执行步数、允许导入的模块及内置函数通过expression.SetExecerOptions设定
+func NewQLangExecer(opts ...QLangOption) Execer {
+	e := &execer{timeout: DefaultExecTimeout}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 type execer struct {
+	timeout time.Duration
+	vars    []string
 }
 
-func (*execer) ExecReturnBool(ctx context.Context, exp, params []byte) (bool, error) {
-	var m map[string]interface{}
-	err := json.Unmarshal(params, &m)
-	if err != nil {
-		return false, err
+// 上下文没有截止时间时设定默认的超时时间
+func (e *execer) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || e.timeout <= 0 {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, e.timeout)
+}
 
+func (e *execer) expContext(ctx context.Context) (expression.ExpContext, context.CancelFunc, bool) {
 	expCtx, ok := FromExpContext(ctx)
-	if ok {
-		return expression.ExecParamBool(expCtx, string(exp), m)
+	if !ok {
+		return nil, nil, false
+	}
+	if _, ok := expCtx.Deadline(); ok || e.timeout <= 0 {
+		return expCtx, func() {}, true
 	}
-	return expression.ExecParamBool(ctx, string(exp), m)
+	expCtx, cancel := expression.WithTimeout(expCtx, e.timeout)
+	return expCtx, cancel, true
 }
 
-func (*execer) ExecReturnStringSlice(ctx context.Context, exp, params []byte) ([]string, error) {
+// 执行表达式
+func (e *execer) exec(ctx context.Context, exp, params []byte) (*expression.OutData, error) {
 	var m map[string]interface{}
 	err := json.Unmarshal(params, &m)
 	if err != nil {
 		return nil, err
 	}
+	m = withFuncs(ctx, m)
 
-	expCtx, ok := FromExpContext(ctx)
+	expCtx, cancel, ok := e.expContext(ctx)
 	if ok {
-		return expression.ExecParamSliceStr(expCtx, string(exp), m)
+		defer cancel()
+		return expression.ExecParam(expCtx, string(exp), m)
 	}
-	return expression.ExecParamSliceStr(ctx, string(exp), m)
+
+	ctx, cancel = e.withTimeout(ctx)
+	defer cancel()
+	return expression.ExecParam(ctx, string(exp), m)
+}
+
+func (e *execer) ExecReturnBool(ctx context.Context, exp, params []byte) (bool, error) {
+	return expression.Bool(e.exec(ctx, exp, params))
+}
+
+func (e *execer) ExecReturnStringSlice(ctx context.Context, exp, params []byte) ([]string, error) {
+	return expression.SliceStr(e.exec(ctx, exp, params))
+}
+
+func (e *execer) ExecReturnValue(ctx context.Context, exp, params []byte) (interface{}, error) {
+	return expression.Value(e.exec(ctx, exp, params))
+}
+
+func (e *execer) ExecReturnInt(ctx context.Context, exp, params []byte) (int, error) {
+	return expression.Int(e.exec(ctx, exp, params))
+}
+
+func (e *execer) ExecReturnTime(ctx context.Context, exp, params []byte) (time.Time, error) {
+	return expression.Time(e.exec(ctx, exp, params))
+}
+
+func (e *execer) ExecReturnMap(ctx context.Context, exp, params []byte) (map[string]interface{}, error) {
+	return expression.Map(e.exec(ctx, exp, params))
+}
+
+// Check qlang表达式在执行前无法确定返回值的类型，只检查语法错误及未定义的变量
+func (e *execer) Check(exp []byte, kind ExpKind) error {
+	vars := append(append([]string{}, expVars...), e.vars...)
+	return expression.Check(string(exp), vars...)
 }
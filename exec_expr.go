@@ -0,0 +1,157 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/antlinker/flow/expression"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/pkg/errors"
+)
+
+// 编译表达式时使用的变量(与NodeRouter.getExpData一致，并包括组织机构及决策表内置函数，用于检查函数的参数类型)
+var exprCompileEnv = withFuncs(context.Background(), nil)
+
+func init() {
+	// 变量声明为映射，访问其字段时按任意类型检查(nil没有字段，编译时报错)
+	for _, name := range expVars {
+		exprCompileEnv[name] = map[string]interface{}(nil)
+	}
+}
+
+// NewExprExecer 创建基于expr的表达式执行器
+// expr是不支持循环及自定义函数的表达式语言，只能访问input、vars、flow、node变量，不能访问脚本模块和数据库
+func NewExprExecer() Execer {
+	return &exprExecer{}
+}
+
+type exprExecer struct {
+	programs sync.Map
+}
+
+type exprKey struct {
+	exp  string
+	bool bool
+}
+
+// 编译表达式(编译结果按表达式文本缓存)
+func (e *exprExecer) compile(exp string, asBool bool) (*vm.Program, error) {
+	key := exprKey{exp: exp, bool: asBool}
+	if p, ok := e.programs.Load(key); ok {
+		return p.(*vm.Program), nil
+	}
+
+	opts := []expr.Option{expr.Env(exprCompileEnv)}
+	if asBool {
+		opts = append(opts, expr.AsBool())
+	}
+
+	p, err := expr.Compile(exp, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "编译表达式( %s )发生错误", exp)
+	}
+	e.programs.Store(key, p)
+	return p, nil
+}
+
+func (e *exprExecer) run(ctx context.Context, exp, params []byte, asBool bool) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p, err := e.compile(string(exp), asBool)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]interface{})
+	for _, key := range expVars {
+		env[key] = nil
+	}
+	err = json.Unmarshal(params, &env)
+	if err != nil {
+		return nil, err
+	}
+	env = withFuncs(ctx, env)
+
+	out, err := expr.Run(p, env)
+	if err != nil {
+		return nil, errors.Wrapf(err, "执行表达式( %s )发生错误", exp)
+	}
+	return out, nil
+}
+
+func (e *exprExecer) ExecReturnBool(ctx context.Context, exp, params []byte) (bool, error) {
+	out, err := e.run(ctx, exp, params, true)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := out.(bool)
+	if !ok {
+		return false, fmt.Errorf("表达式( %s )的返回值不是布尔类型:%v", exp, out)
+	}
+	return b, nil
+}
+
+func (e *exprExecer) ExecReturnStringSlice(ctx context.Context, exp, params []byte) ([]string, error) {
+	out, err := e.run(ctx, exp, params, false)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := out.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("表达式( %s )的返回值不是字符串数组:%v", exp, out)
+			}
+			ss = append(ss, s)
+		}
+		return ss, nil
+	}
+	return nil, fmt.Errorf("表达式( %s )的返回值不是字符串数组:%v", exp, out)
+}
+
+// 执行表达式并转换为expression.OutData(使用与qlang表达式相同的类型转换)
+func (e *exprExecer) out(ctx context.Context, exp, params []byte) (*expression.OutData, error) {
+	out, err := e.run(ctx, exp, params, false)
+	if err != nil {
+		return nil, err
+	}
+	return &expression.OutData{Result: out}, nil
+}
+
+func (e *exprExecer) ExecReturnValue(ctx context.Context, exp, params []byte) (interface{}, error) {
+	return expression.Value(e.out(ctx, exp, params))
+}
+
+func (e *exprExecer) ExecReturnInt(ctx context.Context, exp, params []byte) (int, error) {
+	return expression.Int(e.out(ctx, exp, params))
+}
+
+func (e *exprExecer) ExecReturnTime(ctx context.Context, exp, params []byte) (time.Time, error) {
+	return expression.Time(e.out(ctx, exp, params))
+}
+
+func (e *exprExecer) ExecReturnMap(ctx context.Context, exp, params []byte) (map[string]interface{}, error) {
+	return expression.Map(e.out(ctx, exp, params))
+}
+
+// Check 编译表达式，连线条件按布尔类型编译(与ExecReturnBool一致)
+func (e *exprExecer) Check(exp []byte, kind ExpKind) error {
+	_, err := e.compile(string(exp), kind == ExpCondition)
+	return err
+}
@@ -0,0 +1,125 @@
+package flow
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const exprParams = `{"input":{"day":5,"users":["a","b"]},"vars":{"leader":true},"flow":{"launcher":"u1"},"node":null}`
+
+func TestExprExecerBool(t *testing.T) {
+	tests := []struct {
+		exp     string
+		want    bool
+		wantErr bool
+	}{
+		{`input.day > 3`, true, false},
+		{`input.day > 3 && !vars.leader`, false, false},
+		{`flow.launcher == "u1"`, true, false},
+		{`input.day`, false, true},
+		{`input.day >`, false, true},
+	}
+
+	execer := NewExprExecer()
+	for _, tt := range tests {
+		got, err := execer.ExecReturnBool(context.Background(), []byte(tt.exp), []byte(exprParams))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.exp, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.exp, got, tt.want)
+		}
+	}
+}
+
+func TestExprExecerStringSlice(t *testing.T) {
+	tests := []struct {
+		exp  string
+		want []string
+	}{
+		{`flow.launcher`, []string{"u1"}},
+		{`input.users`, []string{"a", "b"}},
+		{`[flow.launcher, "admin"]`, []string{"u1", "admin"}},
+	}
+
+	execer := NewExprExecer()
+	for _, tt := range tests {
+		got, err := execer.ExecReturnStringSlice(context.Background(), []byte(tt.exp), []byte(exprParams))
+		if err != nil {
+			t.Errorf("%s: %v", tt.exp, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.exp, got, tt.want)
+		}
+	}
+}
+
+func TestExprExecerCheck(t *testing.T) {
+	execer := NewExprExecer()
+	if err := execer.Check([]byte(`input.day > 3 && flow.launcher == "u1"`), ExpCondition); err != nil {
+		t.Error(err)
+	}
+	if err := execer.Check([]byte(`inptu.day > 3`), ExpCondition); err == nil {
+		t.Error("inptu.day > 3: want error")
+	}
+	if err := execer.Check([]byte(`input.day >`), ExpCondition); err == nil {
+		t.Error("input.day >: want error")
+	}
+
+	// 连线条件必须返回布尔值，指派表达式不限制
+	if err := execer.Check([]byte(`len(input.users)`), ExpCondition); err == nil {
+		t.Error("len(input.users): want error for router condition")
+	}
+	if err := execer.Check([]byte(`len(input.users)`), ExpCandidate); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExprExecerTyped(t *testing.T) {
+	execer := NewExprExecer()
+	ctx := context.Background()
+
+	n, err := execer.ExecReturnInt(ctx, []byte(`input.day * 2`), []byte(exprParams))
+	if err != nil || n != 10 {
+		t.Errorf("ExecReturnInt = %d, %v, want 10", n, err)
+	}
+
+	tm, err := execer.ExecReturnTime(ctx, []byte(`"2018-01-02"`), []byte(exprParams))
+	if err != nil || tm.Format("2006-01-02") != "2018-01-02" {
+		t.Errorf("ExecReturnTime = %v, %v, want 2018-01-02", tm, err)
+	}
+
+	m, err := execer.ExecReturnMap(ctx, []byte(`{"launcher": flow.launcher}`), []byte(exprParams))
+	if err != nil || m["launcher"] != "u1" {
+		t.Errorf("ExecReturnMap = %v, %v", m, err)
+	}
+
+	v, err := execer.ExecReturnValue(ctx, []byte(`input.users`), []byte(exprParams))
+	if err != nil || !reflect.DeepEqual(v, []interface{}{"a", "b"}) {
+		t.Errorf("ExecReturnValue = %v, %v", v, err)
+	}
+}
+
+func TestExprExecerHistory(t *testing.T) {
+	params := `{"input":{},"vars":{},"flow":{"launcher":"u1"},"node":null,` +
+		`"history":{"apply":{"processor":"u1","output":{"amount":20000},"seq":1,"count":1}},` +
+		`"launcher":{"id":"u1","launch_time":1514736000}}`
+
+	execer := NewExprExecer()
+	ok, err := execer.ExecReturnBool(context.Background(), []byte(`history.apply.output.amount > 10000 && launcher.id == "u1"`), []byte(params))
+	if err != nil || !ok {
+		t.Errorf("ExecReturnBool = %v, %v, want true", ok, err)
+	}
+
+	users, err := execer.ExecReturnStringSlice(context.Background(), []byte(`[history.apply.processor]`), []byte(params))
+	if err != nil || !reflect.DeepEqual(users, []string{"u1"}) {
+		t.Errorf("ExecReturnStringSlice = %v, %v, want [u1]", users, err)
+	}
+
+	if err := execer.Check([]byte(`history.apply.count > 1`), ExpCondition); err != nil {
+		t.Error(err)
+	}
+}
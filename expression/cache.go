@@ -0,0 +1,171 @@
+package expression
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"sort"
+	"sync"
+)
+
+// DefaultCacheSize 脚本缓存的默认容量
+const DefaultCacheSize = 1024
+
+var (
+	defaultCache = newScriptCache(DefaultCacheSize)
+)
+
+// SetCacheSize 设定脚本缓存容量(按最近最少使用淘汰)，小于等于0时不缓存
+// 缓存仅省去模板渲染及插桩的过程，qlang脚本在每次执行时仍会重新编译
+func SetCacheSize(size int) {
+	defaultCache.resize(size)
+}
+
+// ResetCache 清空脚本缓存
+func ResetCache() {
+	defaultCache.reset()
+}
+
+// 由模板渲染的表达式脚本
+type program struct {
+	key       string
+	resultKey string
+	code      []byte
+	exp       string
+	refs      []string // 表达式中调用的函数及访问的模块
+	imports   bool     // 表达式中是否包含import/include语句
+}
+
+// 表达式脚本缓存，以表达式文本及执行器、上下文的导入模块和预定义变量作为键
+// 缓存的是渲染模板及插桩后的脚本文本(不是qlang的编译结果)
+type scriptCache struct {
+	lock     sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	lru      *list.List
+}
+
+func newScriptCache(capacity int) *scriptCache {
+	return &scriptCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// 获取表达式脚本，不存在时通过模板渲染并缓存
+func (c *scriptCache) get(opt *tplOption) *program {
+	key := programKey(opt)
+
+	c.lock.Lock()
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		c.lock.Unlock()
+		return el.Value.(*program)
+	}
+	c.lock.Unlock()
+
+	p := renderProgram(key, opt)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.capacity <= 0 {
+		return p
+	}
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*program)
+	}
+	c.items[key] = c.lru.PushFront(p)
+	c.evict()
+	return p
+}
+
+func (c *scriptCache) evict() {
+	for c.lru.Len() > c.capacity {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.items, el.Value.(*program).key)
+	}
+}
+
+func (c *scriptCache) resize(capacity int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if capacity < 0 {
+		capacity = 0
+	}
+	c.capacity = capacity
+	c.evict()
+}
+
+func (c *scriptCache) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+func (c *scriptCache) size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// 渲染表达式脚本(每个脚本使用固定的结果变量，同一表达式重复执行时无需重新渲染模板)
+// 渲染时在循环体及函数体中插入计步函数调用，并记录表达式引用的函数及模块
+func renderProgram(key string, opt *tplOption) *program {
+	buff := bytes.NewBuffer(nil)
+	resultKey := creResultKey()
+	parseExeTpl(buff, &tplOption{
+		Import:    opt.Import,
+		ExecerVar: opt.ExecerVar,
+		CtxVar:    opt.CtxVar,
+		ResultKey: resultKey,
+		Exp:       opt.Exp,
+	})
+	refs, imports := scanRefs(opt.Exp)
+	return &program{
+		key:       key,
+		resultKey: resultKey,
+		code:      instrument(buff.Bytes()),
+		exp:       opt.Exp,
+		refs:      refs,
+		imports:   imports,
+	}
+}
+
+func programKey(opt *tplOption) string {
+	h := sha1.New()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	group := func() {
+		h.Write([]byte{1})
+	}
+
+	keys := make([]string, 0, len(opt.Import))
+	for k := range opt.Import {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		write(k)
+		write(opt.Import[k])
+	}
+	group()
+	for _, p := range opt.ExecerVar {
+		write(p.Key)
+		write(p.Value)
+	}
+	group()
+	for _, p := range opt.CtxVar {
+		write(p.Key)
+		write(p.Value)
+	}
+	group()
+	write(opt.Exp)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,41 @@
+package expression
+
+import (
+	"testing"
+)
+
+func Test_scriptCache(t *testing.T) {
+	c := newScriptCache(2)
+	opt := func(exp string) *tplOption {
+		return &tplOption{Exp: exp}
+	}
+
+	a := c.get(opt("a+1"))
+	if c.get(opt("a+1")) != a {
+		t.Error("same expression should reuse the cached script")
+	}
+
+	// 预定义变量不同时重新渲染
+	b := c.get(&tplOption{Exp: "a+1", ExecerVar: []pairs{{Key: "a", Value: "1"}}})
+	if b == a || b.resultKey == a.resultKey {
+		t.Error("different predefined vars should render a new script")
+	}
+
+	// 按最近最少使用淘汰
+	c.get(opt("a+1"))
+	c.get(opt("a+2"))
+	if n := c.size(); n != 2 {
+		t.Errorf("size = %d, want 2", n)
+	}
+	if c.get(opt("a+1")) != a {
+		t.Error("recently used script should not be evicted")
+	}
+
+	c.resize(0)
+	if n := c.size(); n != 0 {
+		t.Errorf("size after resize(0) = %d, want 0", n)
+	}
+	if p := c.get(opt("a+1")); p == a || c.size() != 0 {
+		t.Error("cache with zero capacity should not keep scripts")
+	}
+}
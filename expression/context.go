@@ -10,36 +10,58 @@ import (
 
 type dbkey struct{}
 
+// 表达式上下文中的数据库(默认数据库的名称为空字符串)
+type dbs map[string]*sql.DB
+
 // CreateExpContextByDB 创建含有DB的ctx
-func CreateExpContextByDB(ctx context.Context, db *sql.DB) ExpContext {
+// name 为空时设定默认数据库，否则按名称注册数据库(多次调用可以注册多个数据库，表达式中通过sqlctx.Use(__ctx__, name)使用)
+func CreateExpContextByDB(ctx context.Context, db *sql.DB, name ...string) ExpContext {
 	if ctx == nil {
 		panic("ctx不能为nil")
 	}
+
+	key := ""
+	if len(name) > 0 {
+		key = name[0]
+	}
+
 	ectx, ok := ctx.(*expContext)
 	if ok {
-
-		ectx.ctx = context.WithValue(ectx.ctx, dbkey{}, db)
+		ectx.ctx = context.WithValue(ectx.ctx, dbkey{}, withDB(ectx.ctx, key, db))
 		return ectx
 	}
 
 	return &expContext{
-		ctx:        context.WithValue(ctx, dbkey{}, db),
+		ctx:        context.WithValue(ctx, dbkey{}, withDB(ctx, key, db)),
 		ql:         qlang.New(),
 		predefined: predefined{data: make([]pairs, 0, 4)},
 	}
 }
 
-// FromExpContextForDB 从ctx中获取*sql.DB
+// 复制上下文中已有的数据库并加入新的数据库
+func withDB(ctx context.Context, name string, db *sql.DB) dbs {
+	m := make(dbs)
+	if v, ok := ctx.Value(dbkey{}).(dbs); ok {
+		for k, d := range v {
+			m[k] = d
+		}
+	}
+	m[name] = db
+	return m
+}
+
+// FromExpContextForDB 从ctx中获取默认的*sql.DB
 func FromExpContextForDB(ctx context.Context) *sql.DB {
-	db := ctx.Value(dbkey{})
-	if db == nil {
+	return FromExpContextForNamedDB(ctx, "")
+}
+
+// FromExpContextForNamedDB 从ctx中获取指定名称的*sql.DB
+func FromExpContextForNamedDB(ctx context.Context, name string) *sql.DB {
+	v, ok := ctx.Value(dbkey{}).(dbs)
+	if !ok {
 		return nil
 	}
-	dbs, ok := db.(*sql.DB)
-	if ok {
-		return dbs
-	}
-	return nil
+	return v[name]
 }
 
 // CreateExpContext 创建一个ExpContext
@@ -58,6 +80,24 @@ func CreateExpContext(ctx context.Context) ExpContext {
 		predefined: predefined{data: make([]pairs, 0, 4)},
 	}
 }
+
+// WithTimeout 为表达式上下文设定超时时间
+// 返回的上下文与ctx共享脚本变量，超时后正在执行的表达式将被中止
+func WithTimeout(ctx ExpContext, timeout time.Duration) (ExpContext, context.CancelFunc) {
+	ec, ok := ctx.(*expContext)
+	if !ok {
+		return ctx, func() {}
+	}
+
+	c, cancel := context.WithTimeout(ec.ctx, timeout)
+	return &expContext{
+		predefined: ec.predefined,
+		ctx:        c,
+		ql:         ec.ql,
+		vars:       ec.vars,
+	}, cancel
+}
+
 func qlangFromContext(ctx ExpContext) *qlang.Qlang {
 	ql, ok := ctx.(*expContext)
 	if ok {
@@ -73,9 +113,10 @@ type pairs struct {
 
 type expContext struct {
 	predefined
-	ctx context.Context
-	ql  *qlang.Qlang
-	err error
+	ctx  context.Context
+	ql   *qlang.Qlang
+	err  error
+	vars map[string]struct{}
 }
 
 func (c *expContext) Var(key string) interface{} {
@@ -83,6 +124,10 @@ func (c *expContext) Var(key string) interface{} {
 }
 
 func (c *expContext) AddVar(key string, value interface{}) {
+	if c.vars == nil {
+		c.vars = make(map[string]struct{})
+	}
+	c.vars[key] = struct{}{}
 	c.ql.SetVar(key, value)
 }
 
@@ -101,7 +146,7 @@ func (c *expContext) Err() error {
 	if c.err != nil {
 		return c.err
 	}
-	return c.Err()
+	return c.ctx.Err()
 }
 
 // Value context.Context 接口实现
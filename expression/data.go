@@ -1,10 +1,12 @@
 package expression
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -85,6 +87,96 @@ func (d OutData) Float() (float64, error) {
 	}
 	return result2float(d.Result)
 }
+
+// Time 获取时间类型数据
+// 支持time.Time、Unix时间戳(秒)及RFC3339、"2006-01-02 15:04:05"、"2006-01-02"格式的字符串
+func (d OutData) Time() (time.Time, error) {
+	if d.IsUndefined() {
+		return time.Time{}, errors.Errorf("未定义变量：spec.Undefined")
+	}
+	if d.IsNil() {
+		return time.Time{}, nil
+	}
+	return result2time(d.Result)
+}
+
+// Map 获取键为字符串的映射类型数据
+func (d OutData) Map() (map[string]interface{}, error) {
+	if d.IsUndefined() {
+		return nil, errors.Errorf("未定义变量：spec.Undefined")
+	}
+	if d.IsNil() {
+		return nil, nil
+	}
+	return result2map(d.Result)
+}
+
+// Value 获取JSON兼容的数据(map[string]interface{}、[]interface{}、string、float64、bool或nil)
+func (d OutData) Value() (interface{}, error) {
+	if d.IsUndefined() {
+		return nil, errors.Errorf("未定义变量：spec.Undefined")
+	}
+	if d.IsNil() {
+		return nil, nil
+	}
+
+	buf, err := json.Marshal(d.Result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "返回值不能转换为JSON:%v", d.Result)
+	}
+
+	var v interface{}
+	err = json.Unmarshal(buf, &v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+func result2time(result interface{}) (time.Time, error) {
+	switch r := result.(type) {
+	case time.Time:
+		return r, nil
+	case *time.Time:
+		return *r, nil
+	case string:
+		for _, layout := range timeLayouts {
+			t, err := time.ParseInLocation(layout, r, time.Local)
+			if err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, errors.Errorf("不能解析的时间格式:%s", r)
+	case bool:
+		return time.Time{}, errors.Errorf("不能处理的类型:%v", r)
+	}
+
+	sec, err := result2int(result)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(sec), 0), nil
+}
+
+func result2map(result interface{}) (map[string]interface{}, error) {
+	if r, ok := result.(map[string]interface{}); ok {
+		return r, nil
+	}
+
+	v := reflect.ValueOf(result)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, errors.Errorf("返回值的类型错误:%v", result)
+	}
+
+	r := make(map[string]interface{}, v.Len())
+	for _, key := range v.MapKeys() {
+		r[key.String()] = v.MapIndex(key).Interface()
+	}
+	return r, nil
+}
+
 func result2string(result interface{}) (string, error) {
 
 	return fmt.Sprintf("%v", result), nil
@@ -97,10 +189,12 @@ func result2float(result interface{}) (float64, error) {
 			return 1, nil
 		}
 		return 0, nil
-	case int, int8, int16, int32, int64, uint, uint16, uint32, uint64:
-		return float64(result.(int)), nil
+	case int, int8, int16, int32, int64:
+		return float64(reflect.ValueOf(result).Int()), nil
+	case uint, uint16, uint32, uint64:
+		return float64(reflect.ValueOf(result).Uint()), nil
 	case float32, float64:
-		return result.(float64), nil
+		return reflect.ValueOf(result).Float(), nil
 	case string:
 		return strconv.ParseFloat(result.(string), 64)
 
@@ -119,10 +213,12 @@ func result2int(result interface{}) (int, error) {
 			return 1, nil
 		}
 		return 0, nil
-	case int, int8, int16, int32, int64, uint, uint16, uint32, uint64:
-		return result.(int), nil
+	case int, int8, int16, int32, int64:
+		return int(reflect.ValueOf(result).Int()), nil
+	case uint, uint16, uint32, uint64:
+		return int(reflect.ValueOf(result).Uint()), nil
 	case float32, float64:
-		return int(result.(float64)), nil
+		return int(reflect.ValueOf(result).Float()), nil
 	case string:
 		return strconv.Atoi(result.(string))
 
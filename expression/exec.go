@@ -3,6 +3,8 @@ package expression
 import (
 	"bytes"
 	"fmt"
+	"path"
+	"strings"
 	"sync/atomic"
 
 	"qlang.io/cl/qlang"
@@ -11,21 +13,30 @@ import (
 )
 
 // CreateExecer 创建表达式执行器
-func CreateExecer(libs string) Execer {
-	return &execExp{
+// opts 执行限制(最大步数、允许导入的模块及允许调用的内置函数)
+func CreateExecer(libs string, opts ...ExecerOption) Execer {
+	e := &execExp{
 		libs:       libs,
 		predefined: predefined{data: make([]pairs, 0, 4)},
+		opts:       &sharedOptions{opts: &execOptions{}},
 		imports:    make(map[string]string),
 	}
+	e.setOptions(opts...)
+	return e
 }
 
 type execExp struct {
 	predefined
 	libs string
+	opts *sharedOptions
 
 	imports map[string]string
 }
 
+func (e *execExp) setOptions(opts ...ExecerOption) {
+	e.opts.set(opts...)
+}
+
 func (e *execExp) ScriptImport(model string) {
 	e.imports[model] = ""
 }
@@ -38,20 +49,32 @@ func (e *execExp) SetLibs(libs string) {
 func (e execExp) Exec(ctx ExpContext, exp string) (out *OutData, err error) {
 
 	ql := qlangFromContext(ctx)
+	p := e.parse(ctx, exp)
+	opts := e.opts.get()
+	err = opts.check(e.imports, e.names(ctx), p)
+	if err != nil {
+		return
+	}
+
 	ql.SetLibs(e.libs)
 	ql.SetVar("__ctx__", ctx)
-	resultKey, expdata := e.parse(ctx, exp)
+	st := &stepper{ctx: ctx, maxSteps: opts.maxSteps}
+	ql.SetVar(stepFunc, st.step)
+	resultKey, expdata := p.resultKey, p.code
 
+	var execErr error
 	ok := make(chan struct{})
 
 	go func() {
 		defer close(ok)
 
-		err = e.exec(ql, expdata)
-		if err != nil {
+		execErr = e.exec(ql, expdata)
+		if st.err != nil {
+			// 超时或超过最大步数时由计步函数中止执行
+			execErr = errors.Wrapf(st.err, "表达式( %s )被中止", exp)
+		} else if execErr != nil {
 			// 错误处理
-			err = errors.Wrapf(err, "表达式( %s )执行失败:%v.", exp, err)
-			return
+			execErr = errors.Wrapf(execErr, "表达式( %s )执行失败:%v.", exp, execErr)
 		}
 	}()
 
@@ -62,6 +85,7 @@ func (e execExp) Exec(ctx ExpContext, exp string) (out *OutData, err error) {
 			err = errors.Wrapf(err, "执行失败:%v", err)
 		}
 	case <-ok:
+		err = execErr
 		if err == nil {
 			o := ql.Var(resultKey)
 			out = &OutData{Result: o}
@@ -70,6 +94,57 @@ func (e execExp) Exec(ctx ExpContext, exp string) (out *OutData, err error) {
 
 	return
 }
+
+// Check 检查表达式的语法及引用的变量是否已定义(函数调用不检查)
+func (e execExp) Check(exp string, vars ...string) error {
+	p := defaultCache.get(&tplOption{
+		Import:    e.imports,
+		ExecerVar: e.data,
+		Exp:       exp,
+	})
+
+	names := e.names(nil)
+	for _, name := range vars {
+		names[name] = struct{}{}
+	}
+	moduleLock.RLock()
+	for name := range modules {
+		names[name] = struct{}{}
+	}
+	moduleLock.RUnlock()
+
+	err := e.opts.get().check(e.imports, names, p)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range scanVars(exp) {
+		if _, ok := names[name]; !ok {
+			return errors.Errorf("表达式( %s )中的变量%s未定义", exp, name)
+		}
+	}
+
+	err = e.compile(p.code)
+	if err != nil {
+		return errors.Wrapf(err, "表达式( %s )语法错误", exp)
+	}
+	return nil
+}
+
+// 编译脚本(不执行)
+func (e execExp) compile(code []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("%v", r)
+		}
+	}()
+
+	ql := qlang.New()
+	ql.SetLibs(e.libs)
+	_, err = ql.Cl(code, "")
+	return
+}
+
 func (e execExp) exec(ql *qlang.Qlang, exp []byte) (err error) {
 	defer func() {
 		if err != nil {
@@ -89,30 +164,39 @@ func (e execExp) exec(ql *qlang.Qlang, exp []byte) (err error) {
 	}
 	return
 }
-func (e execExp) parse(ctx ExpContext, exp string) (string, []byte) {
+func (e execExp) parse(ctx ExpContext, exp string) *program {
 
 	ec := ctx.(*expContext)
-	buff := bytes.NewBuffer(nil)
-
-	key := creResultKey()
-	parseExeTpl(buff, &tplOption{
+	return defaultCache.get(&tplOption{
 		Import:    e.imports,
 		ExecerVar: e.data,
 		CtxVar:    ec.data,
-		ResultKey: key,
 		Exp:       exp,
 	})
+}
+
+// 表达式中可以直接使用的变量(执行器及上下文的预定义变量、上下文中赋值的变量、导入模块的别名及__ctx__)
+func (e execExp) names(ctx ExpContext) map[string]struct{} {
+	names := map[string]struct{}{"__ctx__": {}}
+	for _, p := range e.data {
+		names[p.Key] = struct{}{}
+	}
+	for model, alias := range e.imports {
+		if alias == "" {
+			alias = strings.TrimSuffix(path.Base(model), path.Ext(model))
+		}
+		names[alias] = struct{}{}
+	}
 
-	// // 执行器预定义
-	// e.parsePredefined("Execer", e.data, buff)
-	// // 上下文预定义
-	// e.parsePredefined("Context", ec.data, buff)
-	// // 表达式赋值
-	// buff.WriteString(key)
-	// buff.WriteString(" = ")
-	// buff.WriteString(exp)
-	// buff.WriteString("\n")
-	return key, buff.Bytes()
+	if ec, ok := ctx.(*expContext); ok {
+		for _, p := range ec.data {
+			names[p.Key] = struct{}{}
+		}
+		for key := range ec.vars {
+			names[key] = struct{}{}
+		}
+	}
+	return names
 }
 
 func (e execExp) parsePredefined(key string, ps []pairs, buff *bytes.Buffer) {
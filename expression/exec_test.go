@@ -2,11 +2,14 @@ package expression_test
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/antlinker/flow/expression"
+	"github.com/pkg/errors"
 )
 
 func TestMain(m *testing.M) {
@@ -274,6 +277,140 @@ func Test_Resultslicestring(t *testing.T) {
 	}
 }
 
+func Test_Cache(t *testing.T) {
+	expression.ResetCache()
+	defer expression.SetCacheSize(expression.DefaultCacheSize)
+
+	exp := createTestExpression()
+	for i := 0; i < 3; i++ {
+		got, err := exp.execint("ctx_10*2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 20 {
+			t.Errorf("ctx_10*2 = %d, want 20", got)
+		}
+	}
+
+	// 执行器的预定义变量不同时不能使用缓存的脚本
+	other := expression.CreateExecer("")
+	other.PredefinedVar("ctx_10", "5")
+	out, err := other.Exec(expression.CreateExpContext(context.Background()), "ctx_10*2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := out.Int(); got != 10 {
+		t.Errorf("ctx_10*2 = %d, want 10", got)
+	}
+
+	// 淘汰后重新渲染的脚本执行结果不变
+	expression.SetCacheSize(1)
+	if got, err := exp.execint("ctx_10*2"); err != nil {
+		t.Fatal(err)
+	} else if got != 20 {
+		t.Errorf("ctx_10*2 = %d, want 20", got)
+	}
+}
+
+// 对比缓存渲染的脚本前后的执行耗时(qlang脚本每次执行时仍会编译)
+func Benchmark_Cache(b *testing.B) {
+	defer expression.SetCacheSize(expression.DefaultCacheSize)
+
+	for _, size := range []int{0, expression.DefaultCacheSize} {
+		b.Run(fmt.Sprintf("cache_%d", size), func(b *testing.B) {
+			expression.ResetCache()
+			expression.SetCacheSize(size)
+
+			exp := createTestExpression()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := exp.execint("ctx_10*2"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Test_Sandbox(t *testing.T) {
+	exp := expression.CreateExecer("",
+		expression.MaxStepsOption(100),
+		expression.AllowBuiltinsOption("len"),
+	)
+
+	ectx := expression.CreateExpContext(context.Background())
+	ectx.AddVar("input", map[string]interface{}{"users": []string{"a", "b"}})
+
+	out, err := exp.Exec(ectx, `len(input.users) == 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := out.Bool(); !ok {
+		t.Errorf("len(input.users) == 2 = false, want true")
+	}
+
+	_, err = exp.Exec(ectx, `fn() { for { } }()`)
+	if errors.Cause(err) != expression.ErrStepLimit {
+		t.Errorf("error = %v, want ErrStepLimit", err)
+	}
+
+	// 通过赋值或括号引用不允许的函数及模块
+	for _, s := range []string{
+		`printf("a")`,
+		`x = printf; x("a")`,
+		`(printf)("a")`,
+		`y = sqlctx; y.Query(__ctx__, "delete from t")`,
+	} {
+		_, err = exp.Exec(ectx, s)
+		if errors.Cause(err) != expression.ErrNotAllowed {
+			t.Errorf("%s: error = %v, want ErrNotAllowed", s, err)
+		}
+	}
+
+	out, err = exp.Exec(ectx, `n = 0; for _, u = range []string{"a", "b"} { n += len(u) }; n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := out.Int(); n != 2 {
+		t.Errorf("n = %d, want 2", n)
+	}
+}
+
+func Test_Timeout(t *testing.T) {
+	exp := expression.CreateExecer("")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := exp.Exec(expression.CreateExpContext(ctx), `fn() { for { } }()`)
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func Test_OutData(t *testing.T) {
+	tm, err := expression.OutData{Result: "2018-01-02 15:04:05"}.Time()
+	if err != nil || tm.Format("2006-01-02 15:04:05") != "2018-01-02 15:04:05" {
+		t.Errorf("Time() = %v, %v", tm, err)
+	}
+
+	tm, err = expression.OutData{Result: int64(1514862245)}.Time()
+	if err != nil || tm.Unix() != 1514862245 {
+		t.Errorf("Time() = %v, %v", tm, err)
+	}
+
+	m, err := expression.OutData{Result: map[string]int{"a": 1}}.Map()
+	if err != nil || m["a"] != 1 {
+		t.Errorf("Map() = %v, %v", m, err)
+	}
+
+	v, err := expression.OutData{Result: []int{1, 2}}.Value()
+	if err != nil || !reflect.DeepEqual(v, []interface{}{float64(1), float64(2)}) {
+		t.Errorf("Value() = %v, %v", v, err)
+	}
+}
+
 func createTestExpression() *testExpression {
 	exp := expression.CreateExecer("")
 	exp.PredefinedJson("global", map[string]interface{}{
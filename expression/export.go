@@ -1,11 +1,19 @@
 package expression
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 var (
 	defaultExp = CreateExecer("")
 )
 
+// SetExecerOptions 设定默认表达式执行器的执行限制(可以与表达式的执行并发调用，设定后新执行的表达式生效)
+func SetExecerOptions(opts ...ExecerOption) {
+	defaultExp.(*execExp).setOptions(opts...)
+}
+
 // ScriptImportAlias 设置导入脚本模块　并定义别名
 func ScriptImportAlias(model, alias string) {
 	defaultExp.ScriptImportAlias(model, alias)
@@ -26,6 +34,11 @@ func Exec(ctx ExpContext, exp string) (*OutData, error) {
 	return defaultExp.Exec(ctx, exp)
 }
 
+// Check 检查表达式的语法及引用的变量是否已定义
+func Check(exp string, vars ...string) error {
+	return defaultExp.Check(exp, vars...)
+}
+
 // ExecParam 执行表达式
 func ExecParam(ctx context.Context, exp string, vars map[string]interface{}) (*OutData, error) {
 	ectx := CreateExpContext(ctx)
@@ -45,6 +58,26 @@ func ExecParamSliceStr(ctx context.Context, exp string, vars map[string]interfac
 	return SliceStr(ExecParam(ctx, exp, vars))
 }
 
+// ExecParamInt 执行表达式，返回整数
+func ExecParamInt(ctx context.Context, exp string, vars map[string]interface{}) (int, error) {
+	return Int(ExecParam(ctx, exp, vars))
+}
+
+// ExecParamTime 执行表达式，返回时间
+func ExecParamTime(ctx context.Context, exp string, vars map[string]interface{}) (time.Time, error) {
+	return Time(ExecParam(ctx, exp, vars))
+}
+
+// ExecParamMap 执行表达式，返回映射
+func ExecParamMap(ctx context.Context, exp string, vars map[string]interface{}) (map[string]interface{}, error) {
+	return Map(ExecParam(ctx, exp, vars))
+}
+
+// ExecParamValue 执行表达式，返回JSON兼容的数据
+func ExecParamValue(ctx context.Context, exp string, vars map[string]interface{}) (interface{}, error) {
+	return Value(ExecParam(ctx, exp, vars))
+}
+
 // ExecPredefineVar 执行表达式,传入预编译参数
 func ExecPredefineVar(ctx context.Context, exp string, key string, predefinestr string) (*OutData, error) {
 	ectx := CreateExpContext(ctx)
@@ -70,7 +103,7 @@ func ExecBool(ctx ExpContext, exp string) (bool, error) {
 // Bool 返回布尔值
 func Bool(d *OutData, err ...error) (bool, error) {
 
-	if len(err) > 1 && err[0] != nil {
+	if len(err) > 0 && err[0] != nil {
 		return false, err[0]
 	}
 	return d.Bool()
@@ -79,8 +112,44 @@ func Bool(d *OutData, err ...error) (bool, error) {
 // SliceStr 返回字符串切片
 func SliceStr(d *OutData, err ...error) ([]string, error) {
 
-	if len(err) > 1 && err[0] != nil {
+	if len(err) > 0 && err[0] != nil {
 		return nil, err[0]
 	}
 	return d.SliceStr()
 }
+
+// Int 返回整数
+func Int(d *OutData, err ...error) (int, error) {
+
+	if len(err) > 0 && err[0] != nil {
+		return 0, err[0]
+	}
+	return d.Int()
+}
+
+// Time 返回时间
+func Time(d *OutData, err ...error) (time.Time, error) {
+
+	if len(err) > 0 && err[0] != nil {
+		return time.Time{}, err[0]
+	}
+	return d.Time()
+}
+
+// Map 返回映射
+func Map(d *OutData, err ...error) (map[string]interface{}, error) {
+
+	if len(err) > 0 && err[0] != nil {
+		return nil, err[0]
+	}
+	return d.Map()
+}
+
+// Value 返回JSON兼容的数据
+func Value(d *OutData, err ...error) (interface{}, error) {
+
+	if len(err) > 0 && err[0] != nil {
+		return nil, err[0]
+	}
+	return d.Value()
+}
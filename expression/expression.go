@@ -2,6 +2,7 @@ package expression
 
 import (
 	"context"
+	"sync"
 
 	"qlang.io/cl/qlang"
 )
@@ -45,10 +46,24 @@ type Execer interface {
 	ScriptImport(model string)
 	// 设置脚本模块库
 	SetLibs(libs string)
+	// 检查表达式的语法及引用的变量是否已定义(不执行表达式)
+	// vars 执行时通过上下文提供的变量
+	Check(exp string, vars ...string) error
 }
 
+var (
+	moduleLock sync.RWMutex
+	modules    = make(map[string]struct{})
+)
+
 // GlobalImport 全局导入模块扩展
 // 同一模块只能被导入一次，多次导入会导致panic
 func GlobalImport(name string, table map[string]interface{}) {
 	qlang.Import(name, table)
+
+	if name != "" {
+		moduleLock.Lock()
+		modules[name] = struct{}{}
+		moduleLock.Unlock()
+	}
 }
@@ -0,0 +1,502 @@
+package expression
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// 定义错误
+var (
+	ErrStepLimit  = errors.New("表达式执行步数超过限制")
+	ErrNotAllowed = errors.New("表达式使用了不允许的模块或函数")
+)
+
+// 计步函数的变量名(循环体及函数体开始时调用)
+const stepFunc = "__step__"
+
+// ExecerOption 表达式执行器配置
+type ExecerOption func(*execOptions)
+
+type execOptions struct {
+	maxSteps      int64
+	allowImports  map[string]struct{}
+	allowBuiltins map[string]struct{}
+}
+
+// 执行器的执行限制，修改时生成新的副本，执行中的表达式继续使用开始执行时的副本
+type sharedOptions struct {
+	lock sync.RWMutex
+	opts *execOptions
+}
+
+func (o *sharedOptions) get() *execOptions {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return o.opts
+}
+
+func (o *sharedOptions) set(opts ...ExecerOption) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	// 各配置项只替换字段(不修改已有的map)，浅复制即可
+	next := *o.opts
+	for _, opt := range opts {
+		opt(&next)
+	}
+	o.opts = &next
+}
+
+// MaxStepsOption 设定单次执行允许的最大步数(每次循环迭代或函数调用计为一步)，超过时中止执行并返回ErrStepLimit
+// 小于等于0时不限制
+func MaxStepsOption(steps int64) ExecerOption {
+	return func(o *execOptions) {
+		o.maxSteps = steps
+	}
+}
+
+// AllowImportsOption 设定允许导入的脚本模块
+// 设定后执行器只能导入列表中的模块，表达式中不能使用import及include语句
+func AllowImportsOption(models ...string) ExecerOption {
+	return func(o *execOptions) {
+		o.allowImports = make(map[string]struct{})
+		for _, model := range models {
+			o.allowImports[model] = struct{}{}
+		}
+	}
+}
+
+// AllowBuiltinsOption 设定表达式允许调用的内置函数及模块
+// 设定后表达式中引用的顶层标识符(函数调用、模块访问，以及赋值给其他变量等作为值的引用)必须在列表中，
+// 或者是执行器、上下文中预定义的变量，上下文中赋值的变量，导入模块的别名
+func AllowBuiltinsOption(names ...string) ExecerOption {
+	return func(o *execOptions) {
+		o.allowBuiltins = make(map[string]struct{})
+		for _, name := range names {
+			o.allowBuiltins[name] = struct{}{}
+		}
+	}
+}
+
+// 检查导入的模块及表达式引用的内置函数
+func (o *execOptions) check(imports map[string]string, names map[string]struct{}, p *program) error {
+	if o.allowImports != nil {
+		for model := range imports {
+			if _, ok := o.allowImports[model]; !ok {
+				return errors.Wrapf(ErrNotAllowed, "模块(%s)不允许导入", model)
+			}
+		}
+		if p.imports {
+			return errors.Wrapf(ErrNotAllowed, "表达式( %s )中不允许使用import或include", p.exp)
+		}
+	}
+
+	if o.allowBuiltins != nil {
+		for _, ref := range p.refs {
+			if _, ok := o.allowBuiltins[ref]; ok {
+				continue
+			}
+			if _, ok := names[ref]; ok {
+				continue
+			}
+			return errors.Wrapf(ErrNotAllowed, "表达式( %s )中不允许使用%s", p.exp, ref)
+		}
+	}
+	return nil
+}
+
+// 计步器，在脚本的循环体及函数体中调用
+// 上下文结束或超过最大步数时通过panic中止脚本执行(内置函数及Go函数的调用过程中不计步，无法中止)
+type stepper struct {
+	ctx      ExpContext
+	maxSteps int64
+	steps    int64
+	err      error // 中止执行的原因
+}
+
+func (s *stepper) step() {
+	select {
+	case <-s.ctx.Done():
+		s.err = s.ctx.Err()
+		panic(s.err)
+	default:
+	}
+
+	s.steps++
+	if s.maxSteps > 0 && s.steps > s.maxSteps {
+		s.err = ErrStepLimit
+		panic(s.err)
+	}
+}
+
+// 不作为函数调用检查的关键字
+var keywords = map[string]struct{}{
+	"fn": {}, "func": {}, "if": {}, "else": {}, "for": {}, "range": {}, "return": {},
+	"switch": {}, "case": {}, "default": {}, "break": {}, "continue": {}, "defer": {},
+	"go": {}, "class": {}, "new": {}, "import": {}, "include": {}, "as": {},
+}
+
+type token struct {
+	prev   byte   // 标识符之前的第一个非空白字符
+	pos    int    // 标识符的起始位置
+	end    int    // 标识符的结束位置
+	name   string // 标识符
+	dot    bool   // 是否紧跟在.之后(成员访问)
+	next   byte   // 标识符之后的第一个非空白字符
+	assign bool   // 是否为赋值语句的左侧(name = ...)
+}
+
+// 扫描脚本中的标识符(跳过字符串及注释)
+func scanTokens(code []byte) []*token {
+	var (
+		tokens []*token
+		prev   byte
+	)
+
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			i = skipString(code, i)
+			prev = c
+			continue
+		case c == '/' && i+1 < len(code) && code[i+1] == '/':
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < len(code) && code[i+1] == '*':
+			end := bytes.Index(code[i+2:], []byte("*/"))
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+			continue
+		case isIdentStart(c):
+			start := i
+			for i < len(code) && isIdentPart(code[i]) {
+				i++
+			}
+
+			t := &token{
+				prev: prev,
+				pos:  start,
+				end:  i,
+				name: string(code[start:i]),
+				dot:  prev == '.',
+			}
+			for j := i; j < len(code); j++ {
+				if code[j] != ' ' && code[j] != '\t' {
+					t.next = code[j]
+					t.assign = code[j] == '=' && (j+1 == len(code) || code[j+1] != '=')
+					break
+				}
+			}
+			tokens = append(tokens, t)
+			prev = 'a'
+			continue
+		case c >= '0' && c <= '9':
+			// 数字(包括小数点)
+			for i < len(code) && (isIdentPart(code[i]) || code[i] == '.') {
+				i++
+			}
+			prev = '0'
+			continue
+		}
+
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			prev = c
+		}
+		i++
+	}
+	return tokens
+}
+
+func skipString(code []byte, i int) int {
+	quote := code[i]
+	for i++; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return i
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// 表达式中赋值的变量及函数参数
+func localNames(code []byte, tokens []*token) map[string]struct{} {
+	locals := make(map[string]struct{})
+	for i, t := range tokens {
+		if t.dot {
+			continue
+		}
+		if t.assign {
+			locals[t.name] = struct{}{}
+			// 多变量赋值(k, v = ...)
+			for j := i; j > 0 && t.prev == ',' && tokens[j-1].next == ','; j-- {
+				t = tokens[j-1]
+				if !t.dot {
+					locals[t.name] = struct{}{}
+				}
+			}
+			continue
+		}
+		if (t.name == "fn" || t.name == "func") && t.next == '(' {
+			end := findBlock(code, t.end)
+			for _, p := range tokens[i+1:] {
+				if end < 0 || p.pos >= end {
+					break
+				}
+				locals[p.name] = struct{}{}
+			}
+		}
+	}
+	return locals
+}
+
+// 类型名称(用于类型转换及复合字面量，例如[]string{...}、map[string]int{})
+var typeNames = map[string]struct{}{
+	"int": {}, "int8": {}, "int16": {}, "int32": {}, "int64": {},
+	"uint": {}, "uint8": {}, "uint16": {}, "uint32": {}, "uint64": {}, "uintptr": {},
+	"float": {}, "float32": {}, "float64": {}, "string": {}, "byte": {}, "bool": {},
+	"rune": {}, "var": {}, "map": {}, "chan": {},
+}
+
+// 标识符是否为类型名称(内置类型，或者在]之后的切片、映射元素类型)
+func isTypeName(t *token) bool {
+	if _, ok := typeNames[t.name]; ok {
+		return true
+	}
+	return t.prev == ']'
+}
+
+// 标识符是否为映射字面量的键({key: ...}、{..., key: ...})
+func isMapKey(t *token) bool {
+	return t.next == ':' && (t.prev == '{' || t.prev == ',')
+}
+
+// 获取表达式中引用的顶层标识符(调用的函数、访问的模块及作为值引用的变量)，以及是否包含import/include语句
+// 表达式中赋值的变量、函数参数、字面量及类型名称不作为引用
+func scanRefs(exp string) ([]string, bool) {
+	var (
+		refs    []string
+		imports bool
+		code    = []byte(exp)
+		tokens  = scanTokens(code)
+		locals  = localNames(code, tokens)
+		seen    = make(map[string]struct{})
+	)
+
+	for _, t := range tokens {
+		if t.dot {
+			continue
+		}
+		if t.name == "import" || t.name == "include" {
+			imports = true
+			continue
+		}
+		if _, ok := keywords[t.name]; ok {
+			continue
+		}
+		if _, ok := literals[t.name]; ok {
+			continue
+		}
+		if isTypeName(t) || isMapKey(t) {
+			continue
+		}
+		if _, ok := locals[t.name]; ok {
+			continue
+		}
+		if _, ok := seen[t.name]; ok {
+			continue
+		}
+		seen[t.name] = struct{}{}
+		refs = append(refs, t.name)
+	}
+	return refs, imports
+}
+
+// 不作为变量检查的字面量
+var literals = map[string]struct{}{
+	"true": {}, "false": {}, "nil": {}, "undefined": {}, "_": {},
+}
+
+// 获取表达式中引用的顶层变量(不包括函数调用、成员访问、赋值的变量、函数参数、映射的键及类型名称)
+func scanVars(exp string) []string {
+	var (
+		vars   []string
+		code   = []byte(exp)
+		tokens = scanTokens(code)
+		locals = localNames(code, tokens)
+		seen   = make(map[string]struct{})
+	)
+
+	for _, t := range tokens {
+		if t.dot || t.next == '(' || isMapKey(t) || isTypeName(t) {
+			continue
+		}
+		if _, ok := keywords[t.name]; ok {
+			continue
+		}
+		if _, ok := literals[t.name]; ok {
+			continue
+		}
+		if _, ok := locals[t.name]; ok {
+			continue
+		}
+		if _, ok := seen[t.name]; ok {
+			continue
+		}
+		seen[t.name] = struct{}{}
+		vars = append(vars, t.name)
+	}
+	return vars
+}
+
+// 在循环体及函数体的开始位置插入计步函数调用
+func instrument(code []byte) []byte {
+	var inserts []int
+	for _, t := range scanTokens(code) {
+		if t.dot {
+			continue
+		}
+
+		var pos int
+		switch t.name {
+		case "for":
+			pos = findBlock(code, t.end)
+		case "fn", "func":
+			if t.next != '(' {
+				continue
+			}
+			pos = findBlock(code, t.end)
+		default:
+			continue
+		}
+		if pos > 0 {
+			inserts = append(inserts, pos)
+		}
+	}
+
+	if len(inserts) == 0 {
+		return code
+	}
+
+	sort.Ints(inserts)
+
+	var buf bytes.Buffer
+	last := 0
+	for _, pos := range inserts {
+		if pos == last {
+			continue
+		}
+		buf.Write(code[last:pos])
+		buf.WriteString(stepFunc)
+		buf.WriteString("()\n")
+		last = pos
+	}
+	buf.Write(code[last:])
+	return buf.Bytes()
+}
+
+// 查找语句块的开始位置({之后)，跳过括号中的内容及循环条件中的复合字面量
+func findBlock(code []byte, i int) int {
+	depth := 0
+	for i < len(code) {
+		switch code[i] {
+		case '"', '\'', '`':
+			i = skipString(code, i)
+			continue
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case '{':
+			if depth == 0 {
+				if !isLiteralBrace(code, i) {
+					return i + 1
+				}
+				i = skipBraces(code, i)
+				continue
+			}
+		}
+		i++
+	}
+	return -1
+}
+
+// {是否为复合字面量的开始(而不是语句块)
+// 字面量的{在运算符、赋值、range之后(例如range {"a": 1})，或者紧跟在类型之后(例如[]string{...})
+func isLiteralBrace(code []byte, i int) bool {
+	j := i - 1
+	for j >= 0 && isSpace(code[j]) {
+		j--
+	}
+	if j < 0 {
+		return false
+	}
+
+	c := code[j]
+	switch {
+	case c == '+' || c == '-':
+		// i++ {、i-- {
+		return j == 0 || code[j-1] != c
+	case strings.IndexByte("=,([:*/%&|!<>^", c) >= 0:
+		return true
+	case !isIdentPart(c):
+		return false
+	}
+
+	end := j + 1
+	for j >= 0 && isIdentPart(code[j]) {
+		j--
+	}
+	if string(code[j+1:end]) == "range" {
+		return true
+	}
+	for j >= 0 && isSpace(code[j]) {
+		j--
+	}
+	return j >= 0 && code[j] == ']'
+}
+
+// 跳过成对的{}(包括其中的字符串)，返回}之后的位置
+func skipBraces(code []byte, i int) int {
+	depth := 0
+	for i < len(code) {
+		switch code[i] {
+		case '"', '\'', '`':
+			i = skipString(code, i)
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,120 @@
+package expression
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_scanRefs(t *testing.T) {
+	tests := []struct {
+		exp     string
+		refs    []string
+		imports bool
+	}{
+		{`1+1`, nil, false},
+		{`input.day > 3`, []string{"input"}, false},
+		{`len(input.users) > 0 && sql.Count("select count(*) from t") > 0`, []string{"len", "input", "sql"}, false},
+		{`"os.exit(1)" == a`, []string{"a"}, false},
+		{`fn(a) { return a.b() }(1)`, nil, false},
+		{`f = fn(x) { return x * 2 }; f(1)`, nil, false},
+		{`import "os"`, nil, true},
+		// 作为值引用的函数及模块(赋值给其他变量后调用)
+		{`x = printf; x("a")`, []string{"printf"}, false},
+		{`(printf)("a")`, []string{"printf"}, false},
+		{`y = sqlctx; y.Query(__ctx__, "delete from t")`, []string{"sqlctx", "__ctx__"}, false},
+		{`z = [printf]; z[0]("a")`, []string{"printf"}, false},
+		{`[]string{"a"}[0] == map[string]int{"b": 1}.b`, nil, false},
+		{`{"a": 1, b: true}.b`, nil, false},
+		{`for k, v = range input { len(v) }`, []string{"input", "len"}, false},
+		{`_, u = 1, 2; u`, nil, false},
+	}
+
+	for _, tt := range tests {
+		refs, imports := scanRefs(tt.exp)
+		if !reflect.DeepEqual(refs, tt.refs) || imports != tt.imports {
+			t.Errorf("scanRefs(%s) = %v %v, want %v %v", tt.exp, refs, imports, tt.refs, tt.imports)
+		}
+	}
+}
+
+func Test_instrument(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"a = 1", "a = 1"},
+		{"for i < 3 { i++ }", "for i < 3 {__step__()\n i++ }"},
+		{"f = fn(a) { return a }", "f = fn(a) {__step__()\n return a }"},
+		{`a = "for {"`, `a = "for {"`},
+		{"for k, v = range [1, 2] { fn() {}() }", "for k, v = range [1, 2] {__step__()\n fn() {__step__()\n}() }"},
+		// 循环条件中的切片及映射字面量
+		{`for _, u = range []string{"a", "b"} { x = u }`, "for _, u = range []string{\"a\", \"b\"} {__step__()\n x = u }"},
+		{`for k, v = range {"a": 1} { x = v }`, "for k, v = range {\"a\": 1} {__step__()\n x = v }"},
+		{`for k, v = range map[string]int{"a": 1} { x = v }`, "for k, v = range map[string]int{\"a\": 1} {__step__()\n x = v }"},
+		{`for i < len([]int{1, 2}) { i++ }`, "for i < len([]int{1, 2}) {__step__()\n i++ }"},
+		{`for i = 0; i < 3; i++ { x = i }`, "for i = 0; i < 3; i++ {__step__()\n x = i }"},
+		{`for x = range m[k] { y = x }`, "for x = range m[k] {__step__()\n y = x }"},
+	}
+
+	for _, tt := range tests {
+		if got := string(instrument([]byte(tt.code))); got != tt.want {
+			t.Errorf("instrument(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func Test_scanVars(t *testing.T) {
+	tests := []struct {
+		exp  string
+		vars []string
+	}{
+		{`input.day > 3 && vars.leader`, []string{"input", "vars"}},
+		{`len(inptu.users) > 0`, []string{"inptu"}},
+		{`a == nil || a == true`, []string{"a"}},
+		{`f = fn(x) { return x * y }; f(1)`, []string{"y"}},
+		{`{"a": 1, b: 2}.a`, nil},
+		{`"name" == flow.launcher`, []string{"flow"}},
+		// 复合字面量中的类型名称
+		{`[]string{flow.launcher}`, []string{"flow"}},
+		{`map[string]int{} == m`, []string{"m"}},
+		{`[][]float64{{1}}`, nil},
+		{`SliceStr(sqlctx.Query(__ctx__, "select user_id from t where launcher=?", flow.launcher), "user_id")`, []string{"sqlctx", "__ctx__", "flow"}},
+		{`for k, v = range input { x = k + v }`, []string{"input"}},
+	}
+
+	for _, tt := range tests {
+		if vars := scanVars(tt.exp); !reflect.DeepEqual(vars, tt.vars) {
+			t.Errorf("scanVars(%s) = %v, want %v", tt.exp, vars, tt.vars)
+		}
+	}
+}
+
+func Test_sharedOptions(t *testing.T) {
+	o := &sharedOptions{opts: &execOptions{}}
+	o.set(MaxStepsOption(10), AllowBuiltinsOption("len"))
+
+	// 执行中的表达式继续使用开始执行时的执行限制
+	before := o.get()
+	o.set(MaxStepsOption(20))
+	if before.maxSteps != 10 {
+		t.Errorf("maxSteps = %d, want 10", before.maxSteps)
+	}
+	if after := o.get(); after.maxSteps != 20 || after.allowBuiltins == nil {
+		t.Errorf("options = %+v, want maxSteps 20 and kept builtins", after)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(steps int64) {
+			defer wg.Done()
+			o.set(MaxStepsOption(steps), AllowBuiltinsOption("len"))
+		}(int64(i))
+		go func() {
+			defer wg.Done()
+			_ = o.get().check(nil, nil, &program{refs: []string{"len"}})
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,187 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 占位符类型
+type bindType int
+
+const (
+	bindQuestion bindType = iota // ?(MySQL、SQLite等)
+	bindDollar                   // $1、$2(PostgreSQL)
+)
+
+// 根据数据库驱动判断占位符类型
+func bindTypeOf(db *sql.DB) bindType {
+	name := strings.ToLower(fmt.Sprintf("%T", db.Driver()))
+	for _, s := range []string{"pq.", "pgx", "postgres"} {
+		if strings.Contains(name, s) {
+			return bindDollar
+		}
+	}
+	return bindQuestion
+}
+
+// 跳过从i开始的字符串、带引号的标识符或注释，返回之后的位置(不是以上内容时返回i)
+func skipQuoted(query string, i int) int {
+	switch c := query[i]; {
+	case c == '\'' || c == '"' || c == '`':
+		for j := i + 1; j < len(query); j++ {
+			switch query[j] {
+			case '\\':
+				if c != '`' {
+					j++
+				}
+			case c:
+				if j+1 < len(query) && query[j+1] == c {
+					j++
+					continue
+				}
+				return j + 1
+			}
+		}
+		return len(query)
+	case c == '-' && strings.HasPrefix(query[i:], "--"):
+		if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+			return i + j + 1
+		}
+		return len(query)
+	case c == '/' && strings.HasPrefix(query[i:], "/*"):
+		if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+			return i + 2 + j + 2
+		}
+		return len(query)
+	}
+	return i
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+// 解析命名参数(:name)，返回将命名参数替换为占位符后的语句及参数名称列表
+// 字符串、带引号的标识符及注释中的内容不作为参数，::为PostgreSQL的类型转换
+func parseNamed(query string, bt bindType) (string, []string) {
+	var (
+		buf   strings.Builder
+		names []string
+	)
+
+	for i := 0; i < len(query); {
+		if j := skipQuoted(query, i); j > i {
+			buf.WriteString(query[i:j])
+			i = j
+			continue
+		}
+
+		c := query[i]
+		if c != ':' {
+			buf.WriteByte(c)
+			i++
+			continue
+		}
+
+		if i+1 < len(query) && query[i+1] == ':' {
+			buf.WriteString("::")
+			i += 2
+			continue
+		}
+
+		j := i + 1
+		for j < len(query) && isNameChar(query[j], j == i+1) {
+			j++
+		}
+		if j == i+1 {
+			buf.WriteByte(c)
+			i++
+			continue
+		}
+
+		names = append(names, query[i+1:j])
+		if bt == bindDollar {
+			buf.WriteString("$" + strconv.Itoa(len(names)))
+		} else {
+			buf.WriteByte('?')
+		}
+		i = j
+	}
+
+	return buf.String(), names
+}
+
+// 将命名参数替换为占位符，参数值依次从sources中查找
+// 语句中没有命名参数时返回原语句
+func bindNamed(query string, bt bindType, sources ...map[string]interface{}) (string, []interface{}, error) {
+	q, names := parseNamed(query, bt)
+	if len(names) == 0 {
+		return query, nil, nil
+	}
+
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		found := false
+		for _, m := range sources {
+			if v, ok := m[name]; ok {
+				args[i], found = v, true
+				break
+			}
+		}
+		if !found {
+			return "", nil, fmt.Errorf("参数(:%s)未定义", name)
+		}
+	}
+	return q, args, nil
+}
+
+// 检查是否为只读语句(以SELECT或WITH开头的单条语句)
+func checkReadOnly(query string) error {
+	var (
+		keyword string
+		end     bool
+	)
+
+	for i := 0; i < len(query); {
+		if j := skipQuoted(query, i); j > i {
+			if end && query[i] != '-' && query[i] != '/' {
+				return fmt.Errorf("只读模式下不能执行多条语句:%s", query)
+			}
+			i = j
+			continue
+		}
+
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == ';':
+			end = true
+			i++
+		case end:
+			return fmt.Errorf("只读模式下不能执行多条语句:%s", query)
+		case keyword == "" && isNameChar(c, true):
+			j := i
+			for j < len(query) && isNameChar(query[j], false) {
+				j++
+			}
+			keyword = strings.ToLower(query[i:j])
+			if keyword != "select" && keyword != "with" {
+				return fmt.Errorf("只读模式下只能执行查询语句:%s", query)
+			}
+			i = j
+		default:
+			if keyword == "" {
+				return fmt.Errorf("只读模式下只能执行查询语句:%s", query)
+			}
+			i++
+		}
+	}
+
+	if keyword == "" {
+		return fmt.Errorf("只读模式下只能执行查询语句:%s", query)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseNamed(t *testing.T) {
+	tests := []struct {
+		query string
+		bind  bindType
+		want  string
+		names []string
+	}{
+		{`select * from t where id=?`, bindQuestion, `select * from t where id=?`, nil},
+		{`select * from t where a=:a and b=:b_1`, bindQuestion, `select * from t where a=? and b=?`, []string{"a", "b_1"}},
+		{`select * from t where a=:a or c=:a`, bindDollar, `select * from t where a=$1 or c=$2`, []string{"a", "a"}},
+		{`select ':a', "x:b" from t where c=:c`, bindQuestion, `select ':a', "x:b" from t where c=?`, []string{"c"}},
+		{`select 'it''s :a', id::text from t -- :b`, bindDollar, `select 'it''s :a', id::text from t -- :b`, nil},
+		{`select * from t /* :a */ where d=:d`, bindQuestion, `select * from t /* :a */ where d=?`, []string{"d"}},
+	}
+
+	for _, tt := range tests {
+		got, names := parseNamed(tt.query, tt.bind)
+		if got != tt.want || !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("parseNamed(%s) = %s %v, want %s %v", tt.query, got, names, tt.want, tt.names)
+		}
+	}
+}
+
+func Test_bindNamed(t *testing.T) {
+	params := map[string]interface{}{"user": "u1"}
+	vars := map[string]interface{}{"user": "u2", "dept": "d1"}
+
+	q, args, err := bindNamed(`select * from t where user_id=:user and dept_id=:dept`, bindQuestion, params, vars)
+	if err != nil || q != `select * from t where user_id=? and dept_id=?` || !reflect.DeepEqual(args, []interface{}{"u1", "d1"}) {
+		t.Errorf("bindNamed = %s %v %v", q, args, err)
+	}
+
+	_, _, err = bindNamed(`select * from t where id=:id`, bindQuestion, params, vars)
+	if err == nil {
+		t.Error("bindNamed(:id): want error")
+	}
+}
+
+func Test_checkReadOnly(t *testing.T) {
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{`select * from t`, true},
+		{`  -- comment
+		SELECT count(*) FROM t;`, true},
+		{`with a as (select 1) select * from a`, true},
+		{`select ';' from t`, true},
+		{`update t set a=1`, false},
+		{`select 1; delete from t`, false},
+		{`/* x */ insert into t values(1)`, false},
+		{``, false},
+	}
+
+	for _, tt := range tests {
+		if err := checkReadOnly(tt.query); (err == nil) != tt.ok {
+			t.Errorf("checkReadOnly(%s) = %v, want ok %v", tt.query, err, tt.ok)
+		}
+	}
+}
@@ -4,170 +4,407 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/antlinker/flow/expression"
-
-	"qlang.io/cl/qlang"
 )
 
-// execDB 从ctx获取数据库
-// 没有数据库发出panic
-func execDB(ctx context.Context) *sql.DB {
-	db := expression.FromExpContextForDB(ctx)
-	if db == nil {
-		panic(fmt.Errorf("没有指定数据库不能查询"))
+type options struct {
+	dbOptions
+	dbs   map[string]*sql.DB
+	named map[string][]Option
+}
+
+// 数据库的执行选项
+type dbOptions struct {
+	readOnly bool
+	timeout  time.Duration
+}
+
+// Option sqlctx模块配置
+type Option func(*options)
+
+// ReadOnlyOption 只读模式(只能执行单条SELECT或WITH语句，并在只读事务中执行，不能使用Exec)
+// 默认为只读模式，连线条件在流转及模拟运行时可能执行多次，需要在表达式中写数据库时通过ReadOnlyOption(false)开启
+func ReadOnlyOption(readOnly bool) Option {
+	return func(o *options) {
+		o.readOnly = readOnly
+	}
+}
+
+// TimeoutOption 单条语句的执行超时时间(小于等于0时只受表达式上下文的限制)
+func TimeoutOption(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
 	}
-	return db
 }
 
-// getDB 获取指定数据库
-// 如果ctx没有指定数据库返回defaultDB
-// 如果defaultDB为nil则发出panic
-func getDB(ctx context.Context, defaultDB *sql.DB) *sql.DB {
-	db := expression.FromExpContextForDB(ctx)
+// NamedDBOption 注册指定名称的数据库(表达式上下文中没有同名的数据库时使用)
+// opts 该数据库的只读模式及超时时间(未指定时使用注册时的全局设定)，例如sql.NamedDBOption("report", db, sql.ReadOnlyOption(true))
+func NamedDBOption(name string, db *sql.DB, opts ...Option) Option {
+	return func(o *options) {
+		if o.dbs == nil {
+			o.dbs = make(map[string]*sql.DB)
+		}
+		o.dbs[name] = db
+
+		if len(opts) > 0 {
+			if o.named == nil {
+				o.named = make(map[string][]Option)
+			}
+			o.named[name] = append(o.named[name], opts...)
+		}
+	}
+}
+
+// 默认的配置(只读模式)
+func newOptions() *options {
+	return &options{dbOptions: dbOptions{readOnly: true}}
+}
+
+// 指定名称的数据库的执行选项(全局设定之后应用该数据库的设定)
+func (o *options) dbOptionsOf(name string) dbOptions {
+	no := options{dbOptions: o.dbOptions}
+	for _, opt := range o.named[name] {
+		opt(&no)
+	}
+	return no.dbOptions
+}
+
+// 模块注册信息(每次注册时整体替换)
+type registration struct {
+	defaultDB *sql.DB
+	opts      *options
+}
+
+var (
+	regLock    sync.RWMutex
+	current    = &registration{opts: newOptions()}
+	importOnce sync.Once
+)
+
+func currentRegistration() *registration {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	return current
+}
+
+// getDB 获取指定名称的数据库及其执行选项
+// 依次从ctx(expression.CreateExpContextByDB)、注册的数据库及defaultDB(name为空时)中查找
+// 没有找到时发出panic
+func getDB(ctx context.Context, name string) (*sql.DB, dbOptions) {
+	r := currentRegistration()
+	db := expression.FromExpContextForNamedDB(ctx, name)
 	if db == nil {
-		db = defaultDB
+		db = r.opts.dbs[name]
+	}
+	if db == nil && name == "" {
+		db = r.defaultDB
 	}
 	if db == nil {
-		panic(fmt.Errorf("没有指定数据库不能查询"))
+		if name == "" {
+			panic(fmt.Errorf("没有指定数据库不能查询"))
+		}
+		panic(fmt.Errorf("数据库(%s)未注册", name))
 	}
-	return db
+	return db, r.opts.dbOptionsOf(name)
 }
 
 // Reg 注册数据库DB
 // 有默认数据库操作
 // 也支持多数据库
-func Reg(defaultDB *sql.DB) {
-	qlang.Import("sqlctx", map[string]interface{}{
-		"QueryDB": QueryDB,
-		"Query": func(ctx context.Context, query string, args ...interface{}) []map[string]interface{} {
-
-			return QueryDB(ctx, getDB(ctx, defaultDB), query, args...)
-		},
-		"CountDB": QueryDBCount,
-		"Count": func(ctx context.Context, query string, args ...interface{}) int {
-			return QueryDBCount(ctx, getDB(ctx, defaultDB), query, args...)
-		},
-		"OneDB": QueryOneDB,
-		"One": func(query string, ctx context.Context, args ...interface{}) map[string]interface{} {
-			return QueryOneDB(ctx, getDB(ctx, defaultDB), query, args...)
-		},
-	})
-
+// 重复注册时替换之前注册的数据库及配置(之前的配置不再生效)
+// 默认为只读模式，表达式中需要执行写操作时使用Reg(db, ReadOnlyOption(false))
+func Reg(defaultDB *sql.DB, opts ...Option) {
+	reg(defaultDB, opts...)
 }
 
 // RegMoreDB 注册多数据库支持
 // 没有默认数据库
-func RegMoreDB() {
-	qlang.Import("sqlctx", map[string]interface{}{
-		"QueryDB": QueryDB,
-		"Query": func(ctx context.Context, query string, args ...interface{}) []map[string]interface{} {
-			return QueryDB(ctx, execDB(ctx), query, args...)
-		},
-		"CountDB": QueryDBCount,
-		"Count": func(ctx context.Context, query string, args ...interface{}) int {
-			return QueryDBCount(ctx, execDB(ctx), query, args...)
-		},
-		"OneDB": QueryOneDB,
-		"One": func(query string, ctx context.Context, args ...interface{}) map[string]interface{} {
-			return QueryOneDB(ctx, execDB(ctx), query, args...)
-		},
+func RegMoreDB(opts ...Option) {
+	reg(nil, opts...)
+}
+
+func reg(defaultDB *sql.DB, opts ...Option) {
+	o := newOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	regLock.Lock()
+	current = &registration{defaultDB: defaultDB, opts: o}
+	regLock.Unlock()
+
+	// 模块只能导入一次，模块中的函数使用最近注册的数据库及配置
+	importOnce.Do(func() {
+		expression.GlobalImport("sqlctx", map[string]interface{}{
+			"Use": use,
+			"Query": func(ctx context.Context, query string, args ...interface{}) []map[string]interface{} {
+				return use(ctx, "").Query(query, args...)
+			},
+			"One": func(ctx context.Context, query string, args ...interface{}) map[string]interface{} {
+				return use(ctx, "").One(query, args...)
+			},
+			"Count": func(ctx context.Context, query string, args ...interface{}) int {
+				return use(ctx, "").Count(query, args...)
+			},
+			"Scalar": func(ctx context.Context, query string, args ...interface{}) interface{} {
+				return use(ctx, "").Scalar(query, args...)
+			},
+			"Exists": func(ctx context.Context, query string, args ...interface{}) bool {
+				return use(ctx, "").Exists(query, args...)
+			},
+			"Exec": func(ctx context.Context, query string, args ...interface{}) int64 {
+				return use(ctx, "").Exec(query, args...)
+			},
+			"QueryDB": QueryDB,
+			"CountDB": QueryDBCount,
+			"OneDB":   QueryOneDB,
+		})
 	})
+}
 
+// 使用指定名称的数据库(按名称应用注册时的执行选项)
+func use(ctx context.Context, name string) *DB {
+	db, opts := getDB(ctx, name)
+	d := Use(ctx, db)
+	d.opts = opts
+	return d
 }
 
-// QueryDB 查询sql返回的所有行
-func QueryDB(ctx context.Context, db *sql.DB, query string, args ...interface{}) (out []map[string]interface{}) {
-	// var rows *sql.Rows
-	// var err error
-	rows, err := db.QueryContext(ctx, query, args...)
+// DB 表达式中使用的数据库，执行出错时发出panic(由表达式执行器转换为错误)
+// 语句中可以使用命名参数(:name)，参数值依次从单个map类型的参数、表达式中的vars(流程实例变量)及input变量中查找
+type DB struct {
+	ctx  context.Context
+	db   *sql.DB
+	bind bindType
+	opts dbOptions
+}
+
+// Use 使用指定的数据库执行语句(使用注册时的全局只读模式及超时时间)
+func Use(ctx context.Context, db *sql.DB) *DB {
+	return &DB{
+		ctx:  ctx,
+		db:   db,
+		bind: bindTypeOf(db),
+		opts: currentRegistration().opts.dbOptions,
+	}
+}
 
+// 获取表达式中的变量(不存在或不是map类型时返回nil)
+func ctxVar(ctx context.Context, key string) (m map[string]interface{}) {
+	ec, ok := ctx.(interface{ Var(string) interface{} })
+	if !ok {
+		return nil
+	}
+	defer func() {
+		if recover() != nil {
+			m = nil
+		}
+	}()
+	m, _ = ec.Var(key).(map[string]interface{})
+	return
+}
+
+// 绑定命名参数
+func (d *DB) prepare(query string, args []interface{}) (string, []interface{}) {
+	var named map[string]interface{}
+	if len(args) == 1 {
+		named, _ = args[0].(map[string]interface{})
+	}
+
+	q, nargs, err := bindNamed(query, d.bind, named, ctxVar(d.ctx, "vars"), ctxVar(d.ctx, "input"))
 	if err != nil {
-		panic(fmt.Sprintf("查询失败:%s  %v ==> %v", query, args, err))
+		panic(fmt.Errorf("绑定参数失败:%s ==> %v", query, err))
+	} else if nargs == nil {
+		return query, args
+	} else if len(args) > 0 && named == nil {
+		panic(fmt.Errorf("命名参数不能与位置参数同时使用:%s", query))
+	}
+	return q, nargs
+}
+
+// 设定单条语句的超时时间
+func (d *DB) withTimeout() (context.Context, context.CancelFunc) {
+	if d.opts.timeout <= 0 {
+		return d.ctx, func() {}
+	}
+	return context.WithTimeout(d.ctx, d.opts.timeout)
+}
+
+// 执行查询并处理结果集(只读模式下在只读事务中执行)
+func (d *DB) query(query string, args []interface{}, fn func(*sql.Rows) error) {
+	query, args = d.prepare(query, args)
+	if d.opts.readOnly {
+		if err := checkReadOnly(query); err != nil {
+			panic(err)
+		}
+	}
+
+	ctx, cancel := d.withTimeout()
+	defer cancel()
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if d.opts.readOnly {
+		tx, terr := d.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if terr != nil {
+			panic(fmt.Errorf("开启只读事务失败:%s ==> %v", query, terr))
+		}
+		defer tx.Rollback()
+		rows, err = tx.QueryContext(ctx, query, args...)
+	} else {
+		rows, err = d.db.QueryContext(ctx, query, args...)
 	}
-	cols, err := rows.Columns() // Remember to check err afterwards
 	if err != nil {
-		panic(fmt.Sprintf("查询失败:%s  %v ==> %v", query, args, err))
+		panic(fmt.Errorf("查询失败:%s  %v ==> %v", query, args, err))
+	}
+	defer rows.Close()
+
+	err = fn(rows)
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		panic(fmt.Errorf("提取数据失败:%s  %v ==> %v", query, args, err))
+	}
+}
+
+// 提取结果集的行(列值转换为字符串)
+func scanRows(rows *sql.Rows, limit int) ([]map[string]interface{}, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
 	}
 	vals := make([]interface{}, len(cols))
 	for i := range cols {
 		vals[i] = new(sql.RawBytes)
 	}
-	defer rows.Close()
+
+	var out []map[string]interface{}
 	for rows.Next() {
 		err = rows.Scan(vals...)
 		if err != nil {
-			panic(fmt.Sprintf("提取数据失败:%s  %v ==> %v", query, args, err))
+			return nil, err
 		}
 		vmap := make(map[string]interface{})
 		for i, col := range cols {
-			var s string
-			rb, ok := vals[i].(*sql.RawBytes)
-			if ok {
-				s = string(*rb)
-			}
-			vmap[col] = s
+			vmap[col] = string(*vals[i].(*sql.RawBytes))
 		}
 		out = append(out, vmap)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
 	}
+	return out, nil
+}
 
+// Query 查询sql返回的所有行
+func (d *DB) Query(query string, args ...interface{}) (out []map[string]interface{}) {
+	d.query(query, args, func(rows *sql.Rows) (err error) {
+		out, err = scanRows(rows, 0)
+		return
+	})
 	return
 }
 
-// QueryDBCount 查询sql匹配的条数
-func QueryDBCount(ctx context.Context, db *sql.DB, query string, args ...interface{}) (count int) {
-
-	query = "select count(*) num from (" + query + ")"
+// One 查询sql返回的第一行，没有数据时返回nil
+func (d *DB) One(query string, args ...interface{}) (out map[string]interface{}) {
+	d.query(query, args, func(rows *sql.Rows) error {
+		items, err := scanRows(rows, 1)
+		if len(items) > 0 {
+			out = items[0]
+		}
+		return err
+	})
+	return
+}
 
-	rows, err := db.QueryContext(ctx, query, args...)
+// Count 查询sql匹配的条数
+func (d *DB) Count(query string, args ...interface{}) (count int) {
+	query = "SELECT COUNT(*) FROM (" + query + ") t"
+	d.query(query, args, func(rows *sql.Rows) error {
+		if rows.Next() {
+			return rows.Scan(&count)
+		}
+		return nil
+	})
+	return
+}
 
-	if err != nil {
-		panic(fmt.Sprintf("查询失败:%s  %v ==> %v", query, args, err))
-	}
+// Scalar 查询sql返回的第一行第一列的值，没有数据时返回nil
+func (d *DB) Scalar(query string, args ...interface{}) (out interface{}) {
+	d.query(query, args, func(rows *sql.Rows) error {
+		if !rows.Next() {
+			return nil
+		}
 
-	defer rows.Close()
-	if rows.Next() {
-		err = rows.Scan(&count)
+		cols, err := rows.Columns()
 		if err != nil {
-			panic(fmt.Sprintf("提取数据失败:%s  %v ==> %v", query, args, err))
+			return err
 		}
-	}
+		vals := make([]interface{}, len(cols))
+		for i := range cols {
+			vals[i] = new(interface{})
+		}
+		err = rows.Scan(vals...)
+		if err != nil {
+			return err
+		}
+
+		out = *vals[0].(*interface{})
+		if b, ok := out.([]byte); ok {
+			out = string(b)
+		}
+		return nil
+	})
 	return
 }
 
-// QueryOneDB 查询sql返回的第一条记录
-func QueryOneDB(ctx context.Context, db *sql.DB, query string, args ...interface{}) (out map[string]interface{}) {
-	// var rows *sql.Rows
-	// var err error
-	if strings.Index(strings.ToLower(query), "limit") < 0 {
-		query = "select * from (" + query + ") limit 1"
+// Exists 检查sql是否有返回的行
+func (d *DB) Exists(query string, args ...interface{}) (exists bool) {
+	d.query(query, args, func(rows *sql.Rows) error {
+		exists = rows.Next()
+		return nil
+	})
+	return
+}
+
+// Exec 执行写操作，返回影响的行数(只读模式下不能使用)
+func (d *DB) Exec(query string, args ...interface{}) int64 {
+	if d.opts.readOnly {
+		panic(fmt.Errorf("只读模式下不能执行写操作:%s", query))
 	}
-	rows, err := db.QueryContext(ctx, query, args...)
+	query, args = d.prepare(query, args)
 
+	ctx, cancel := d.withTimeout()
+	defer cancel()
+
+	result, err := d.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		panic(fmt.Sprintf("查询失败:%s  %v ==> %v", query, args, err))
+		panic(fmt.Errorf("执行失败:%s  %v ==> %v", query, args, err))
 	}
-	cols, err := rows.Columns() // Remember to check err afterwards
+	n, err := result.RowsAffected()
 	if err != nil {
-		panic(fmt.Sprintf("查询失败:%s  %v ==> %v", query, args, err))
-	}
-	vals := make([]interface{}, len(cols))
-	for i := range cols {
-		vals[i] = new(sql.RawBytes)
+		panic(fmt.Errorf("执行失败:%s  %v ==> %v", query, args, err))
 	}
-	defer rows.Close()
-	if rows.Next() {
-		err = rows.Scan(vals...)
-		if err != nil {
-			panic(fmt.Sprintf("提取数据失败:%s  %v ==> %v", query, args, err))
-		}
-		out = make(map[string]interface{})
-		for i, col := range cols {
-			out[col] = vals[i]
+	return n
+}
 
-		}
-	}
+// QueryDB 查询sql返回的所有行
+func QueryDB(ctx context.Context, db *sql.DB, query string, args ...interface{}) []map[string]interface{} {
+	return Use(ctx, db).Query(query, args...)
+}
 
-	return
+// QueryDBCount 查询sql匹配的条数
+func QueryDBCount(ctx context.Context, db *sql.DB, query string, args ...interface{}) int {
+	return Use(ctx, db).Count(query, args...)
+}
+
+// QueryOneDB 查询sql返回的第一条记录
+func QueryOneDB(ctx context.Context, db *sql.DB, query string, args ...interface{}) map[string]interface{} {
+	return Use(ctx, db).One(query, args...)
 }
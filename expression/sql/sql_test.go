@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testDriver struct{}
+
+func (testDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("not supported")
+}
+
+func init() {
+	sql.Register("sqltest", testDriver{})
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqltest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRegOptions(t *testing.T) {
+	defer Reg(nil)
+
+	// 默认为只读模式
+	Reg(nil)
+	if o := currentRegistration().opts.dbOptions; !o.readOnly || o.timeout != 0 {
+		t.Errorf("default options = %+v, want read-only", o)
+	}
+
+	Reg(nil, ReadOnlyOption(false), TimeoutOption(time.Second))
+	if o := currentRegistration().opts.dbOptions; o.readOnly || o.timeout != time.Second {
+		t.Errorf("options = %+v", o)
+	}
+
+	// 重新注册时不保留之前的配置
+	Reg(nil)
+	if o := currentRegistration().opts.dbOptions; !o.readOnly || o.timeout != 0 {
+		t.Errorf("options after Reg = %+v, want reset", o)
+	}
+}
+
+func TestNamedDBOptions(t *testing.T) {
+	defer Reg(nil)
+
+	db := openTestDB(t)
+	RegMoreDB(
+		NamedDBOption("report", db),
+		NamedDBOption("main", db, ReadOnlyOption(false)),
+		TimeoutOption(2*time.Second),
+	)
+
+	ctx := context.Background()
+	if o := use(ctx, "report").opts; !o.readOnly || o.timeout != 2*time.Second {
+		t.Errorf("report options = %+v", o)
+	}
+	if o := use(ctx, "main").opts; o.readOnly || o.timeout != 2*time.Second {
+		t.Errorf("main options = %+v", o)
+	}
+
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			if err == nil || !strings.Contains(err.Error(), "只读模式") {
+				t.Errorf("Exec on read-only db: %v", err)
+			}
+		}()
+		use(ctx, "report").Exec("delete from t")
+	}()
+}
+
+func TestRegConcurrent(t *testing.T) {
+	defer Reg(nil)
+
+	db := openTestDB(t)
+	RegMoreDB(NamedDBOption("main", db))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(readOnly bool) {
+			defer wg.Done()
+			RegMoreDB(NamedDBOption("main", db), ReadOnlyOption(readOnly))
+		}(i%2 == 0)
+		go func() {
+			defer wg.Done()
+			_ = use(context.Background(), "main")
+		}()
+	}
+	wg.Wait()
+}
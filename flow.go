@@ -16,12 +16,12 @@ var (
 
 // Init 初始化流程配置
 func Init(opts ...db.Option) {
-	db, trace, err := db.NewMySQL(opts...)
+	db, dialect, trace, err := db.Open(opts...)
 	if err != nil {
 		panic(err)
 	}
 
-	e, err := new(Engine).Init(NewXMLParser(), NewQLangExecer(), db, trace)
+	e, err := new(Engine).Init(NewXMLParser(), NewQLangExecer(), db, trace, DialectOption(dialect))
 	if err != nil {
 		panic(err)
 	}
@@ -39,11 +39,45 @@ func SetExecer(execer Execer) {
 	engine.SetExecer(execer)
 }
 
+// RegisterExecer 注册表达式执行器(流程可以通过扩展属性execer指定)
+func RegisterExecer(name string, execer Execer) {
+	engine.RegisterExecer(name, execer)
+}
+
 // LoadFile 加载流程文件数据
 func LoadFile(name string) error {
 	return engine.LoadFile(name)
 }
 
+// DeployFlow 部署流程
+func DeployFlow(data []byte, opts DeployOptions) (*schema.FlowDeployment, error) {
+	return engine.DeployFlow(data, opts)
+}
+
+// DeployDecisions 部署DMN文件中的决策表
+func DeployDecisions(data []byte, opts DeployOptions) ([]*schema.Decision, error) {
+	return engine.DeployDecisions(data, opts)
+}
+
+// Decide 执行已部署的决策表
+func Decide(code string, input map[string]interface{}) (interface{}, error) {
+	return engine.Decide(code, input)
+}
+
+// ActivateFlowVersion 启用流程版本
+// code 流程编号
+// version 版本号
+func ActivateFlowVersion(code string, version int64) error {
+	return engine.ActivateFlowVersion(code, version)
+}
+
+// ExportFlow 导出流程定义数据
+// flowID 流程内码
+// format 导出格式(bpmn或json)
+func ExportFlow(flowID string, format Format) ([]byte, error) {
+	return engine.ExportFlow(flowID, format)
+}
+
 // StartFlow 启动流程
 // flowCode 流程编号
 // nodeCode 开始节点编号
@@ -96,6 +130,21 @@ func StopFlowInstance(flowInstanceID string, allowStop func(*schema.FlowInstance
 	return engine.StopFlowInstance(flowInstanceID, allowStop)
 }
 
+// MoveToNode 将流程实例移动到指定节点(仅支持人工任务节点)
+// flowInstanceID 流程实例内码
+// targetNodeCode 目标节点编号(必须是人工任务)
+// candidates 目标节点的候选人(为空时重新计算)
+// reason 操作原因
+// operator 操作人
+func MoveToNode(flowInstanceID, targetNodeCode string, candidates []string, reason, operator string) (*NextNode, error) {
+	return engine.MoveToNode(context.Background(), flowInstanceID, targetNodeCode, candidates, reason, operator)
+}
+
+// ExplainStep 查询节点实例的流转说明
+func ExplainStep(nodeInstanceID string) (*StepExplain, error) {
+	return engine.ExplainStep(nodeInstanceID)
+}
+
 // QueryTodoFlows 查询流程待办数据
 // flowCode 流程编号
 // userID 待办人
@@ -124,6 +173,16 @@ func GetNodeInstance(nodeInstanceID string) (*schema.NodeInstance, error) {
 	return engine.GetNodeInstance(nodeInstanceID)
 }
 
+// GetVariables 获取流程实例变量
+func GetVariables(flowInstanceID string) (map[string]interface{}, error) {
+	return engine.GetVariables(flowInstanceID)
+}
+
+// SetVariables 设定流程实例变量
+func SetVariables(flowInstanceID, operator string, vars map[string]interface{}) error {
+	return engine.SetVariables(flowInstanceID, operator, vars)
+}
+
 // StartServer 启动管理服务
 func StartServer(opts ...ServerOption) http.Handler {
 	srv := new(Server).Init(engine, opts...)
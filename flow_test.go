@@ -1,10 +1,17 @@
 package flow_test
 
 import (
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/antlinker/flow"
+	"github.com/antlinker/flow/bll"
 	"github.com/antlinker/flow/service/db"
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -420,3 +427,233 @@ func TestLeaveRepeatedBack(t *testing.T) {
 		t.Fatalf("无效的下一级流转：%s", result.String())
 	}
 }
+
+func TestDeployFlowMultiple(t *testing.T) {
+	data := []byte(`[
+  {"id": "process_deploy_multi_a", "nodes": [
+    {"id": "start", "type": "startEvent", "routers": [{"target": "end"}]},
+    {"id": "end", "type": "endEvent"}
+  ]},
+  {"id": "process_deploy_multi_b", "nodes": [
+    {"id": "start", "type": "startEvent", "routers": [{"target": "end"}]},
+    {"id": "end", "type": "endEvent"}
+  ]}
+]`)
+
+	_, err := flow.DeployFlow(data, flow.DeployOptions{Activate: true})
+	if err == nil {
+		t.Fatal("部署包含多个流程的数据应返回错误")
+	}
+
+	// 返回错误时不能部署任何流程
+	for _, code := range []string{"process_deploy_multi_a", "process_deploy_multi_b"} {
+		items, err := flow.DefaultEngine().QueryDeployments(code)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if len(items) != 0 {
+			t.Fatalf("流程(%s)不应被部署", code)
+		}
+	}
+}
+
+func TestMigrateInstancesDifferentCode(t *testing.T) {
+	var flowIDs []string
+	for _, code := range []string{"process_migrate_code_a", "process_migrate_code_b"} {
+		data := fmt.Sprintf(`{"id": "%s", "nodes": [
+  {"id": "start", "type": "startEvent", "routers": [{"target": "end"}]},
+  {"id": "end", "type": "endEvent"}
+]}`, code)
+
+		deployment, err := flow.DeployFlow([]byte(data), flow.DeployOptions{Activate: true})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		flowIDs = append(flowIDs, deployment.FlowID)
+	}
+
+	_, err := flow.DefaultEngine().MigrateInstances(flowIDs[0], flowIDs[1], nil, nil, flow.DryRunOption(true))
+	if err == nil {
+		t.Fatal("不同流程编号之间的迁移应返回错误")
+	}
+}
+
+func TestMoveToNodeCancelled(t *testing.T) {
+	var (
+		flowCode = "process_leave_test"
+		bzr      = "T002"
+	)
+
+	input := map[string]interface{}{
+		"day": 1,
+		"bzr": bzr,
+	}
+
+	result, err := flow.StartFlow(flowCode, "node_start", "T001", input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cancelled := result.NextNodes[0].NodeInstance.RecordID
+
+	// 非人工任务节点不能作为目标节点
+	_, err = flow.MoveToNode(result.FlowInstance.RecordID, "node_start", []string{"T003"}, "测试", "T000")
+	if err == nil {
+		t.Fatal("移动到非人工任务节点应返回错误")
+	}
+
+	next, err := flow.MoveToNode(result.FlowInstance.RecordID, "node_user_fdy", []string{"T003"}, "测试", "T000")
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if next.CandidateIDs[0] != "T003" {
+		t.Fatalf("无效的目标节点：%v", next.CandidateIDs)
+	}
+
+	// 已取消的节点实例不能再处理
+	input["action"] = "pass"
+	_, err = flow.HandleFlow(cancelled, bzr, input)
+	if err == nil {
+		t.Fatal("处理已取消的节点实例应返回错误")
+	}
+}
+
+func TestDeployFlowConcurrent(t *testing.T) {
+	code := fmt.Sprintf("process_deploy_concurrent_%d", time.Now().UnixNano())
+	data := []byte(fmt.Sprintf(`{"id": "%s", "nodes": [
+  {"id": "start", "type": "startEvent", "routers": [{"target": "end"}]},
+  {"id": "end", "type": "endEvent"}
+]}`, code))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = flow.DeployFlow(data, flow.DeployOptions{})
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// 并发部署的版本号不能重复
+	items, err := flow.DefaultEngine().QueryDeployments(code)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	versions := make(map[int64]bool)
+	for _, item := range items {
+		versions[item.Version] = true
+	}
+	if len(items) != 4 || len(versions) != 4 {
+		t.Fatalf("无效的部署版本：%d个部署，%d个版本", len(items), len(versions))
+	}
+}
+
+const cacheDecisionTpl = `<?xml version="1.0" encoding="UTF-8"?>
+<definitions xmlns="https://www.omg.org/spec/DMN/20191111/MODEL/" id="cache" name="缓存" namespace="http://camunda.org/schema/1.0/dmn">
+  <decision id="%s" name="缓存">
+    <decisionTable id="cacheTable" hitPolicy="FIRST">
+      <input id="inputAmount"><inputExpression id="inputAmountExp" typeRef="number"><text>amount</text></inputExpression></input>
+      <output id="outputLevel" name="level" typeRef="number" />
+      <rule id="rule1"><inputEntry id="r1i1"><text>-</text></inputEntry><outputEntry id="r1o1"><text>%d</text></outputEntry></rule>
+    </decisionTable>
+  </decision>
+</definitions>`
+
+func TestDecideLatestVersion(t *testing.T) {
+	code := fmt.Sprintf("cacheLevel%d", time.Now().UnixNano())
+	_, err := flow.DeployDecisions([]byte(fmt.Sprintf(cacheDecisionTpl, code, 1)), flow.DeployOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	level, err := flow.Decide(code, map[string]interface{}{"amount": 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if level != 1.0 {
+		t.Fatalf("无效的决策结果：%v", level)
+	}
+
+	// 模拟其他引擎进程部署新版本
+	sqlDB, err := sql.Open("mysql", "root:123456@tcp(127.0.0.1:3306)/flow_test?charset=utf8")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer sqlDB.Close()
+
+	_, err = sqlDB.Exec("INSERT INTO f_decision(record_id,code,name,version,data,checksum,comment,author,created) VALUES(?,?,?,?,?,?,?,?,?)",
+		code+"_2", code, "缓存", 2, fmt.Sprintf(cacheDecisionTpl, code, 2), "", "", "", time.Now().Unix())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	level, err = flow.Decide(code, map[string]interface{}{"amount": 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if level != 2.0 {
+		t.Fatalf("应使用最近部署的版本：%v", level)
+	}
+}
+
+func TestQueryWebFlowsOffloaded(t *testing.T) {
+	var (
+		flowCode = "process_leave_test"
+		bzr      = fmt.Sprintf("T%d", time.Now().UnixNano())
+	)
+
+	dir, err := ioutil.TempDir("", "payload")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// web查询依赖业务系统的流程范围表
+	sqlDB, err := sql.Open("mysql", "root:123456@tcp(127.0.0.1:3306)/flow_test?charset=utf8")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer sqlDB.Close()
+	_, err = sqlDB.Exec("CREATE TABLE IF NOT EXISTS f_flow_range(flow_id VARCHAR(36),user_type INT)")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 所有节点实例的输入数据都转存到外部存储
+	flowBll := flow.DefaultEngine().FlowBll()
+	flowBll.SetPayloadStore(bll.NewFilePayloadStore(dir), 1)
+	defer flowBll.SetPayloadStore(nil, 0)
+
+	input := map[string]interface{}{
+		"day": 1,
+		"bzr": bzr,
+	}
+
+	result, err := flow.StartFlow(flowCode, "node_start", "T001", input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	todos, _, err := flowBll.QueryWebTodoFlowInstanceResult(bzr, "", flowCode, 10, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(todos) != 1 || todos[0].RecordID != result.FlowInstance.RecordID {
+		t.Fatalf("无效的待办数据：%v", todos)
+	}
+
+	input["action"] = "pass"
+	_, err = flow.HandleFlow(result.NextNodes[0].NodeInstance.RecordID, bzr, input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	handled, _, err := flowBll.QueryWebHandleFlowInstanceResult(bzr, "", flowCode, 0, 10, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(handled) != 1 || handled[0].RecordID != result.FlowInstance.RecordID {
+		t.Fatalf("无效的已处理数据：%v", handled)
+	}
+}
@@ -0,0 +1,58 @@
+package flow
+
+import (
+	"encoding/json"
+
+	"github.com/antlinker/flow/schema"
+)
+
+// 表达式中的已完成节点数据(history.节点编号)
+type historyNode struct {
+	NodeInstanceID string      `json:"node_instance_id"` // 节点实例内码
+	NodeName       string      `json:"node_name"`        // 节点名称
+	Processor      string      `json:"processor"`        // 处理人
+	ProcessTime    int64       `json:"process_time"`     // 处理时间(秒时间戳)
+	Output         interface{} `json:"output"`           // 处理时提交的数据
+	Seq            int         `json:"seq"`              // 完成顺序(从1开始)
+	Count          int         `json:"count"`            // 完成次数(退回后重新处理时大于1)
+}
+
+// 表达式中的发起人数据
+type launcherData struct {
+	ID         string `json:"id"`          // 发起人
+	LaunchTime int64  `json:"launch_time"` // 发起时间
+}
+
+// 按节点编号整理已完成的节点实例，同一节点多次完成时保留最后一次
+func newHistory(items []*schema.FlowHistoryResult) map[string]*historyNode {
+	history := make(map[string]*historyNode)
+	for i, item := range items {
+		var output interface{}
+		if item.OutData != "" {
+			json.Unmarshal([]byte(item.OutData), &output)
+		}
+
+		var count int
+		if h, ok := history[item.NodeCode]; ok {
+			count = h.Count
+		}
+
+		history[item.NodeCode] = &historyNode{
+			NodeInstanceID: item.RecordID,
+			NodeName:       item.NodeName,
+			Processor:      item.Processor,
+			ProcessTime:    item.ProcessTime,
+			Output:         output,
+			Seq:            i + 1,
+			Count:          count + 1,
+		}
+	}
+	return history
+}
+
+func newLauncher(flowInstance *schema.FlowInstance) *launcherData {
+	return &launcherData{
+		ID:         flowInstance.Launcher,
+		LaunchTime: flowInstance.LaunchTime,
+	}
+}
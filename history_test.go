@@ -0,0 +1,32 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/antlinker/flow/schema"
+)
+
+func TestNewHistory(t *testing.T) {
+	history := newHistory([]*schema.FlowHistoryResult{
+		{RecordID: "1", NodeCode: "apply", Processor: "u1", OutData: `{"amount":20000}`},
+		{RecordID: "2", NodeCode: "review", Processor: "u2", OutData: `{"approved":false}`},
+		{RecordID: "3", NodeCode: "apply", Processor: "u1", OutData: `{"amount":12000}`},
+		{RecordID: "4", NodeCode: "review", Processor: "u3", OutData: `{"approved":true}`},
+	})
+
+	review := history["review"]
+	if review == nil || review.NodeInstanceID != "4" || review.Processor != "u3" || review.Seq != 4 || review.Count != 2 {
+		t.Fatalf("history[review] = %+v", review)
+	}
+	if output, ok := review.Output.(map[string]interface{}); !ok || output["approved"] != true {
+		t.Errorf("history[review].Output = %v", review.Output)
+	}
+
+	apply := history["apply"]
+	if apply == nil || apply.Seq != 3 || apply.Count != 2 {
+		t.Fatalf("history[apply] = %+v", apply)
+	}
+	if output := apply.Output.(map[string]interface{}); output["amount"] != float64(12000) {
+		t.Errorf("history[apply].Output = %v", apply.Output)
+	}
+}
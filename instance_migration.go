@@ -0,0 +1,233 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/antlinker/flow/util"
+)
+
+// MigrateOption 流程实例迁移配置
+type MigrateOption func(*migrateOptions)
+
+type migrateOptions struct {
+	dryRun   bool
+	operator string
+}
+
+// DryRunOption 仅生成迁移报告，不修改流程实例
+func DryRunOption(dryRun bool) MigrateOption {
+	return func(o *migrateOptions) {
+		o.dryRun = dryRun
+	}
+}
+
+// MigrateOperatorOption 设定迁移操作人(记录到流程实例操作日志)
+func MigrateOperatorOption(operator string) MigrateOption {
+	return func(o *migrateOptions) {
+		o.operator = operator
+	}
+}
+
+// MigrationReport 流程实例迁移报告
+type MigrationReport struct {
+	FromFlowID string               `json:"from_flow_id"` // 源流程内码
+	ToFlowID   string               `json:"to_flow_id"`   // 目标流程内码
+	DryRun     bool                 `json:"dry_run"`      // 是否仅生成报告
+	Instances  []*InstanceMigration `json:"instances"`    // 流程实例迁移明细
+}
+
+// Failed 迁移失败(或校验不通过)的流程实例数
+func (r *MigrationReport) Failed() int {
+	var n int
+	for _, item := range r.Instances {
+		if item.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// InstanceMigration 流程实例迁移明细
+type InstanceMigration struct {
+	FlowInstanceID string           `json:"flow_instance_id"` // 流程实例内码
+	Nodes          []*NodeMigration `json:"nodes"`            // 待处理节点实例的迁移明细
+	Migrated       bool             `json:"migrated"`         // 是否已迁移
+	Error          string           `json:"error,omitempty"`  // 错误信息
+}
+
+// NodeMigration 节点实例迁移明细
+type NodeMigration struct {
+	NodeInstanceID string `json:"node_instance_id"` // 节点实例内码
+	FromNodeID     string `json:"from_node_id"`     // 源节点内码
+	FromNodeCode   string `json:"from_node_code"`   // 源节点编号
+	ToNodeID       string `json:"to_node_id"`       // 目标节点内码
+	ToNodeCode     string `json:"to_node_code"`     // 目标节点编号
+}
+
+// MigrateInstances 将未结束的流程实例迁移到新的流程版本
+// fromFlowID 源流程内码
+// toFlowID 目标流程内码
+// nodeMapping 源节点编号到目标节点编号的映射(未指定的节点按相同的节点编号迁移)
+// filter 过滤需要迁移的流程实例(为空时迁移所有未结束的流程实例)
+// 所有待处理的节点都能找到目标节点时才会执行迁移，否则返回迁移报告及错误
+// 源流程与目标流程的流程编号必须一致；迁移时锁定流程实例并按当前待处理的节点实例重新校验
+func (e *Engine) MigrateInstances(fromFlowID, toFlowID string, nodeMapping map[string]string, filter func(*schema.FlowInstance) bool, opts ...MigrateOption) (*MigrationReport, error) {
+	var o migrateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	fromFlow, err := e.flowBll.GetFlow(fromFlowID)
+	if err != nil {
+		return nil, err
+	} else if fromFlow == nil {
+		return nil, fmt.Errorf("源流程(%s)不存在", fromFlowID)
+	}
+
+	toFlow, err := e.flowBll.GetFlow(toFlowID)
+	if err != nil {
+		return nil, err
+	} else if toFlow == nil {
+		return nil, fmt.Errorf("目标流程(%s)不存在", toFlowID)
+	} else if fromFlow.Code != toFlow.Code {
+		return nil, fmt.Errorf("源流程(%s)与目标流程(%s)的流程编号不一致", fromFlow.Code, toFlow.Code)
+	}
+
+	fromNodes, err := e.flowBll.QueryNodesByFlowID(fromFlowID)
+	if err != nil {
+		return nil, err
+	}
+
+	toNodes, err := e.flowBll.QueryNodesByFlowID(toFlowID)
+	if err != nil {
+		return nil, err
+	}
+
+	fromNodeMap := make(map[string]*schema.Node)
+	for _, node := range fromNodes {
+		fromNodeMap[node.RecordID] = node
+	}
+
+	toNodeMap := make(map[string]*schema.Node)
+	for _, node := range toNodes {
+		toNodeMap[node.Code] = node
+	}
+
+	// 映射待处理节点实例的目标节点
+	mapNodes := func(item *InstanceMigration, nodeInstances []*schema.NodeInstance) error {
+		item.Nodes = nil
+		for _, ni := range nodeInstances {
+			nm := &NodeMigration{
+				NodeInstanceID: ni.RecordID,
+				FromNodeID:     ni.NodeID,
+			}
+			item.Nodes = append(item.Nodes, nm)
+
+			fromNode, ok := fromNodeMap[ni.NodeID]
+			if !ok {
+				return fmt.Errorf("节点实例(%s)的源节点不存在", ni.RecordID)
+			}
+			nm.FromNodeCode = fromNode.Code
+
+			code := fromNode.Code
+			if v, ok := nodeMapping[code]; ok {
+				code = v
+			}
+
+			toNode, ok := toNodeMap[code]
+			if !ok {
+				return fmt.Errorf("节点(%s)在目标流程中没有对应的节点", fromNode.Code)
+			}
+			nm.ToNodeID = toNode.RecordID
+			nm.ToNodeCode = toNode.Code
+		}
+		return nil
+	}
+
+	flowInstances, err := e.flowBll.QueryRunningFlowInstances(fromFlowID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MigrationReport{
+		FromFlowID: fromFlowID,
+		ToFlowID:   toFlowID,
+		DryRun:     o.dryRun,
+	}
+
+	for _, flowInstance := range flowInstances {
+		if filter != nil && !filter(flowInstance) {
+			continue
+		}
+
+		item := &InstanceMigration{FlowInstanceID: flowInstance.RecordID}
+		report.Instances = append(report.Instances, item)
+
+		nodeInstances, err := e.flowBll.QueryTodoNodeInstances(flowInstance.RecordID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mapNodes(item, nodeInstances); err != nil {
+			item.Error = err.Error()
+		}
+	}
+
+	if n := report.Failed(); n > 0 {
+		if o.dryRun {
+			return report, nil
+		}
+		return report, fmt.Errorf("存在%d个流程实例无法迁移", n)
+	} else if o.dryRun {
+		return report, nil
+	}
+
+	for _, item := range report.Instances {
+		// 在事物中锁定流程实例后，按当前待处理的节点实例重新映射
+		err = e.flowBll.MigrateFlowInstance(item.FlowInstanceID, fromFlowID, toFlowID, func(nodeInstances []*schema.NodeInstance) (map[string]string, *schema.InstanceLog, error) {
+			if err := mapNodes(item, nodeInstances); err != nil {
+				return nil, nil, err
+			}
+
+			nodes := make(map[string]string)
+			for _, nm := range item.Nodes {
+				nodes[nm.NodeInstanceID] = nm.ToNodeID
+			}
+
+			data, _ := json.Marshal(map[string]interface{}{
+				"from_flow_id": fromFlowID,
+				"to_flow_id":   toFlowID,
+				"nodes":        item.Nodes,
+			})
+
+			log := &schema.InstanceLog{
+				RecordID:       util.UUID(),
+				FlowInstanceID: item.FlowInstanceID,
+				Action:         "migrate",
+				Operator:       o.operator,
+				Data:           string(data),
+				Created:        time.Now().Unix(),
+			}
+			return nodes, log, nil
+		})
+		if err != nil {
+			item.Error = err.Error()
+			e.errorf("%+v", err)
+			continue
+		}
+		item.Migrated = true
+	}
+
+	if n := report.Failed(); n > 0 {
+		return report, fmt.Errorf("存在%d个流程实例迁移失败", n)
+	}
+	return report, nil
+}
+
+// QueryInstanceLogs 查询流程实例操作日志(例如版本迁移记录)
+func (e *Engine) QueryInstanceLogs(flowInstanceID string) ([]*schema.InstanceLog, error) {
+	return e.flowBll.QueryInstanceLogs(flowInstanceID)
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/pkg/errors"
+)
+
+// CreateDecision 创建决策表部署记录
+func (a *Flow) CreateDecision(item *schema.Decision) error {
+	err := a.DB.Insert(item)
+	if err != nil {
+		return errors.Wrapf(err, "创建决策表部署记录发生错误")
+	}
+	return nil
+}
+
+// GetLatestDecisionVersion 获取决策表编号最近部署的版本号(未部署时返回0)
+func (a *Flow) GetLatestDecisionVersion(code string) (int64, error) {
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version),0) FROM %s WHERE code=?", schema.DecisionTableName)
+
+	version, err := a.DB.SelectInt(query, code)
+	if err != nil {
+		return 0, errors.Wrapf(err, "获取决策表最近的版本号发生错误")
+	}
+	return version, nil
+}
+
+// GetLatestDecision 获取决策表编号最近的部署记录
+func (a *Flow) GetLatestDecision(code string) (*schema.Decision, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE code=? ORDER BY id DESC LIMIT 1", schema.DecisionTableName)
+
+	var item schema.Decision
+	err := a.DB.SelectOne(&item, query, code)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "获取决策表最近的部署记录发生错误")
+	}
+
+	return &item, nil
+}
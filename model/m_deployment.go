@@ -0,0 +1,124 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/pkg/errors"
+)
+
+// DeployFlow 部署流程(流程数据及部署记录在同一事物中保存，flow.Activated为1时启用该版本)
+func (a *Flow) DeployFlow(flow *schema.Flow, nodes *schema.NodeOperating, forms *schema.FormOperating, deployment *schema.FlowDeployment) error {
+	tran, err := a.DB.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "部署流程开启事物发生错误")
+	}
+
+	err = a.insertFlow(tran, flow, nodes, forms)
+	if err != nil {
+		_ = tran.Rollback()
+		return err
+	}
+
+	err = tran.Insert(deployment)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "插入流程部署记录发生错误")
+	}
+
+	err = tran.Commit()
+	if err != nil {
+		return errors.Wrapf(err, "部署流程提交事物发生错误")
+	}
+	return nil
+}
+
+// ActivateFlow 启用流程版本(同一流程编号的其它版本将被停用)
+func (a *Flow) ActivateFlow(code, recordID string) error {
+	tran, err := a.DB.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "启用流程版本开启事物发生错误")
+	}
+
+	now := time.Now().Unix()
+	query := fmt.Sprintf("UPDATE %s SET activated=0,updated=? WHERE code=? AND activated=1", schema.FlowTableName)
+	_, err = tran.Exec(query, now, code)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "停用流程版本发生错误")
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET activated=1,updated=? WHERE record_id=?", schema.FlowTableName)
+	_, err = tran.Exec(query, now, recordID)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "启用流程版本发生错误")
+	}
+
+	err = tran.Commit()
+	if err != nil {
+		return errors.Wrapf(err, "启用流程版本提交事物发生错误")
+	}
+	return nil
+}
+
+// GetFlowByCodeAndVersion 根据流程编号及版本号获取流程数据
+func (a *Flow) GetFlowByCodeAndVersion(code string, version int64) (*schema.Flow, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND code=? AND version=? LIMIT 1", schema.FlowTableName)
+
+	var flow schema.Flow
+	err := a.DB.SelectOne(&flow, query, code, version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "根据流程编号及版本号获取流程数据发生错误")
+	}
+
+	return &flow, nil
+}
+
+// GetMaxFlowVersion 获取流程编号已部署的最大版本号
+func (a *Flow) GetMaxFlowVersion(code string) (int64, error) {
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version),0) FROM %s WHERE deleted=0 AND code=?", schema.FlowTableName)
+
+	version, err := a.DB.SelectInt(query, code)
+	if err != nil {
+		return 0, errors.Wrapf(err, "获取流程最大版本号发生错误")
+	}
+	return version, nil
+}
+
+// QueryDeployments 查询流程部署记录
+func (a *Flow) QueryDeployments(code string) ([]*schema.FlowDeploymentResult, error) {
+	query := fmt.Sprintf(`SELECT d.record_id,d.flow_id,d.flow_code,f.name AS flow_name,d.version,d.checksum,d.comment,d.author,f.activated,d.created
+		FROM %s d JOIN %s f ON d.flow_id=f.record_id
+		WHERE f.deleted=0 AND d.flow_code=?
+		ORDER BY d.id DESC`, schema.DeploymentTableName, schema.FlowTableName)
+
+	var items []*schema.FlowDeploymentResult
+	_, err := a.DB.Select(&items, query, code)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询流程部署记录发生错误")
+	}
+
+	return items, nil
+}
+
+// GetLatestDeployment 获取流程编号最近的部署记录
+func (a *Flow) GetLatestDeployment(code string) (*schema.FlowDeployment, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE flow_code=? ORDER BY id DESC LIMIT 1", schema.DeploymentTableName)
+
+	var item schema.FlowDeployment
+	err := a.DB.SelectOne(&item, query, code)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "获取流程最近的部署记录发生错误")
+	}
+
+	return &item, nil
+}
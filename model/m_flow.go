@@ -24,16 +24,37 @@ func (a *Flow) CreateFlow(flow *schema.Flow, nodes *schema.NodeOperating, forms
 		return errors.Wrapf(err, "创建流程基础数据开启事物发生错误")
 	}
 
-	err = tran.Insert(flow)
+	err = a.insertFlow(tran, flow, nodes, forms)
 	if err != nil {
 		_ = tran.Rollback()
+		return err
+	}
+
+	err = tran.Commit()
+	if err != nil {
+		return errors.Wrapf(err, "创建流程基础数据提交事物发生错误")
+	}
+	return nil
+}
+
+// 插入流程、节点及表单数据(flow.Activated为1时停用同一流程编号的其它版本)
+func (a *Flow) insertFlow(tran *db.Tx, flow *schema.Flow, nodes *schema.NodeOperating, forms *schema.FormOperating) error {
+	if flow.Activated == 1 {
+		query := fmt.Sprintf("UPDATE %s SET activated=0,updated=? WHERE code=? AND activated=1", schema.FlowTableName)
+		_, err := tran.Exec(query, time.Now().Unix(), flow.Code)
+		if err != nil {
+			return errors.Wrapf(err, "停用流程版本发生错误")
+		}
+	}
+
+	err := tran.Insert(flow)
+	if err != nil {
 		return errors.Wrapf(err, "插入流程数据发生错误")
 	}
 
 	if list := nodes.All(); len(list) > 0 {
 		err = tran.Insert(list...)
 		if err != nil {
-			_ = tran.Rollback()
 			return errors.Wrapf(err, "插入节点数据发生错误")
 		}
 	}
@@ -41,15 +62,9 @@ func (a *Flow) CreateFlow(flow *schema.Flow, nodes *schema.NodeOperating, forms
 	if list := forms.All(); len(list) > 0 {
 		err = tran.Insert(list...)
 		if err != nil {
-			_ = tran.Rollback()
 			return errors.Wrapf(err, "插入表单数据发生错误")
 		}
 	}
-
-	err = tran.Commit()
-	if err != nil {
-		return errors.Wrapf(err, "创建流程基础数据提交事物发生错误")
-	}
 	return nil
 }
 
@@ -69,9 +84,9 @@ func (a *Flow) GetFlow(recordID string) (*schema.Flow, error) {
 	return &flow, nil
 }
 
-// GetFlowByCode 根据编号查询流程数据
+// GetFlowByCode 根据编号查询启用版本的流程数据
 func (a *Flow) GetFlowByCode(code string) (*schema.Flow, error) {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND flag=1 AND status=1 AND code=? ORDER BY version DESC LIMIT 1", schema.FlowTableName)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND flag=1 AND status=1 AND activated=1 AND code=? ORDER BY version DESC LIMIT 1", schema.FlowTableName)
 
 	var flow schema.Flow
 	err := a.DB.SelectOne(&flow, query, code)
@@ -87,7 +102,7 @@ func (a *Flow) GetFlowByCode(code string) (*schema.Flow, error) {
 
 // QueryFlowByCode 根据流程编号查询流程数据
 func (a *Flow) QueryFlowByCode(flowCode string) ([]*schema.Flow, error) {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND flag=1 AND status=1 AND code=? ORDER BY version DESC", schema.FlowTableName)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND flag=1 AND status=1 AND code=? ORDER BY activated DESC,version DESC", schema.FlowTableName)
 
 	var items []*schema.Flow
 	_, err := a.DB.Select(&items, query, flowCode)
@@ -213,20 +228,14 @@ func (a *Flow) CreateNodeInstance(nodeInstance *schema.NodeInstance, nodeCandida
 
 	err = tran.Insert(nodeInstance)
 	if err != nil {
-		err = tran.Rollback()
-		if err != nil {
-			return errors.Wrapf(err, "创建流程节点实例回滚事物发生错误")
-		}
+		_ = tran.Rollback()
 		return errors.Wrapf(err, "插入流程节点实例数据发生错误")
 	}
 
 	for _, c := range nodeCandidates {
 		err = tran.Insert(c)
 		if err != nil {
-			err = tran.Rollback()
-			if err != nil {
-				return errors.Wrapf(err, "创建流程节点实例回滚事物发生错误")
-			}
+			_ = tran.Rollback()
 			return errors.Wrapf(err, "插入流程节点候选人数据发生错误")
 		}
 	}
@@ -247,6 +256,15 @@ func (a *Flow) UpdateNodeInstance(recordID string, info map[string]interface{})
 	return nil
 }
 
+// DoneNodeInstance 完成待处理的节点实例(节点实例已处理或已取消时返回false)
+func (a *Flow) DoneNodeInstance(recordID string, info map[string]interface{}) (bool, error) {
+	n, err := a.DB.UpdateByPK(schema.NodeInstanceTableName, db.M{"record_id": recordID, "status": 1}, db.M(info))
+	if err != nil {
+		return false, errors.Wrapf(err, "完成节点实例发生错误")
+	}
+	return n > 0, nil
+}
+
 // CheckFlowInstanceTodo 检查流程实例待办事项
 func (a *Flow) CheckFlowInstanceTodo(flowInstanceID string) (bool, error) {
 	query := fmt.Sprintf("SELECT count(*) FROM %s WHERE deleted=0 AND status=1 AND flow_instance_id=?", schema.NodeInstanceTableName)
@@ -275,20 +293,14 @@ func (a *Flow) CreateFlowInstance(flowInstance *schema.FlowInstance, nodeInstanc
 
 	err = tran.Insert(flowInstance)
 	if err != nil {
-		err = tran.Rollback()
-		if err != nil {
-			return errors.Wrapf(err, "创建流程实例回滚事物发生错误")
-		}
+		_ = tran.Rollback()
 		return errors.Wrapf(err, "插入流程实例数据发生错误")
 	}
 
 	for _, n := range nodeInstances {
 		err = tran.Insert(n)
 		if err != nil {
-			err = tran.Rollback()
-			if err != nil {
-				return errors.Wrapf(err, "创建流程实例回滚事物发生错误")
-			}
+			_ = tran.Rollback()
 			return errors.Wrapf(err, "插入流程节点实例数据发生错误")
 		}
 	}
@@ -333,14 +345,15 @@ func (a *Flow) QueryTodo(typeCode, flowCode, userID string, count int) ([]*schem
 		  ni.record_id,
 		  ni.flow_instance_id,
 		  ni.input_data,
+		  ni.input_ref,
 		  ni.node_id,
-		  f.data 'form_data',
-		  f.type_code 'form_type',
+		  f.data AS form_data,
+		  f.type_code AS form_type,
 		  fi.launcher,
 		  fi.launch_time,
-			n.code 'node_code',
-			n.name 'node_name',
-			fw.name 'flow_name'
+			n.code AS node_code,
+			n.name AS node_name,
+			fw.name AS flow_name
 		FROM %s ni
 		  JOIN %s fi ON ni.flow_instance_id = fi.record_id AND fi.deleted = ni.deleted
 		  LEFT JOIN %s n ON ni.node_id = n.record_id AND n.deleted = ni.deleted
@@ -374,14 +387,15 @@ func (a *Flow) GetTodoByID(nodeInstanceID string) (*schema.FlowTodoResult, error
 		  ni.record_id,
 		  ni.flow_instance_id,
 		  ni.input_data,
+		  ni.input_ref,
 		  ni.node_id,
-		  f.data 'form_data',
-		  f.type_code 'form_type',
+		  f.data AS form_data,
+		  f.type_code AS form_type,
 		  fi.launcher,
 		  fi.launch_time,
-			n.code 'node_code',
-			n.name 'node_name',
-			fw.name 'flow_name'
+			n.code AS node_code,
+			n.name AS node_name,
+			fw.name AS flow_name
 		FROM %s ni
 		  JOIN %s fi ON ni.flow_instance_id = fi.record_id AND fi.deleted = ni.deleted
 		  LEFT JOIN %s n ON ni.node_id = n.record_id AND n.deleted = ni.deleted
@@ -413,14 +427,14 @@ func (a *Flow) GetDoneByID(nodeInstanceID string) (*schema.FlowDoneResult, error
 	ni.flow_instance_id,
 	ni.out_data,
 	ni.process_time,
-	f.data 'form_data',
-	f.type_code 'form_type',
-	fi.status 'flow_status',
+	f.data AS form_data,
+	f.type_code AS form_type,
+	fi.status AS flow_status,
 	fi.launcher,
 	fi.launch_time,
-	n.record_id 'node_id',
-	n.name 'node_name',
-	fw.name 'flow_name'`
+	n.record_id AS node_id,
+	n.name AS node_name,
+	fw.name AS flow_name`
 
 	query := fmt.Sprintf("SELECT %s FROM %s %s", fieldsSelect, table, where)
 
@@ -462,14 +476,14 @@ func (a *Flow) QueryDone(typeCode, flowCode, userID string, lastTime int64, coun
 	ni.flow_instance_id,
 	ni.out_data,
 	ni.process_time,
-	f.data 'form_data',
-	f.type_code 'form_type',
-	fi.status 'flow_status',
+	f.data AS form_data,
+	f.type_code AS form_type,
+	fi.status AS flow_status,
 	fi.launcher,
 	fi.launch_time,
-	n.record_id 'node_id',
-	n.name 'node_name',
-	fw.name 'flow_name'`
+	n.record_id AS node_id,
+	n.name AS node_name,
+	fw.name AS flow_name`
 
 	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY ni.process_time DESC LIMIT %d", fieldsSelect, table, where, count)
 
@@ -536,6 +550,12 @@ func (a *Flow) DeleteFlow(flowID string) error {
 		return errors.Wrapf(err, "删除流程表单发生错误")
 	}
 
+	_, err = tran.Exec(fmt.Sprintf("UPDATE %s SET deleted=? WHERE deleted=0 AND flow_id=?", schema.FlowPropertyTableName), ctimeUnix, flowID)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "删除流程扩展属性发生错误")
+	}
+
 	err = tran.Commit()
 	if err != nil {
 		return errors.Wrapf(err, "删除流程提交事物发生错误")
@@ -551,13 +571,14 @@ func (a *Flow) QueryHistory(flowInstanceID string) ([]*schema.FlowHistoryResult,
 		ni.processor,
 		ni.process_time,
 		ni.input_data,
+		ni.input_ref,
 		ni.out_data,
 		ni.status,
-		n.record_id 'node_id',
-		n.code 'node_code',
-		n.name 'node_name',
-		f.data 'form_data',
-		f.type_code 'form_type'
+		n.record_id AS node_id,
+		n.code AS node_code,
+		n.name AS node_name,
+		f.data AS form_data,
+		f.type_code AS form_type
 		FROM %s ni JOIN %s n ON ni.node_id=n.record_id AND n.deleted=ni.deleted
 		LEFT JOIN %s f ON n.form_id = f.record_id AND f.deleted = n.deleted
 		WHERE ni.deleted=0 AND ni.flow_instance_id=? AND n.type_code='userTask'
@@ -613,7 +634,7 @@ func (a *Flow) QueryFlowIDsByType(typeCodes ...string) ([]string, error) {
 
 // QueryFlowByIDs 根据流程ID查询流程数据
 func (a *Flow) QueryFlowByIDs(flowIDs []string) ([]*schema.FlowQueryResult, error) {
-	query := fmt.Sprintf("SELECT code,MAX(version)'version' FROM %s WHERE deleted=0 AND flag=1 AND status=1 AND record_id IN(?)  GROUP BY code ORDER BY code", schema.FlowTableName)
+	query := fmt.Sprintf("SELECT code,MAX(version) AS version FROM %s WHERE deleted=0 AND flag=1 AND status=1 AND record_id IN(?)  GROUP BY code ORDER BY code", schema.FlowTableName)
 
 	query, args, err := a.DB.In(query, flowIDs)
 	if err != nil {
@@ -736,6 +757,32 @@ func (a *Flow) QueryNodeProperty(nodeID string) ([]*schema.NodeProperty, error)
 	return items, nil
 }
 
+// QueryFlowProperties 查询流程扩展属性
+func (a *Flow) QueryFlowProperties(flowID string) ([]*schema.FlowProperty, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND flow_id=? ORDER BY id", schema.FlowPropertyTableName)
+
+	var items []*schema.FlowProperty
+	_, err := a.DB.Select(&items, query, flowID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询流程扩展属性发生错误")
+	}
+
+	return items, nil
+}
+
+// QueryNodesByFlowID 查询流程的所有节点(按创建顺序)
+func (a *Flow) QueryNodesByFlowID(flowID string) ([]*schema.Node, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND flow_id=? ORDER BY id", schema.NodeTableName)
+
+	var items []*schema.Node
+	_, err := a.DB.Select(&items, query, flowID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询流程节点发生错误")
+	}
+
+	return items, nil
+}
+
 // CreateNodeTiming 创建定时节点
 func (a *Flow) CreateNodeTiming(item *schema.NodeTiming) error {
 	err := a.DB.Insert(item)
@@ -769,7 +816,7 @@ func (a *Flow) QueryExpiredNodeTiming() ([]*schema.NodeTiming, error) {
 // QueryLaunchFlowInstanceResult 查询发起的流程实例数据
 func (a *Flow) QueryLaunchFlowInstanceResult(launcher, typeCode, flowCode string, lastID int64, count int) ([]*schema.FlowInstanceResult, error) {
 	var args []interface{}
-	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code 'flow_code',f.name 'flow_name' FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0", schema.FlowInstanceTableName, schema.FlowTableName)
+	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code AS flow_code,f.name AS flow_name FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0", schema.FlowInstanceTableName, schema.FlowTableName)
 	query = fmt.Sprintf("%s AND fi.launcher=?", query)
 	args = append(args, launcher)
 
@@ -801,7 +848,7 @@ func (a *Flow) QueryLaunchFlowInstanceResult(launcher, typeCode, flowCode string
 // QueryTodoFlowInstanceResult 查询待办的流程实例数据
 func (a *Flow) QueryTodoFlowInstanceResult(userID, typeCode, flowCode string, lastID int64, count int) ([]*schema.FlowInstanceResult, error) {
 	var args []interface{}
-	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code 'flow_code',f.name 'flow_name' FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0 AND fi.status = 1", schema.FlowInstanceTableName, schema.FlowTableName)
+	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code AS flow_code,f.name AS flow_name FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0 AND fi.status = 1", schema.FlowInstanceTableName, schema.FlowTableName)
 	// query = fmt.Sprintf("%s AND fi.launcher!=?", query)
 	// args = append(args, userID)
 	query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=1 AND record_id IN(SELECT node_instance_id FROM %s WHERE deleted=0 AND candidate_id=?))", query, schema.NodeInstanceTableName, schema.NodeCandidateTableName)
@@ -835,7 +882,7 @@ func (a *Flow) QueryTodoFlowInstanceResult(userID, typeCode, flowCode string, la
 // QueryHandleFlowInstanceResult 查询处理的流程实例结果
 func (a *Flow) QueryHandleFlowInstanceResult(processor, typeCode, flowCode string, lastID int64, count int) ([]*schema.FlowInstanceResult, error) {
 	var args []interface{}
-	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code 'flow_code',f.name 'flow_name' FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0", schema.FlowInstanceTableName, schema.FlowTableName)
+	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code AS flow_code,f.name AS flow_name FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0", schema.FlowInstanceTableName, schema.FlowTableName)
 	query = fmt.Sprintf("%s AND fi.launcher!=?", query)
 	args = append(args, processor)
 	query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=2 AND processor=?)", query, schema.NodeInstanceTableName)
@@ -869,7 +916,7 @@ func (a *Flow) QueryHandleFlowInstanceResult(processor, typeCode, flowCode strin
 // QueryLastNodeInstances 查询流程实例的最后一个节点实例
 func (a *Flow) QueryLastNodeInstances(flowInstanceIDs []string) ([]*schema.NodeInstance, error) {
 	errMsg := "查询流程实例的最后一个节点实例发生错误"
-	query := fmt.Sprintf("SELECT MAX(id)'id' FROM %s WHERE deleted=0 AND flow_instance_id IN(?) GROUP BY flow_instance_id", schema.NodeInstanceTableName)
+	query := fmt.Sprintf("SELECT MAX(id) AS id FROM %s WHERE deleted=0 AND flow_instance_id IN(?) GROUP BY flow_instance_id", schema.NodeInstanceTableName)
 	query, args, err := a.DB.In(query, flowInstanceIDs)
 	if err != nil {
 		return nil, errors.Wrapf(err, errMsg)
@@ -950,6 +997,11 @@ func (a *Flow) QueryAllFlowPage(params schema.FlowQueryParam, pageIndex, pageSiz
 		args = append(args, v)
 	}
 
+	for name, value := range params.Properties {
+		where = fmt.Sprintf("%s AND EXISTS(SELECT 1 FROM %s WHERE deleted=0 AND flow_id=%s.record_id AND name=? AND value=?)", where, schema.FlowPropertyTableName, schema.FlowTableName)
+		args = append(args, name, value)
+	}
+
 	n, err := a.DB.SelectInt(fmt.Sprintf("SELECT count(*) FROM %s %s", schema.FlowTableName, where), args...)
 	if err != nil {
 		return 0, nil, errors.Wrapf(err, "查询分页数据发生错误")
@@ -957,9 +1009,9 @@ func (a *Flow) QueryAllFlowPage(params schema.FlowQueryParam, pageIndex, pageSiz
 		return 0, nil, nil
 	}
 
-	query := fmt.Sprintf("SELECT id,record_id,created,code,name,version FROM %s %s ORDER BY id DESC", schema.FlowTableName, where)
+	query := fmt.Sprintf("SELECT id,record_id,created,code,name,version,type_code,status,memo FROM %s %s ORDER BY id DESC", schema.FlowTableName, where)
 	if pageIndex > 0 && pageSize > 0 {
-		query = fmt.Sprintf("%s limit %d,%d", query, (pageIndex-1)*pageSize, pageSize)
+		query = fmt.Sprintf("%s LIMIT %d OFFSET %d", query, pageSize, (pageIndex-1)*pageSize)
 	}
 
 	var items []*schema.FlowQueryResult
@@ -998,7 +1050,7 @@ func (a *Flow) QueryGroupFlowPage(params schema.FlowQueryParam, pageIndex, pageS
 		args = append(args, v)
 	}
 
-	query := fmt.Sprintf("SELECT code,MAX(version)'version' FROM %s %s GROUP BY code ORDER BY code", schema.FlowTableName, where)
+	query := fmt.Sprintf("SELECT code,MAX(version) AS version FROM %s %s GROUP BY code ORDER BY code", schema.FlowTableName, where)
 
 	var items []*schema.Flow
 	_, err := a.DB.Select(&items, query, args...)
@@ -1080,23 +1132,35 @@ func (a *Flow) QueryFlowVersion(code string) ([]*schema.FlowQueryResult, error)
 }
 
 
+// 排除指定标题及状态的节点实例的条件(输入数据已转存到外部存储的节点实例无法按输入数据判断，不排除)
+func (a *Flow) excludeTitleCond(cond string) string {
+	title := a.DB.JSONField("input_data", "title")
+	excludeCond := fmt.Sprintf("%[1]s != '班干部学生状态修改' AND %[1]s != '学生调班申请' AND %[1]s != '状态调整'", title)
+	if cond != "" {
+		excludeCond = fmt.Sprintf("%s AND %s", excludeCond, cond)
+	}
+	return fmt.Sprintf("(input_ref != '' OR (%s))", excludeCond)
+}
+
 // QueryTodoWebFlowInstanceResult web查询待办的流程实例数据
 func (a *Flow) QueryTodoWebFlowInstanceResult(userID, typeCode, flowCode string, count int,ParamSearchList map[string]string) ([]*schema.FlowWebInstanceResult, int64,error) {
 	var args []interface{}
-	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code 'flow_code',f.name 'flow_name' FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0 AND fi.status = 1 AND f.record_id NOT IN (select flow_id from f_flow_range where user_type=0 or user_type=2)", schema.FlowInstanceTableName, schema.FlowTableName)
+	statusCond := a.DB.JSONNumberCond("input_data", "status", "!=", 2) + " AND " + a.DB.JSONNumberCond("input_data", "status", "!=", 3)
+	excludeCond := a.excludeTitleCond(statusCond)
+	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code AS flow_code,f.name AS flow_name FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0 AND fi.status = 1 AND f.record_id NOT IN (select flow_id from f_flow_range where user_type=0 or user_type=2)", schema.FlowInstanceTableName, schema.FlowTableName)
 	//最后的更改
 	if ParamSearchList != nil && len(ParamSearchList) > 0  {
-		tmpSql := `  AND input_data->'$.title' != '班干部学生状态修改' AND input_data->'$.title' != '学生调班申请'   AND input_data->'$.title' != '状态调整'  `
+		tmpSql := ""
 		for i, v := range ParamSearchList {
 			if i == "page"{
 				continue
 			}
-			tmpSql += ` AND input_data->'$.` + i + `' = ? `
+			tmpSql += ` AND ` + a.DB.JSONField("input_data", i) + ` = ? `
 			args = append(args, v)
 		}
-		query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=1 %s AND input_data->'$.status' !=2  AND input_data->'$.status' !=3 AND record_id IN(SELECT node_instance_id FROM %s WHERE deleted=0 AND candidate_id=?))", query, schema.NodeInstanceTableName, tmpSql,schema.NodeCandidateTableName)
+		query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=1 AND %s %s AND record_id IN(SELECT node_instance_id FROM %s WHERE deleted=0 AND candidate_id=?))", query, schema.NodeInstanceTableName, excludeCond, tmpSql, schema.NodeCandidateTableName)
 	}else{
-		query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=1  AND input_data->'$.title' != '状态调整'  AND input_data->'$.status' !=2 AND input_data->'$.status' !=3  AND input_data->'$.title' != '班干部学生状态修改' AND input_data->'$.title' != '学生调班申请' AND record_id IN(SELECT node_instance_id FROM %s WHERE deleted=0 AND candidate_id=?))", query, schema.NodeInstanceTableName, schema.NodeCandidateTableName)
+		query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=1 AND %s AND record_id IN(SELECT node_instance_id FROM %s WHERE deleted=0 AND candidate_id=?))", query, schema.NodeInstanceTableName, excludeCond, schema.NodeCandidateTableName)
 	}
 	args = append(args, userID)
 
@@ -1108,7 +1172,7 @@ func (a *Flow) QueryTodoWebFlowInstanceResult(userID, typeCode, flowCode string,
 		args = append(args, flowCode)
 	}
 	//获取信息条数
-	tmpSql := `SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code 'flow_code',f.name 'flow_name'`
+	tmpSql := `SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code AS flow_code,f.name AS flow_name`
 	num := a.GetWebFlowNumber(tmpSql,query,args )
 
 	if v,ok := ParamSearchList["page"];ok {
@@ -1134,21 +1198,22 @@ func (a *Flow) QueryWebHandleFlowInstanceResult(processor, typeCode, flowCode st
 		args 	[]interface{}
 
 	)
-	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code 'flow_code',f.name 'flow_name' FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0 AND f.record_id NOT IN (select flow_id from f_flow_range where user_type=0 or user_type=2) ", schema.FlowInstanceTableName, schema.FlowTableName)
+	query := fmt.Sprintf("SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code AS flow_code,f.name AS flow_name FROM %s fi LEFT JOIN %s f ON fi.flow_id=f.record_id AND f.deleted=0 WHERE fi.deleted=0 AND f.record_id NOT IN (select flow_id from f_flow_range where user_type=0 or user_type=2) ", schema.FlowInstanceTableName, schema.FlowTableName)
 	query = fmt.Sprintf("%s AND fi.launcher!=?", query)
 	args = append(args, processor)
+	excludeCond := a.excludeTitleCond("")
 	if ParamSearchList != nil && len(ParamSearchList) > 0 {
-		tmpSql := ` AND input_data->'$.title' != '班干部学生状态修改' AND input_data->'$.title' != '学生调班申请' AND input_data->'$.title' != '状态调整' `
+		tmpSql := ""
 		for i, v := range ParamSearchList {
 			if i == "page"{
 				continue
 			}
-			tmpSql += ` AND input_data->'$.` + i + `' = ? `
+			tmpSql += ` AND ` + a.DB.JSONField("input_data", i) + ` = ? `
 			args = append(args, v)
 		}
-		query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=2 %s AND processor=?)", query, schema.NodeInstanceTableName,tmpSql)
+		query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=2 AND %s %s AND processor=?)", query, schema.NodeInstanceTableName, excludeCond, tmpSql)
 	}else  {
-		query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=2   AND input_data->'$.title' != '状态调整'  AND  input_data->'$.title' != '班干部学生状态修改' AND input_data->'$.title' != '学生调班申请' AND processor=?)", query, schema.NodeInstanceTableName)
+		query = fmt.Sprintf("%s AND fi.record_id IN(SELECT flow_instance_id FROM %s WHERE deleted=0 AND status=2 AND %s AND processor=?)", query, schema.NodeInstanceTableName, excludeCond)
 
 	}
 	args = append(args, processor)
@@ -1160,7 +1225,7 @@ func (a *Flow) QueryWebHandleFlowInstanceResult(processor, typeCode, flowCode st
 		query = fmt.Sprintf("%s AND f.code=?", query)
 		args = append(args, flowCode)
 	}
-	tmpSql:=`SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code 'flow_code',f.name 'flow_name'`
+	tmpSql:=`SELECT fi.id,fi.record_id,fi.flow_id,fi.status,fi.launcher,fi.launch_time,f.code AS flow_code,f.name AS flow_name`
 	//获取信息条数
 	num := a.GetWebFlowNumber(tmpSql,query,args )
 
@@ -1189,7 +1254,7 @@ func (a *Flow) QueryWebLastNodeInstances(flowInstanceIDs []string,ParamSearchLis
 	if isComplete{
 		tmpSQL = ` AND status=2 `
 	}
-	query = fmt.Sprintf("SELECT MAX(id)'id' FROM %s WHERE deleted=0 AND flow_instance_id IN(?) %s GROUP BY flow_instance_id", schema.NodeInstanceTableName,tmpSQL)
+	query = fmt.Sprintf("SELECT MAX(id) AS id FROM %s WHERE deleted=0 AND flow_instance_id IN(?) %s GROUP BY flow_instance_id", schema.NodeInstanceTableName,tmpSQL)
 
 
 	errMsg = "查询流程实例的最后一个节点实例发生错误"
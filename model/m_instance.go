@@ -0,0 +1,218 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/pkg/errors"
+)
+
+// QueryRunningFlowInstances 查询流程下未结束(进行中或暂停)的流程实例
+func (a *Flow) QueryRunningFlowInstances(flowID string) ([]*schema.FlowInstance, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND status IN(1,2) AND flow_id=? ORDER BY id", schema.FlowInstanceTableName)
+
+	var items []*schema.FlowInstance
+	_, err := a.DB.Select(&items, query, flowID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询未结束的流程实例发生错误")
+	}
+
+	return items, nil
+}
+
+// QueryTodoNodeInstances 查询流程实例中待处理的节点实例
+func (a *Flow) QueryTodoNodeInstances(flowInstanceID string) ([]*schema.NodeInstance, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND status=1 AND flow_instance_id=? ORDER BY id", schema.NodeInstanceTableName)
+
+	var items []*schema.NodeInstance
+	_, err := a.DB.Select(&items, query, flowInstanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询待处理的节点实例发生错误")
+	}
+
+	return items, nil
+}
+
+// QueryDoneNodeInstances 查询流程实例中已完成的节点实例(按完成顺序)
+func (a *Flow) QueryDoneNodeInstances(flowInstanceID string) ([]*schema.FlowHistoryResult, error) {
+	query := fmt.Sprintf(`
+		SELECT
+		ni.record_id,
+		ni.processor,
+		ni.process_time,
+		ni.input_data,
+		ni.input_ref,
+		ni.out_data,
+		ni.status,
+		n.record_id AS node_id,
+		n.code AS node_code,
+		n.name AS node_name
+		FROM %s ni JOIN %s n ON ni.node_id=n.record_id AND n.deleted=ni.deleted
+		WHERE ni.deleted=0 AND ni.status=2 AND ni.flow_instance_id=?
+		ORDER BY ni.process_time,ni.id
+		`, schema.NodeInstanceTableName, schema.NodeTableName)
+
+	var items []*schema.FlowHistoryResult
+	_, err := a.DB.Select(&items, query, flowInstanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询已完成的节点实例发生错误")
+	}
+	return items, nil
+}
+
+// MigrateFlowInstance 迁移流程实例(锁定流程实例及待处理的节点实例后，在同一事物中校验并更新流程内码、节点内码及记录操作日志)
+// migrate 根据锁定后的待处理节点实例返回节点实例内码与目标节点内码的映射及操作日志，返回错误时不迁移
+func (a *Flow) MigrateFlowInstance(flowInstanceID, fromFlowID, toFlowID string, migrate func([]*schema.NodeInstance) (map[string]string, *schema.InstanceLog, error)) error {
+	tran, err := a.DB.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "迁移流程实例开启事物发生错误")
+	}
+
+	var flowInstance schema.FlowInstance
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND record_id=? FOR UPDATE", schema.FlowInstanceTableName)
+	err = tran.SelectOne(&flowInstance, query, flowInstanceID)
+	if err != nil {
+		_ = tran.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.Errorf("流程实例(%s)不存在", flowInstanceID)
+		}
+		return errors.Wrapf(err, "锁定流程实例发生错误")
+	} else if flowInstance.FlowID != fromFlowID || (flowInstance.Status != 1 && flowInstance.Status != 2) {
+		_ = tran.Rollback()
+		return errors.Errorf("流程实例(%s)已结束或已迁移", flowInstanceID)
+	}
+
+	var nodeInstances []*schema.NodeInstance
+	query = fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND status=1 AND flow_instance_id=? ORDER BY id FOR UPDATE", schema.NodeInstanceTableName)
+	_, err = tran.Select(&nodeInstances, query, flowInstanceID)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "锁定待处理的节点实例发生错误")
+	}
+
+	nodes, log, err := migrate(nodeInstances)
+	if err != nil {
+		_ = tran.Rollback()
+		return err
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET flow_id=?,updated=? WHERE record_id=?", schema.FlowInstanceTableName)
+	_, err = tran.Exec(query, toFlowID, log.Created, flowInstanceID)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "更新流程实例的流程内码发生错误")
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET node_id=?,updated=? WHERE record_id=? AND status=1", schema.NodeInstanceTableName)
+	for _, ni := range nodeInstances {
+		result, err := tran.Exec(query, nodes[ni.RecordID], log.Created, ni.RecordID)
+		if err != nil {
+			_ = tran.Rollback()
+			return errors.Wrapf(err, "更新节点实例的节点内码发生错误")
+		} else if n, _ := result.RowsAffected(); n != 1 {
+			_ = tran.Rollback()
+			return errors.Errorf("节点实例(%s)已处理", ni.RecordID)
+		}
+	}
+
+	err = tran.Insert(log)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "插入流程实例操作日志发生错误")
+	}
+
+	err = tran.Commit()
+	if err != nil {
+		return errors.Wrapf(err, "迁移流程实例提交事物发生错误")
+	}
+	return nil
+}
+
+// QueryInstanceLogs 查询流程实例操作日志
+func (a *Flow) QueryInstanceLogs(flowInstanceID string) ([]*schema.InstanceLog, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE flow_instance_id=? ORDER BY id", schema.InstanceLogTableName)
+
+	var items []*schema.InstanceLog
+	_, err := a.DB.Select(&items, query, flowInstanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询流程实例操作日志发生错误")
+	}
+
+	return items, nil
+}
+
+// CreateExpressionTrace 创建表达式执行记录
+func (a *Flow) CreateExpressionTrace(item *schema.ExpressionTrace) error {
+	err := a.DB.Insert(item)
+	if err != nil {
+		return errors.Wrapf(err, "创建表达式执行记录发生错误")
+	}
+	return nil
+}
+
+// QueryExpressionTraces 查询节点实例流转时的表达式执行记录
+func (a *Flow) QueryExpressionTraces(nodeInstanceID string) ([]*schema.ExpressionTrace, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE node_instance_id=? ORDER BY id", schema.ExpressionTraceTableName)
+
+	var items []*schema.ExpressionTrace
+	_, err := a.DB.Select(&items, query, nodeInstanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询表达式执行记录发生错误")
+	}
+	return items, nil
+}
+
+// MoveFlowInstance 将流程实例移动到指定节点(锁定流程实例后取消所有待处理的节点实例及其定时任务，创建新的节点实例并记录操作日志)
+func (a *Flow) MoveFlowInstance(flowID string, nodeInstance *schema.NodeInstance, nodeCandidates []*schema.NodeCandidate, log *schema.InstanceLog) error {
+	tran, err := a.DB.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "移动流程实例开启事物发生错误")
+	}
+
+	var flowInstance schema.FlowInstance
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND record_id=? FOR UPDATE", schema.FlowInstanceTableName)
+	err = tran.SelectOne(&flowInstance, query, nodeInstance.FlowInstanceID)
+	if err != nil {
+		_ = tran.Rollback()
+		if err == sql.ErrNoRows {
+			return errors.Errorf("流程实例(%s)不存在", nodeInstance.FlowInstanceID)
+		}
+		return errors.Wrapf(err, "锁定流程实例发生错误")
+	} else if flowInstance.FlowID != flowID || (flowInstance.Status != 1 && flowInstance.Status != 2) {
+		_ = tran.Rollback()
+		return errors.Errorf("流程实例(%s)已结束或已迁移", nodeInstance.FlowInstanceID)
+	}
+
+	// 删除待处理节点实例的定时任务
+	query = fmt.Sprintf("UPDATE %s SET deleted=? WHERE deleted=0 AND node_instance_id IN(SELECT record_id FROM %s WHERE deleted=0 AND status=1 AND flow_instance_id=?)", schema.NodeTimingTableName, schema.NodeInstanceTableName)
+	_, err = tran.Exec(query, log.Created, nodeInstance.FlowInstanceID)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "删除节点定时任务发生错误")
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET status=3,updated=? WHERE deleted=0 AND status=1 AND flow_instance_id=?", schema.NodeInstanceTableName)
+	_, err = tran.Exec(query, log.Created, nodeInstance.FlowInstanceID)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "取消待处理的节点实例发生错误")
+	}
+
+	group := []interface{}{nodeInstance, log}
+	for _, c := range nodeCandidates {
+		group = append(group, c)
+	}
+
+	err = tran.Insert(group...)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "插入节点实例数据发生错误")
+	}
+
+	err = tran.Commit()
+	if err != nil {
+		return errors.Wrapf(err, "移动流程实例提交事物发生错误")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/antlinker/flow/service/db"
+	"github.com/pkg/errors"
+)
+
+// CreateIdempotentRequest 创建幂等请求
+func (a *Flow) CreateIdempotentRequest(item *schema.IdempotentRequest) error {
+	err := a.DB.Insert(item)
+	if err != nil {
+		return errors.Wrapf(err, "创建幂等请求发生错误")
+	}
+	return nil
+}
+
+// GetIdempotentRequest 获取幂等请求
+func (a *Flow) GetIdempotentRequest(requestKey string) (*schema.IdempotentRequest, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE request_key=? LIMIT 1", schema.RequestTableName)
+
+	var item schema.IdempotentRequest
+	err := a.DB.SelectOne(&item, query, requestKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "获取幂等请求发生错误")
+	}
+
+	return &item, nil
+}
+
+// UpdateIdempotentRequest 更新幂等请求
+func (a *Flow) UpdateIdempotentRequest(requestKey string, info map[string]interface{}) error {
+	_, err := a.DB.UpdateByPK(schema.RequestTableName, db.M{"request_key": requestKey}, db.M(info))
+	if err != nil {
+		return errors.Wrapf(err, "更新幂等请求发生错误")
+	}
+	return nil
+}
+
+// ReclaimIdempotentRequest 重新占用处理中的幂等请求(created与占用时一致时更新，返回是否占用成功)
+func (a *Flow) ReclaimIdempotentRequest(requestKey string, created, now int64) (bool, error) {
+	query := fmt.Sprintf("UPDATE %s SET created=?,updated=? WHERE request_key=? AND status=1 AND created=?", schema.RequestTableName)
+	result, err := a.DB.Exec(query, now, now, requestKey, created)
+	if err != nil {
+		return false, errors.Wrapf(err, "重新占用幂等请求发生错误")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrapf(err, "重新占用幂等请求发生错误")
+	}
+	return n == 1, nil
+}
+
+// RequestIndexExists 检查幂等键的唯一索引是否存在
+func (a *Flow) RequestIndexExists() (bool, error) {
+	exists, err := a.DB.IndexExists(schema.RequestTableName, "request_key")
+	if err != nil {
+		return false, errors.Wrapf(err, "检查幂等请求索引发生错误")
+	}
+	return exists, nil
+}
+
+// DeleteIdempotentRequest 删除幂等请求
+func (a *Flow) DeleteIdempotentRequest(requestKey string) error {
+	_, err := a.DB.DeleteByPK(schema.RequestTableName, db.M{"request_key": requestKey})
+	if err != nil {
+		return errors.Wrapf(err, "删除幂等请求发生错误")
+	}
+	return nil
+}
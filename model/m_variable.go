@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/antlinker/flow/service/db"
+	"github.com/pkg/errors"
+)
+
+// QueryFlowVariables 查询流程实例变量
+func (a *Flow) QueryFlowVariables(flowInstanceID string) ([]*schema.FlowVariable, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND flow_instance_id=? ORDER BY id", schema.FlowVariableTableName)
+
+	var items []*schema.FlowVariable
+	_, err := a.DB.Select(&items, query, flowInstanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询流程实例变量发生错误")
+	}
+
+	return items, nil
+}
+
+// SaveFlowVariables 保存流程实例变量(变量按流程实例及变量名称插入或更新，与变更历史在同一事物中保存)
+func (a *Flow) SaveFlowVariables(vars []*schema.FlowVariable, histories []*schema.VariableHistory) error {
+	tran, err := a.DB.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "保存流程实例变量开启事物发生错误")
+	}
+
+	for _, item := range vars {
+		query, args := a.DB.UpsertSQL(schema.FlowVariableTableName, []string{"flow_instance_id", "name"}, db.M{
+			"record_id":        item.RecordID,
+			"flow_instance_id": item.FlowInstanceID,
+			"name":             item.Name,
+			"type_code":        item.TypeCode,
+			"value":            item.Value,
+			"created":          item.Created,
+			"updated":          item.Updated,
+			"deleted":          0,
+		}, "type_code", "value", "updated")
+
+		_, err = tran.Exec(query, args...)
+		if err != nil {
+			_ = tran.Rollback()
+			return errors.Wrapf(err, "保存流程实例变量数据发生错误")
+		}
+	}
+
+	var group []interface{}
+	for _, item := range histories {
+		group = append(group, item)
+	}
+
+	err = tran.Insert(group...)
+	if err != nil {
+		_ = tran.Rollback()
+		return errors.Wrapf(err, "插入流程实例变量历史数据发生错误")
+	}
+
+	err = tran.Commit()
+	if err != nil {
+		return errors.Wrapf(err, "保存流程实例变量提交事物发生错误")
+	}
+	return nil
+}
+
+// QueryVariableHistories 查询流程实例变量的变更历史
+func (a *Flow) QueryVariableHistories(flowInstanceID string) ([]*schema.VariableHistory, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE deleted=0 AND flow_instance_id=? ORDER BY id", schema.VariableHistoryTableName)
+
+	var items []*schema.VariableHistory
+	_, err := a.DB.Select(&items, query, flowInstanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "查询流程实例变量历史发生错误")
+	}
+
+	return items, nil
+}
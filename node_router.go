@@ -3,6 +3,7 @@ package flow
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/antlinker/flow/schema"
 	"github.com/pkg/errors"
@@ -57,10 +58,13 @@ type NodeRouter struct {
 	flowInstance *schema.FlowInstance
 	nodeInstance *schema.NodeInstance
 	inputData    []byte
+	vars         map[string]interface{}
 	engine       *Engine
+	execer       Execer
 	opts         *nodeRouterOptions
 	parent       *NodeRouter
 	stop         bool
+	history      map[string]*historyNode
 }
 
 // Init 初始化节点路由
@@ -75,7 +79,7 @@ func (n *NodeRouter) Init(ctx context.Context, engine *Engine, nodeInstanceID st
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	n.ctx = ctx
+	n.ctx = engine.exprContext(ctx)
 	n.opts = opts
 	n.inputData = inputData
 	n.engine = engine
@@ -96,6 +100,12 @@ func (n *NodeRouter) Init(ctx context.Context, engine *Engine, nodeInstanceID st
 	}
 	n.flowInstance = flowInstance
 
+	execer, err := n.engine.flowExecer(flowInstance.FlowID)
+	if err != nil {
+		return nil, err
+	}
+	n.execer = execer
+
 	node, err := n.engine.flowBll.GetNode(nodeInstance.NodeID)
 	if err != nil {
 		return nil, err
@@ -160,6 +170,19 @@ func (n *NodeRouter) Next(processor string) error {
 		return err
 	}
 
+	err = n.loadVariables(processor)
+	if err != nil {
+		return err
+	}
+
+	// 业务规则任务执行决策表，后续的连线条件可以使用决策结果
+	if nodeType == BusinessRuleTask {
+		err = n.execBusinessRule(processor)
+		if err != nil {
+			return err
+		}
+	}
+
 	// 如果当前节点是人工任务，检查下一节点是否是并行网关，如果是则检查还未完成的待办事项，如果有则停止流转
 	if nodeType == UserTask && n.parent == nil {
 		ok, err := n.checkNextNodeType(ParallelGateway)
@@ -230,6 +253,36 @@ func (n *NodeRouter) Next(processor string) error {
 	return nil
 }
 
+// 将当前节点的输入数据合并到流程实例变量，并加载流程实例变量
+// 后续自动流转的节点与发起节点共享同一份变量
+func (n *NodeRouter) loadVariables(processor string) error {
+	if n.parent != nil {
+		// 与父节点共享变量(业务规则任务的结果对后续节点可见)
+		if n.parent.vars == nil {
+			n.parent.vars = make(map[string]interface{})
+		}
+		n.vars = n.parent.vars
+		return nil
+	}
+
+	var input map[string]interface{}
+	if json.Unmarshal(n.inputData, &input) == nil && len(input) > 0 {
+		err := n.engine.flowBll.SetVariables(n.flowInstance.RecordID, n.nodeInstance.RecordID, processor, input)
+		if err != nil {
+			return err
+		}
+	}
+
+	vars, err := n.engine.flowBll.GetVariables(n.flowInstance.RecordID)
+	if err != nil {
+		return err
+	} else if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	n.vars = vars
+	return nil
+}
+
 // 增加下一处理节点实例
 func (n *NodeRouter) addNextNodeInstances() ([]string, error) {
 	routers, err := n.engine.flowBll.QueryNodeRouters(n.node.RecordID)
@@ -242,7 +295,12 @@ func (n *NodeRouter) addNextNodeInstances() ([]string, error) {
 	var nodeInstanceIDs []string
 	for _, r := range routers {
 		if r.Expression != "" {
-			allow, err := n.engine.execer.ExecReturnBool(n.ctx, []byte(r.Expression), n.getExpData())
+			expData, err := n.getExpData()
+			if err != nil {
+				return nil, err
+			}
+
+			allow, err := n.execRouter(r, expData)
 			if err != nil {
 				return nil, err
 			} else if !allow {
@@ -256,9 +314,14 @@ func (n *NodeRouter) addNextNodeInstances() ([]string, error) {
 			return nil, err
 		}
 
+		expData, err := n.getExpData()
+		if err != nil {
+			return nil, err
+		}
+
 		var candidates []string
 		for _, assign := range assigns {
-			ss, err := n.engine.execer.ExecReturnStringSlice(n.ctx, []byte(assign.Expression), n.getExpData())
+			ss, err := n.execAssignment(r, assign.Expression, expData)
 			if err != nil {
 				return nil, err
 			}
@@ -274,6 +337,73 @@ func (n *NodeRouter) addNextNodeInstances() ([]string, error) {
 	return nodeInstanceIDs, nil
 }
 
+// 执行业务规则任务的决策表(输入为流程实例变量)，并将结果写入流程实例变量
+func (n *NodeRouter) execBusinessRule(processor string) error {
+	properties, err := n.engine.flowBll.GetNodeProperty(n.node.RecordID)
+	if err != nil {
+		return err
+	}
+
+	code := properties[DecisionRefProperty]
+	out, err := decide(n.ctx, code, n.vars)
+	if err != nil {
+		return errors.Wrapf(err, "执行业务规则任务(%s)发生错误", n.node.Code)
+	}
+
+	name := resultVariable(code, properties[ResultVariableProperty])
+	err = n.engine.flowBll.SetVariables(n.flowInstance.RecordID, n.nodeInstance.RecordID, processor, map[string]interface{}{name: out})
+	if err != nil {
+		return err
+	}
+
+	n.vars[name] = out
+	return nil
+}
+
+// 执行连线条件(启用表达式跟踪时记录执行过程)
+func (n *NodeRouter) execRouter(r *schema.NodeRouter, expData []byte) (bool, error) {
+	start := time.Now()
+	allow, err := n.execer.ExecReturnBool(n.ctx, []byte(r.Expression), expData)
+	n.trace(r, TraceRouter, r.Expression, expData, allow, err, start)
+	return allow, err
+}
+
+// 执行目标节点的指派表达式(启用表达式跟踪时记录执行过程)
+func (n *NodeRouter) execAssignment(r *schema.NodeRouter, exp string, expData []byte) ([]string, error) {
+	start := time.Now()
+	ss, err := n.execer.ExecReturnStringSlice(n.ctx, []byte(exp), expData)
+	n.trace(r, TraceAssignment, exp, expData, ss, err, start)
+	return ss, err
+}
+
+// 记录表达式执行过程，记录失败时只输出日志，不影响流转
+func (n *NodeRouter) trace(r *schema.NodeRouter, kind, exp string, expData []byte, result interface{}, err error, start time.Time) {
+	if !n.engine.exprTrace {
+		return
+	}
+
+	item := &schema.ExpressionTrace{
+		FlowInstanceID: n.flowInstance.RecordID,
+		NodeInstanceID: n.nodeInstance.RecordID,
+		RouterID:       r.RecordID,
+		TargetNodeID:   r.TargetNodeID,
+		Kind:           kind,
+		Expression:     exp,
+		Params:         string(expData),
+		Elapsed:        int64(time.Since(start) / time.Microsecond),
+	}
+	if err != nil {
+		item.Error = err.Error()
+	} else {
+		b, _ := json.Marshal(result)
+		item.Result = string(b)
+	}
+
+	if terr := n.engine.flowBll.CreateExpressionTrace(item); terr != nil {
+		n.engine.errorf("%+v", terr)
+	}
+}
+
 // 检查下一节点类型
 func (n *NodeRouter) checkNextNodeType(t NodeType) (bool, error) {
 	routers, err := n.engine.flowBll.QueryNodeRouters(n.node.RecordID)
@@ -285,7 +415,12 @@ func (n *NodeRouter) checkNextNodeType(t NodeType) (bool, error) {
 
 	for _, r := range routers {
 		if r.Expression != "" {
-			allow, err := n.engine.execer.ExecReturnBool(n.ctx, []byte(r.Expression), n.getExpData())
+			expData, err := n.getExpData()
+			if err != nil {
+				return false, err
+			}
+
+			allow, err := n.execer.ExecReturnBool(n.ctx, []byte(r.Expression), expData)
 			if err != nil {
 				return false, err
 			} else if !allow {
@@ -309,15 +444,27 @@ func (n *NodeRouter) checkNextNodeType(t NodeType) (bool, error) {
 }
 
 // 获取表达式数据
-func (n *NodeRouter) getExpData() []byte {
+// history 已完成的节点实例(按节点编号，只读)，launcher 发起人
+func (n *NodeRouter) getExpData() ([]byte, error) {
+	if n.history == nil {
+		items, err := n.engine.flowBll.QueryDoneNodeInstances(n.flowInstance.RecordID)
+		if err != nil {
+			return nil, err
+		}
+		n.history = newHistory(items)
+	}
+
 	var input map[string]interface{}
 	json.Unmarshal(n.inputData, &input)
 
 	r := map[string]interface{}{
-		"input": input,
-		"flow":  n.flowInstance,
-		"node":  n.nodeInstance,
+		"input":    input,
+		"vars":     n.vars,
+		"flow":     n.flowInstance,
+		"node":     n.nodeInstance,
+		"history":  n.history,
+		"launcher": newLauncher(n.flowInstance),
 	}
 	b, _ := json.Marshal(r)
-	return b
+	return b, nil
 }
@@ -22,6 +22,8 @@ const (
 	ExclusiveGateway NodeType = "exclusiveGateway"
 	// ParallelGateway 并行网关
 	ParallelGateway NodeType = "parallelGateway"
+	// BusinessRuleTask 业务规则任务(执行决策表并将结果写入流程实例变量)
+	BusinessRuleTask NodeType = "businessRuleTask"
 	// Unknown 未知类型
 	Unknown NodeType = "Unknown"
 )
@@ -41,6 +43,8 @@ func GetNodeTypeByName(s string) (NodeType, error) {
 		return ExclusiveGateway, nil
 	case "parallelGateway":
 		return ParallelGateway, nil
+	case "businessRuleTask":
+		return BusinessRuleTask, nil
 	}
 	return Unknown, errors.New(s + "不支持的类型")
 }
@@ -0,0 +1,93 @@
+package flow
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// OrgProvider 组织机构数据提供者(由应用实现)，注册到引擎后可以在表达式中使用以下内置函数：
+// manager(userID, level) 用户的第level级上级(level从1开始)
+// usersInRole(role) 角色下的用户
+// deptHead(deptID) 部门负责人
+// usersInDept(deptID) 部门下的用户
+// usersInPosition(position) 岗位下的用户
+// userDept(userID) 用户所在的部门
+// 除userDept外，内置函数均返回用户ID列表，可以直接作为指派表达式的结果
+type OrgProvider interface {
+	// 获取用户的第level级上级
+	Manager(ctx context.Context, userID string, level int) ([]string, error)
+	// 获取角色下的用户
+	UsersInRole(ctx context.Context, role string) ([]string, error)
+	// 获取部门负责人
+	DeptHead(ctx context.Context, deptID string) ([]string, error)
+	// 获取部门下的用户
+	UsersInDept(ctx context.Context, deptID string) ([]string, error)
+	// 获取岗位下的用户
+	UsersInPosition(ctx context.Context, position string) ([]string, error)
+	// 获取用户所在的部门
+	UserDept(ctx context.Context, userID string) (string, error)
+}
+
+// 组织机构内置函数，执行出错时panic(由表达式执行器转换为错误)
+func orgFuncs(ctx context.Context, p OrgProvider) map[string]interface{} {
+	check := func(name string, err error) {
+		if p == nil {
+			panic(errors.Errorf("调用%s失败：未设定组织机构数据提供者", name))
+		}
+		if err != nil {
+			panic(errors.Wrapf(err, "调用%s发生错误", name))
+		}
+	}
+
+	return map[string]interface{}{
+		"manager": func(userID string, level int) []string {
+			check("manager", nil)
+			users, err := p.Manager(ctx, userID, level)
+			check("manager", err)
+			return users
+		},
+		"usersInRole": func(role string) []string {
+			check("usersInRole", nil)
+			users, err := p.UsersInRole(ctx, role)
+			check("usersInRole", err)
+			return users
+		},
+		"deptHead": func(deptID string) []string {
+			check("deptHead", nil)
+			users, err := p.DeptHead(ctx, deptID)
+			check("deptHead", err)
+			return users
+		},
+		"usersInDept": func(deptID string) []string {
+			check("usersInDept", nil)
+			users, err := p.UsersInDept(ctx, deptID)
+			check("usersInDept", err)
+			return users
+		},
+		"usersInPosition": func(position string) []string {
+			check("usersInPosition", nil)
+			users, err := p.UsersInPosition(ctx, position)
+			check("usersInPosition", err)
+			return users
+		},
+		"userDept": func(userID string) string {
+			check("userDept", nil)
+			dept, err := p.UserDept(ctx, userID)
+			check("userDept", err)
+			return dept
+		},
+	}
+}
+
+// 将组织机构内置函数加入表达式变量
+func withOrgFuncs(ctx context.Context, vars map[string]interface{}) map[string]interface{} {
+	p, _ := FromOrgContext(ctx)
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	for name, fn := range orgFuncs(ctx, p) {
+		vars[name] = fn
+	}
+	return vars
+}
@@ -0,0 +1,76 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testOrgProvider struct{}
+
+func (testOrgProvider) Manager(ctx context.Context, userID string, level int) ([]string, error) {
+	if userID != "u1" {
+		return nil, errors.New("user not found")
+	}
+	return []string{"m" + string(rune('0'+level))}, nil
+}
+
+func (testOrgProvider) UsersInRole(ctx context.Context, role string) ([]string, error) {
+	return []string{role + "1", role + "2"}, nil
+}
+
+func (testOrgProvider) DeptHead(ctx context.Context, deptID string) ([]string, error) {
+	return []string{deptID + "_head"}, nil
+}
+
+func (testOrgProvider) UsersInDept(ctx context.Context, deptID string) ([]string, error) {
+	return nil, nil
+}
+
+func (testOrgProvider) UsersInPosition(ctx context.Context, position string) ([]string, error) {
+	return nil, nil
+}
+
+func (testOrgProvider) UserDept(ctx context.Context, userID string) (string, error) {
+	return "d1", nil
+}
+
+func TestExprExecerOrgFuncs(t *testing.T) {
+	ctx := NewOrgContext(context.Background(), testOrgProvider{})
+	execer := NewExprExecer()
+
+	tests := []struct {
+		exp  string
+		want []string
+	}{
+		{`manager(flow.launcher, 2)`, []string{"m2"}},
+		{`usersInRole("finance")`, []string{"finance1", "finance2"}},
+		{`deptHead(userDept(flow.launcher))`, []string{"d1_head"}},
+	}
+
+	for _, tt := range tests {
+		got, err := execer.ExecReturnStringSlice(ctx, []byte(tt.exp), []byte(exprParams))
+		if err != nil {
+			t.Errorf("%s: %v", tt.exp, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.exp, got, tt.want)
+		}
+	}
+
+	if err := execer.Check([]byte(`manager(flow.launcher, 1)`), ExpCandidate); err != nil {
+		t.Error(err)
+	}
+
+	_, err := execer.ExecReturnStringSlice(ctx, []byte(`manager("u2", 1)`), []byte(exprParams))
+	if err == nil {
+		t.Error(`manager("u2", 1): want error`)
+	}
+
+	_, err = execer.ExecReturnStringSlice(context.Background(), []byte(`usersInRole("finance")`), []byte(exprParams))
+	if err == nil {
+		t.Error("without OrgProvider: want error")
+	}
+}
@@ -10,6 +10,13 @@ type Parser interface {
 	Parse(ctx context.Context, data []byte) (*ParseResult, error)
 }
 
+// MultiParser 支持多流程的解析器(例如包含多个参与者流程的协作图)
+type MultiParser interface {
+	Parser
+	// 解析流程定义数据中的所有可执行流程
+	ParseAll(ctx context.Context, data []byte) ([]*ParseResult, error)
+}
+
 // ParseResult 流程数据
 type ParseResult struct {
 	FlowID      string        // 流程ID
@@ -17,6 +24,10 @@ type ParseResult struct {
 	FlowVersion int64         // 流程版本号
 	FlowStatus  int           // 流程状态(1:可用 2:不可用)
 	Nodes       []*NodeResult // 节点数据
+
+	FlowCategory      string            // 流程类别(对应流程类型编号)
+	FlowDocumentation string            // 流程说明
+	FlowProperties    []*PropertyResult // 流程扩展属性
 }
 
 // NodeResult 节点数据
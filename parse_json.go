@@ -0,0 +1,232 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Format 流程定义数据格式
+type Format string
+
+const (
+	// FormatAuto 根据数据内容自动识别
+	FormatAuto Format = ""
+	// FormatBPMN BPMN 2.0 XML
+	FormatBPMN Format = "bpmn"
+	// FormatJSON JSON流程定义(参考doc/json_flow.md)
+	FormatJSON Format = "json"
+)
+
+// DetectFormat 根据数据内容识别流程定义格式
+func DetectFormat(data []byte) Format {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+		return FormatJSON
+	}
+	return FormatBPMN
+}
+
+// NewJSONParser json解析器
+func NewJSONParser() Parser {
+	return &jsonParser{}
+}
+
+type jsonParser struct {
+}
+
+type jsonFlow struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name,omitempty"`
+	Version       int64           `json:"version,omitempty"`
+	Executable    *bool           `json:"executable,omitempty"`
+	Category      string          `json:"category,omitempty"`
+	Documentation string          `json:"documentation,omitempty"`
+	Properties    []*jsonProperty `json:"properties,omitempty"`
+	Nodes         []*jsonNode     `json:"nodes"`
+}
+
+type jsonNode struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name,omitempty"`
+	Type       string          `json:"type"`
+	Candidates []string        `json:"candidates,omitempty"`
+	Properties []*jsonProperty `json:"properties,omitempty"`
+	Form       *jsonForm       `json:"form,omitempty"`
+	Routers    []*jsonRouter   `json:"routers,omitempty"`
+}
+
+type jsonProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type jsonRouter struct {
+	Target     string `json:"target"`
+	Explain    string `json:"explain,omitempty"`
+	Expression string `json:"expression,omitempty"`
+}
+
+type jsonForm struct {
+	ID     string       `json:"id"`
+	Fields []*jsonField `json:"fields,omitempty"`
+}
+
+type jsonField struct {
+	ID           string            `json:"id"`
+	Type         string            `json:"type,omitempty"`
+	Label        string            `json:"label,omitempty"`
+	DefaultValue string            `json:"default_value,omitempty"`
+	Values       []*jsonOption     `json:"values,omitempty"`
+	Validations  []*jsonValidation `json:"validations,omitempty"`
+	Properties   []*jsonFieldProp  `json:"properties,omitempty"`
+}
+
+type jsonOption struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type jsonValidation struct {
+	Name   string `json:"name"`
+	Config string `json:"config,omitempty"`
+}
+
+type jsonFieldProp struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// Parse 解析第一个流程
+func (p *jsonParser) Parse(ctx context.Context, data []byte) (*ParseResult, error) {
+	results, err := p.ParseAll(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// ParseAll 解析所有流程(数据可以是单个流程对象或流程对象数组)
+func (p *jsonParser) ParseAll(ctx context.Context, data []byte) ([]*ParseResult, error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+
+	var flows []*jsonFlow
+	if len(data) > 0 && data[0] == '[' {
+		if err := p.decode(data, &flows); err != nil {
+			return nil, err
+		}
+	} else {
+		var item jsonFlow
+		if err := p.decode(data, &item); err != nil {
+			return nil, err
+		}
+		flows = append(flows, &item)
+	}
+
+	if len(flows) == 0 {
+		return nil, fmt.Errorf("无效的JSON流程定义：未找到流程")
+	}
+
+	results := make([]*ParseResult, len(flows))
+	for i, item := range flows {
+		result, err := p.parseFlow(item)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+func (p *jsonParser) decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("无效的JSON流程定义：%s", err.Error())
+	}
+	return nil
+}
+
+func (p *jsonParser) parseFlow(item *jsonFlow) (*ParseResult, error) {
+	result := &ParseResult{
+		FlowID:      item.ID,
+		FlowName:    item.Name,
+		FlowVersion: item.Version,
+		FlowStatus:  1,
+	}
+	if item.Executable != nil && !*item.Executable {
+		result.FlowStatus = 2
+	}
+
+	result.FlowCategory = item.Category
+	result.FlowDocumentation = item.Documentation
+	for _, p := range item.Properties {
+		result.FlowProperties = append(result.FlowProperties, &PropertyResult{Name: p.Name, Value: p.Value})
+	}
+
+	nodeIDs := make(map[string]bool)
+	for _, n := range item.Nodes {
+		if n.ID == "" {
+			return nil, fmt.Errorf("节点(%s)缺少id属性", n.Type)
+		} else if nodeIDs[n.ID] {
+			return nil, fmt.Errorf("节点ID(%s)重复", n.ID)
+		}
+		nodeIDs[n.ID] = true
+
+		nodeType, err := GetNodeTypeByName(n.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		node := &NodeResult{
+			NodeID:               n.ID,
+			NodeName:             n.Name,
+			NodeType:             nodeType,
+			CandidateExpressions: n.Candidates,
+			FormResult:           new(NodeFormResult),
+		}
+
+		for _, p := range n.Properties {
+			node.Properties = append(node.Properties, &PropertyResult{Name: p.Name, Value: p.Value})
+		}
+
+		if n.Form != nil {
+			node.FormResult.ID = n.Form.ID
+			for _, f := range n.Form.Fields {
+				field := &FormFieldResult{
+					ID:           f.ID,
+					Type:         f.Type,
+					Label:        f.Label,
+					DefaultValue: f.DefaultValue,
+				}
+				for _, v := range f.Values {
+					field.Values = append(field.Values, &FieldOption{ID: v.ID, Name: v.Name})
+				}
+				for _, v := range f.Validations {
+					field.Validations = append(field.Validations, &FieldValidation{Name: v.Name, Config: v.Config})
+				}
+				for _, v := range f.Properties {
+					field.Properties = append(field.Properties, &FieldProperty{ID: v.ID, Value: v.Value})
+				}
+				node.FormResult.Fields = append(node.FormResult.Fields, field)
+			}
+		}
+
+		for _, r := range n.Routers {
+			if r.Target == "" {
+				return nil, fmt.Errorf("节点(%s)的连线缺少target属性", n.ID)
+			}
+			node.Routers = append(node.Routers, &RouterResult{
+				TargetNodeID: r.Target,
+				Explain:      r.Explain,
+				Expression:   r.Expression,
+			})
+		}
+
+		result.Nodes = append(result.Nodes, node)
+	}
+
+	return result, nil
+}
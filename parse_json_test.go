@@ -0,0 +1,81 @@
+package flow
+
+import (
+	"context"
+	"testing"
+)
+
+const jsonFlowData = `{
+  "id": "leave",
+  "name": "请假流程",
+  "version": 1,
+  "nodes": [
+    {
+      "id": "start",
+      "type": "startEvent",
+      "form": {"id": "leave_form", "fields": [{"id": "day", "type": "enum", "label": "类型", "values": [{"id": "1", "name": "事假"}]}]},
+      "routers": [{"target": "approve"}]
+    },
+    {
+      "id": "approve",
+      "type": "userTask",
+      "candidates": ["return [flow.launcher]"],
+      "properties": [{"name": "timing", "value": "1440"}],
+      "routers": [{"target": "end", "explain": "通过", "expression": "input.action == \"pass\""}]
+    },
+    {"id": "end", "type": "endEvent"}
+  ]
+}`
+
+func TestParseJSON(t *testing.T) {
+	result, err := NewJSONParser().Parse(context.Background(), []byte(jsonFlowData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.FlowID != "leave" || result.FlowVersion != 1 || result.FlowStatus != 1 {
+		t.Errorf("Parse() = %s/%d/%d", result.FlowID, result.FlowVersion, result.FlowStatus)
+	}
+	if len(result.Nodes) != 3 {
+		t.Fatalf("Parse() got %d nodes, want 3", len(result.Nodes))
+	}
+
+	start, approve := result.Nodes[0], result.Nodes[1]
+	if start.FormResult.ID != "leave_form" || len(start.FormResult.Fields) != 1 ||
+		len(start.FormResult.Fields[0].Values) != 1 || start.FormResult.Fields[0].Values[0].Name != "事假" {
+		t.Errorf("Parse() form = %+v", start.FormResult)
+	}
+	if len(approve.CandidateExpressions) != 1 || len(approve.Properties) != 1 || approve.Properties[0].Value != "1440" {
+		t.Errorf("Parse() approve = %+v", approve)
+	}
+	if len(approve.Routers) != 1 || approve.Routers[0].TargetNodeID != "end" || approve.Routers[0].Expression == "" {
+		t.Errorf("Parse() routers = %+v", approve.Routers)
+	}
+	if diagnostics := Validate(result); len(diagnostics) != 0 {
+		t.Errorf("Validate() = %v", diagnostics)
+	}
+}
+
+func TestParseJSONUnknownField(t *testing.T) {
+	_, err := NewJSONParser().Parse(context.Background(), []byte(`{"id": "leave", "nodes": [{"id": "start", "type": "startEvent", "next": "end"}]}`))
+	if err == nil {
+		t.Error("Parse() want error on unknown field")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		data string
+		want Format
+	}{
+		{jsonFlowData, FormatJSON},
+		{"\xef\xbb\xbf  [{}]", FormatJSON},
+		{`<?xml version="1.0" encoding="UTF-8"?><definitions />`, FormatBPMN},
+	}
+
+	for _, tt := range tests {
+		if got := DetectFormat([]byte(tt.data)); got != tt.want {
+			t.Errorf("DetectFormat(%.20q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package flow
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/antlinker/flow/util"
 
@@ -17,20 +20,60 @@ func NewXMLParser() Parser {
 type xmlParser struct {
 }
 
+// Parse 解析第一个可执行流程(没有可执行流程时解析第一个流程)
 func (p *xmlParser) Parse(ctx context.Context, content []byte) (*ParseResult, error) {
-	result := &ParseResult{
-		FlowStatus: 2,
+	results, err := p.ParseAll(ctx, content)
+	if err != nil {
+		return nil, err
 	}
-	var err error
+	return results[0], nil
+}
 
+// ParseAll 解析所有可执行流程(没有可执行流程时解析第一个流程)
+func (p *xmlParser) ParseAll(ctx context.Context, content []byte) ([]*ParseResult, error) {
 	doc := etree.NewDocument()
-	if err = doc.ReadFromBytes(content); err != nil {
-		panic(err)
+	if err := doc.ReadFromBytes(content); err != nil {
+		return nil, fmt.Errorf("无效的BPMN文件：%s", err.Error())
+	}
+
+	root := doc.Root()
+	if root == nil || !isBPMNElement(root, "definitions") {
+		return nil, errors.New("无效的BPMN文件：未找到definitions元素")
+	}
+
+	processes := selectBPMNElements(root, "process")
+	if len(processes) == 0 {
+		return nil, errors.New("无效的BPMN文件：未找到process元素")
+	}
+
+	var executables []*etree.Element
+	for _, process := range processes {
+		if v := process.SelectAttr("isExecutable"); v != nil {
+			if b, _ := strconv.ParseBool(v.Value); b {
+				executables = append(executables, process)
+			}
+		}
+	}
+	if len(executables) == 0 {
+		executables = processes[:1]
 	}
 
-	root := doc.SelectElement("definitions")
-	//root.Tag
-	process := root.SelectElement("process")
+	results := make([]*ParseResult, len(executables))
+	for i, process := range executables {
+		result, err := p.parseProcess(process)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+func (p *xmlParser) parseProcess(process *etree.Element) (*ParseResult, error) {
+	result := &ParseResult{
+		FlowStatus: 2,
+	}
+	var err error
 
 	if id := process.SelectAttr("id"); id != nil {
 		result.FlowID = id.Value
@@ -51,17 +94,22 @@ func (p *xmlParser) Parse(ctx context.Context, content []byte) (*ParseResult, er
 		}
 	}
 
+	p.parseProcessMetadata(process, result)
+
 	// 定义一个用于辅助的map，由节点id映射到noderesult
 	nodeMap := make(map[string]*NodeResult)
 	// 遍历找到所有的节点，因为是解析一个树，所以先解析节点，再解析sequenceFlow部分
 	// 解析sequenceFlow部分时，nodeMap里面应该已经有对应的nodeId了
 	for _, element := range process.ChildElements() {
-		if element.Tag == "documentation" ||
-			element.Tag == "extensionElements" ||
+		if !isBPMNElement(element, element.Tag) ||
+			ignoreElements[element.Tag] ||
 			element.Tag == "sequenceFlow" {
 			continue
 		}
-		node, _ := p.ParseNode(element)
+		node, err := p.ParseNode(element)
+		if err != nil {
+			return nil, err
+		}
 		var nodeResult NodeResult
 		nodeResult.NodeID = node.Code
 		nodeResult.NodeName = node.Name
@@ -69,40 +117,76 @@ func (p *xmlParser) Parse(ctx context.Context, content []byte) (*ParseResult, er
 		if err != nil {
 			return nil, err
 		}
+		if nodeResult.NodeID == "" {
+			return nil, fmt.Errorf("节点(%s)缺少id属性", node.Type)
+		} else if _, exist := nodeMap[nodeResult.NodeID]; exist {
+			return nil, fmt.Errorf("节点ID(%s)重复", nodeResult.NodeID)
+		}
 		nodeResult.CandidateExpressions = node.CandidateUsers
 		// yupengfei 2018-01-17 增加了form的解析
 		nodeResult.FormResult = node.FormResult
 		nodeResult.Properties = node.Properties
 		nodeMap[nodeResult.NodeID] = &nodeResult
-		// 如果节点是一个路由的话，需要特殊处理
+		// 保持节点在文件中的顺序
+		result.Nodes = append(result.Nodes, &nodeResult)
 	}
 
-	for _, element := range process.ChildElements() {
-		if element.Tag == "sequenceFlow" {
-			sequenceFlow, _ := p.ParsesequenceFlow(element)
-			var routerResult RouterResult
-			routerResult.Expression = sequenceFlow.Expression
-			routerResult.Explain = sequenceFlow.Explain
-			routerResult.TargetNodeID = sequenceFlow.TargetRef
-			if nodeResult, exist := nodeMap[sequenceFlow.SourceRef]; exist {
-				nodeResult.Routers = append(nodeResult.Routers, &routerResult)
-			}
+	for _, element := range selectBPMNElements(process, "sequenceFlow") {
+		sequenceFlow, err := p.ParsesequenceFlow(element)
+		if err != nil {
+			return nil, err
+		}
+		var routerResult RouterResult
+		routerResult.Expression = sequenceFlow.Expression
+		routerResult.Explain = sequenceFlow.Explain
+		routerResult.TargetNodeID = sequenceFlow.TargetRef
+		nodeResult, exist := nodeMap[sequenceFlow.SourceRef]
+		if !exist {
+			return nil, fmt.Errorf("连线(%s)的源节点(%s)不存在", sequenceFlow.Code, sequenceFlow.SourceRef)
 		}
+		nodeResult.Routers = append(nodeResult.Routers, &routerResult)
 	}
 
-	for _, nodeResult := range nodeMap {
-		result.Nodes = append(result.Nodes, nodeResult)
-	}
 	return result, nil
 }
 
+// 解析流程说明及扩展属性(名称为category的扩展属性作为流程类别)
+func (p *xmlParser) parseProcessMetadata(process *etree.Element, result *ParseResult) {
+	for _, element := range selectBPMNElements(process, "documentation") {
+		result.FlowDocumentation = strings.TrimSpace(element.Text())
+	}
+
+	extensionElements := process.SelectElement("extensionElements")
+	if extensionElements == nil {
+		return
+	}
+
+	if propertyData := extensionElements.SelectElement("properties"); propertyData != nil {
+		for _, p := range propertyData.SelectElements("property") {
+			var item PropertyResult
+			if name := p.SelectAttr("name"); name != nil {
+				item.Name = name.Value
+			}
+			if value := p.SelectAttr("value"); value != nil {
+				item.Value = value.Value
+			}
+
+			if item.Name == "category" {
+				result.FlowCategory = item.Value
+			} else if item.Name != "" {
+				result.FlowProperties = append(result.FlowProperties, &item)
+			}
+		}
+	}
+}
+
 func (p *xmlParser) ParseNode(element *etree.Element) (*nodeInfo, error) {
 	var node nodeInfo
 
 	node.Type = element.Tag
 	if node.Type == "endEvent" {
 		for _, e := range element.ChildElements() {
-			if e.Tag == "terminateEventDefinition" {
+			if isBPMNElement(e, "terminateEventDefinition") {
 				node.Type = "terminateEvent"
 			}
 		}
@@ -151,6 +235,15 @@ func (p *xmlParser) ParseNode(element *etree.Element) (*nodeInfo, error) {
 	}
 	node.FormResult = nodeFormResult
 
+	// 业务规则任务的决策表及结果变量(camunda:decisionRef、camunda:resultVariable)，与节点属性中的设定相同
+	if node.Type == BusinessRuleTask.String() {
+		for _, key := range []string{DecisionRefProperty, ResultVariableProperty} {
+			if attr := element.SelectAttr(key); attr != nil && propertyValue(node.Properties, key) == "" {
+				node.Properties = append(node.Properties, &PropertyResult{Name: key, Value: attr.Value})
+			}
+		}
+	}
+
 	return &node, nil
 }
 
@@ -158,13 +251,22 @@ func (p *xmlParser) ParsesequenceFlow(element *etree.Element) (*sequenceFlow, er
 	hasExpression := false
 	var seq sequenceFlow
 	seq.XMLName = element.Tag
-	seq.Code = element.SelectAttr("id").Value
-	seq.SourceRef = element.SelectAttr("sourceRef").Value
-	seq.TargetRef = element.SelectAttr("targetRef").Value
+	if id := element.SelectAttr("id"); id != nil {
+		seq.Code = id.Value
+	}
+	if sourceRef := element.SelectAttr("sourceRef"); sourceRef != nil {
+		seq.SourceRef = sourceRef.Value
+	}
+	if targetRef := element.SelectAttr("targetRef"); targetRef != nil {
+		seq.TargetRef = targetRef.Value
+	}
+	if seq.SourceRef == "" || seq.TargetRef == "" {
+		return nil, fmt.Errorf("连线(%s)缺少sourceRef或targetRef属性", seq.Code)
+	}
 	for _, element := range element.ChildElements() {
-		if element.Tag == "documentation" {
+		if isBPMNElement(element, "documentation") {
 			seq.Explain = element.Text()
-		} else if element.Tag == "conditionExpression" {
+		} else if isBPMNElement(element, "conditionExpression") {
 			seq.Expression = element.Text()
 			hasExpression = true
 		}
@@ -273,6 +375,57 @@ func (p *xmlParser) ParseEnumValues(element *etree.Element) ([]*FieldOption, err
 	return options, nil
 }
 
+// BPMN 2.0模型的命名空间
+const bpmnModelNamespace = "http://www.omg.org/spec/BPMN/20100524/MODEL"
+
+// 流程中不作为节点解析的元素
+var ignoreElements = map[string]bool{
+	"documentation":       true,
+	"extensionElements":   true,
+	"laneSet":             true,
+	"textAnnotation":      true,
+	"association":         true,
+	"dataObject":          true,
+	"dataObjectReference": true,
+	"dataStoreReference":  true,
+	"ioSpecification":     true,
+	"property":            true,
+}
+
+// 获取元素的命名空间(根据元素前缀向上查找xmlns声明)
+func namespaceURI(element *etree.Element) string {
+	for e := element; e != nil; e = e.Parent() {
+		for _, attr := range e.Attr {
+			if element.Space == "" && attr.Space == "" && attr.Key == "xmlns" {
+				return attr.Value
+			} else if element.Space != "" && attr.Space == "xmlns" && attr.Key == element.Space {
+				return attr.Value
+			}
+		}
+	}
+	return ""
+}
+
+// 检查是否是指定标签的BPMN模型元素(未声明命名空间的元素按BPMN元素处理)
+func isBPMNElement(element *etree.Element, tag string) bool {
+	if element.Tag != tag {
+		return false
+	}
+	ns := namespaceURI(element)
+	return ns == "" || ns == bpmnModelNamespace
+}
+
+// 查找指定标签的BPMN模型子元素
+func selectBPMNElements(element *etree.Element, tag string) []*etree.Element {
+	var elements []*etree.Element
+	for _, e := range element.ChildElements() {
+		if isBPMNElement(e, tag) {
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
 type nodeInfo struct {
 	ProcessCode    string
 	Type           string
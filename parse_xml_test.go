@@ -77,3 +77,45 @@ func TestParseBpmnForm(t *testing.T) {
 	buf, _ := json.Marshal(v)
 	fmt.Println(string(buf))
 }
+
+func TestParseBpmnNamespace(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:bpmn2="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:ext="http://example.com/ext">
+  <collaboration id="Collaboration_1" />
+  <bpmn2:process id="Process_A" isExecutable="true">
+    <bpmn2:startEvent id="StartEvent_A" />
+    <ext:startEvent id="Ignored" />
+    <bpmn2:endEvent id="EndEvent_A" />
+    <bpmn2:sequenceFlow id="Flow_A" sourceRef="StartEvent_A" targetRef="EndEvent_A" />
+  </bpmn2:process>
+  <process id="Process_B" isExecutable="true">
+    <laneSet id="LaneSet_B" />
+    <startEvent id="StartEvent_B" />
+    <endEvent id="EndEvent_B" />
+    <sequenceFlow id="Flow_B" sourceRef="StartEvent_B" targetRef="EndEvent_B" />
+  </process>
+  <process id="Process_C" isExecutable="false" />
+</definitions>`
+
+	results, err := NewXMLParser().(MultiParser).ParseAll(context.Background(), []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("ParseAll() got %d processes, want 2", len(results))
+	}
+
+	for i, flowID := range []string{"Process_A", "Process_B"} {
+		result := results[i]
+		if result.FlowID != flowID {
+			t.Errorf("ParseAll()[%d].FlowID = %s, want %s", i, result.FlowID, flowID)
+		}
+		if len(result.Nodes) != 2 {
+			t.Errorf("ParseAll()[%d] got %d nodes, want 2", i, len(result.Nodes))
+		}
+		if diagnostics := Validate(result); HasError(diagnostics) {
+			t.Errorf("Validate(%s) = %v", flowID, diagnostics)
+		}
+	}
+}
@@ -21,4 +21,12 @@ func FlowDBMap(db *db.DB) {
 	db.AddTableWithName(schema.FieldProperty{}, schema.FieldPropertyTableName)
 	db.AddTableWithName(schema.FieldValidation{}, schema.FieldValidationTableName)
 	db.AddTableWithName(schema.NodeProperty{}, schema.NodePropertyTableName)
+	db.AddTableWithName(schema.FlowVariable{}, schema.FlowVariableTableName)
+	db.AddTableWithName(schema.VariableHistory{}, schema.VariableHistoryTableName)
+	db.AddTableWithName(schema.IdempotentRequest{}, schema.RequestTableName)
+	db.AddTableWithName(schema.FlowDeployment{}, schema.DeploymentTableName)
+	db.AddTableWithName(schema.InstanceLog{}, schema.InstanceLogTableName)
+	db.AddTableWithName(schema.FlowProperty{}, schema.FlowPropertyTableName)
+	db.AddTableWithName(schema.ExpressionTrace{}, schema.ExpressionTraceTableName)
+	db.AddTableWithName(schema.Decision{}, schema.DecisionTableName)
 }
@@ -0,0 +1,255 @@
+package register
+
+import (
+	"fmt"
+
+	"github.com/antlinker/flow/schema"
+	"github.com/antlinker/flow/service/db"
+	"github.com/pkg/errors"
+)
+
+// FlowMigrations 流程相关的数据库迁移
+// 新增的字段或索引需要追加新的版本，已发布的版本不能再修改
+func FlowMigrations() []db.Migration {
+	return []db.Migration{
+		{
+			Version:     1,
+			Description: "创建流程数据索引",
+			Up:          createFlowIndexes,
+		},
+		{
+			Version:     2,
+			Description: "增加流程状态字段",
+			Up: func(m *db.DB) error {
+				return m.AddColumn(schema.FlowTableName, "status", "INT DEFAULT 1")
+			},
+		},
+		{
+			Version:     3,
+			Description: "扩展流程及节点数据字段长度",
+			Statements: map[db.Dialect][]string{
+				db.MySQL: {
+					"ALTER TABLE f_flow MODIFY COLUMN xml LONGTEXT",
+					"ALTER TABLE f_node_instance MODIFY COLUMN input_data LONGTEXT, MODIFY COLUMN out_data LONGTEXT",
+					"ALTER TABLE f_node_timing MODIFY COLUMN input LONGTEXT",
+				},
+				db.Postgres: {
+					"ALTER TABLE f_flow ALTER COLUMN xml TYPE TEXT",
+					"ALTER TABLE f_node_instance ALTER COLUMN input_data TYPE TEXT, ALTER COLUMN out_data TYPE TEXT",
+					"ALTER TABLE f_node_timing ALTER COLUMN input TYPE TEXT",
+				},
+			},
+			Up: func(m *db.DB) error {
+				return m.AddColumn(schema.NodeInstanceTableName, "input_ref", "VARCHAR(255) DEFAULT ''")
+			},
+		},
+		{
+			Version:     4,
+			Description: "流程实例变量",
+			Statements: map[db.Dialect][]string{
+				db.Postgres: {
+					"ALTER TABLE f_flow_variable ALTER COLUMN value TYPE TEXT",
+					"ALTER TABLE f_flow_variable_history ALTER COLUMN old_value TYPE TEXT, ALTER COLUMN value TYPE TEXT",
+				},
+			},
+			Up: func(m *db.DB) error {
+				err := m.CreateIndex(schema.FlowVariableTableName, "flow_instance_id", false, "flow_instance_id")
+				if err != nil {
+					return err
+				}
+				return m.CreateIndex(schema.VariableHistoryTableName, "flow_instance_id", false, "flow_instance_id")
+			},
+		},
+		{
+			Version:     5,
+			Description: "幂等请求",
+			Statements: map[db.Dialect][]string{
+				db.MySQL: {
+					"ALTER TABLE f_idempotent_request MODIFY COLUMN result LONGTEXT",
+				},
+				db.Postgres: {
+					"ALTER TABLE f_idempotent_request ALTER COLUMN result TYPE TEXT",
+				},
+			},
+			Up: func(m *db.DB) error {
+				return m.CreateIndex(schema.RequestTableName, "request_key", true, "request_key")
+			},
+		},
+		{
+			Version:     6,
+			Description: "流程部署及版本启用",
+			Up: func(m *db.DB) error {
+				err := m.AddColumn(schema.FlowTableName, "activated", "INT DEFAULT 0")
+				if err != nil {
+					return err
+				}
+
+				err = m.CreateIndex(schema.DeploymentTableName, "flow_code", false, "flow_code")
+				if err != nil {
+					return err
+				}
+				return activateLatestFlows(m)
+			},
+		},
+		{
+			Version:     7,
+			Description: "流程实例操作日志",
+			Statements: map[db.Dialect][]string{
+				db.MySQL: {
+					"ALTER TABLE f_instance_log MODIFY COLUMN data LONGTEXT",
+				},
+				db.Postgres: {
+					"ALTER TABLE f_instance_log ALTER COLUMN data TYPE TEXT",
+				},
+			},
+			Up: func(m *db.DB) error {
+				return m.CreateIndex(schema.InstanceLogTableName, "flow_instance_id", false, "flow_instance_id")
+			},
+		},
+		{
+			Version:     8,
+			Description: "流程类别、说明及扩展属性",
+			Statements: map[db.Dialect][]string{
+				db.MySQL: {
+					"ALTER TABLE f_flow MODIFY COLUMN memo VARCHAR(1024)",
+				},
+				db.Postgres: {
+					"ALTER TABLE f_flow ALTER COLUMN memo TYPE VARCHAR(1024)",
+				},
+			},
+			Up: func(m *db.DB) error {
+				err := m.CreateIndex(schema.FlowTableName, "type_code", false, "type_code")
+				if err != nil {
+					return err
+				}
+				return m.CreateIndex(schema.FlowPropertyTableName, "flow_id", false, "flow_id")
+			},
+		},
+		{
+			Version:     9,
+			Description: "表达式执行记录",
+			Statements: map[db.Dialect][]string{
+				db.MySQL: {
+					"ALTER TABLE f_expression_trace MODIFY COLUMN expression TEXT, MODIFY COLUMN params LONGTEXT, MODIFY COLUMN result TEXT, MODIFY COLUMN error TEXT",
+				},
+				db.Postgres: {
+					"ALTER TABLE f_expression_trace ALTER COLUMN expression TYPE TEXT, ALTER COLUMN params TYPE TEXT, ALTER COLUMN result TYPE TEXT, ALTER COLUMN error TYPE TEXT",
+				},
+			},
+			Up: func(m *db.DB) error {
+				return m.CreateIndex(schema.ExpressionTraceTableName, "node_instance_id", false, "node_instance_id")
+			},
+		},
+		{
+			Version:     10,
+			Description: "决策表",
+			Statements: map[db.Dialect][]string{
+				db.MySQL: {
+					"ALTER TABLE f_decision MODIFY COLUMN data LONGTEXT",
+				},
+				db.Postgres: {
+					"ALTER TABLE f_decision ALTER COLUMN data TYPE TEXT",
+				},
+			},
+			Up: func(m *db.DB) error {
+				return m.CreateIndex(schema.DecisionTableName, "code", false, "code")
+			},
+		},
+		{
+			Version:     11,
+			Description: "流程实例变量唯一索引",
+			Statements: map[db.Dialect][]string{
+				db.MySQL: {
+					"DELETE v1 FROM f_flow_variable v1 JOIN f_flow_variable v2 ON v1.flow_instance_id=v2.flow_instance_id AND v1.name=v2.name AND v1.id<v2.id",
+				},
+				db.Postgres: {
+					"DELETE FROM f_flow_variable v1 USING f_flow_variable v2 WHERE v1.flow_instance_id=v2.flow_instance_id AND v1.name=v2.name AND v1.id<v2.id",
+				},
+			},
+			Up: func(m *db.DB) error {
+				return m.CreateIndex(schema.FlowVariableTableName, "flow_instance_name", true, "flow_instance_id", "name")
+			},
+		},
+		{
+			Version:     12,
+			Description: "扩展流程说明字段长度",
+			Statements: map[db.Dialect][]string{
+				db.MySQL: {
+					"ALTER TABLE f_flow MODIFY COLUMN memo TEXT",
+				},
+				db.Postgres: {
+					"ALTER TABLE f_flow ALTER COLUMN memo TYPE TEXT",
+				},
+			},
+		},
+	}
+}
+
+type flowVersion struct {
+	Code    string `db:"code"`
+	Version int64  `db:"version"`
+}
+
+// 启用已有流程的最新版本(保持升级前按最大版本号发起流程的行为)
+func activateLatestFlows(m *db.DB) error {
+	query := fmt.Sprintf("SELECT code,MAX(version) AS version FROM %s WHERE deleted=0 AND flag=1 AND status=1 GROUP BY code", schema.FlowTableName)
+
+	var items []*flowVersion
+	_, err := m.Select(&items, query)
+	if err != nil {
+		return errors.Wrapf(err, "查询流程最新版本发生错误")
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET activated=1 WHERE deleted=0 AND flag=1 AND status=1 AND code=? AND version=?", schema.FlowTableName)
+	for _, item := range items {
+		_, err = m.Exec(query, item.Code, item.Version)
+		if err != nil {
+			return errors.Wrapf(err, "启用流程(%s)的最新版本发生错误", item.Code)
+		}
+	}
+	return nil
+}
+
+// 创建流程数据索引(原doc/index.sql)
+func createFlowIndexes(m *db.DB) error {
+	indexes := []struct {
+		table  string
+		column string
+		unique bool
+	}{
+		{schema.NodeInstanceTableName, "record_id", true},
+		{schema.NodeInstanceTableName, "flow_instance_id", false},
+		{schema.NodeInstanceTableName, "node_id", false},
+		{schema.NodeInstanceTableName, "deleted", false},
+		{schema.NodeInstanceTableName, "status", false},
+		{schema.FlowInstanceTableName, "record_id", true},
+		{schema.FlowInstanceTableName, "flow_id", false},
+		{schema.FlowInstanceTableName, "status", false},
+		{schema.FlowInstanceTableName, "deleted", false},
+		{schema.NodeTableName, "record_id", true},
+		{schema.NodeTableName, "deleted", false},
+		{schema.FormTableName, "record_id", true},
+		{schema.FormTableName, "deleted", false},
+		{schema.FlowTableName, "record_id", true},
+		{schema.FlowTableName, "code", false},
+		{schema.FlowTableName, "flag", false},
+		{schema.FlowTableName, "deleted", false},
+		{schema.NodeCandidateTableName, "record_id", true},
+		{schema.NodeCandidateTableName, "candidate_id", false},
+		{schema.NodeCandidateTableName, "deleted", false},
+		{schema.NodeRouterTableName, "record_id", true},
+		{schema.NodeRouterTableName, "source_node_id", false},
+		{schema.NodeRouterTableName, "deleted", false},
+		{schema.NodeAssignmentTableName, "record_id", true},
+		{schema.NodeAssignmentTableName, "node_id", false},
+		{schema.NodeAssignmentTableName, "deleted", false},
+	}
+
+	for _, item := range indexes {
+		err := m.CreateIndex(item.table, item.column, item.unique, item.column)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
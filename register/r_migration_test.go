@@ -0,0 +1,21 @@
+package register
+
+import (
+	"testing"
+)
+
+func TestFlowMigrations(t *testing.T) {
+	var last int64
+	for _, item := range FlowMigrations() {
+		if item.Version <= last {
+			t.Errorf("migration version %d must be greater than %d", item.Version, last)
+		}
+		if item.Description == "" {
+			t.Errorf("migration %d has no description", item.Version)
+		}
+		if item.Statements == nil && item.Up == nil {
+			t.Errorf("migration %d has nothing to do", item.Version)
+		}
+		last = item.Version
+	}
+}
@@ -16,21 +16,44 @@ const (
 	FieldOptionTableName     = "f_field_option"
 	FieldPropertyTableName   = "f_field_property"
 	FieldValidationTableName = "f_field_validation"
+	FlowVariableTableName    = "f_flow_variable"
+	VariableHistoryTableName = "f_flow_variable_history"
+	RequestTableName         = "f_idempotent_request"
+	DeploymentTableName      = "f_flow_deployment"
+	InstanceLogTableName     = "f_instance_log"
+	FlowPropertyTableName    = "f_flow_property"
+	ExpressionTraceTableName = "f_expression_trace"
+	DecisionTableName        = "f_decision"
 )
 
 // Flow 流程
 type Flow struct {
+	ID        int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`     // 唯一标识(自增ID)
+	RecordID  string `db:"record_id,size:36" structs:"record_id" json:"record_id"` // 记录内码(uuid)
+	Code      string `db:"code,size:50" structs:"code" json:"code"`                // 流程编号
+	Name      string `db:"name,size:50" structs:"name" json:"name"`                // 流程名称
+	Version   int64  `db:"version" structs:"version" json:"version"`               // 版本号
+	TypeCode  string `db:"type_code,size:50" structs:"type_code" json:"type_code"` // 流程类型编号
+	XML       string `db:"xml,size:2147483647" structs:"xml" json:"xml"`           // XML数据
+	Memo      string `db:"memo,size:65535" structs:"memo" json:"memo"`             // 流程备注(BPMN中的流程说明)
+	Flag      int64  `db:"flag" structs:"flag" json:"flag"`                        // 流程标志(1:主流程 2:子流程)
+	ParentID  string `db:"parent_id,size:36" structs:"parent_id" json:"parent_id"` // 父级流程内码
+	Status    int    `db:"status" structs:"status" json:"status"`                  // 流程状态(1:正常 2:禁用)
+	Activated int    `db:"activated" structs:"activated" json:"activated"`         // 是否为启用的版本(1:是 0:否，新发起的流程使用启用的版本)
+	Created   int64  `db:"created" structs:"created" json:"created"`               // 创建时间戳
+	Updated   int64  `db:"updated" structs:"updated" json:"updated"`               // 更新时间戳
+	Deleted   int64  `db:"deleted" structs:"deleted" json:"deleted"`               // 删除时间戳
+
+	Properties map[string]string `db:"-" structs:"-" json:"properties,omitempty"` // 流程扩展属性(不存储在流程表中)
+}
+
+// FlowProperty 流程扩展属性
+type FlowProperty struct {
 	ID       int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`     // 唯一标识(自增ID)
 	RecordID string `db:"record_id,size:36" structs:"record_id" json:"record_id"` // 记录内码(uuid)
-	Code     string `db:"code,size:50" structs:"code" json:"code"`                // 流程编号
-	Name     string `db:"name,size:50" structs:"name" json:"name"`                // 流程名称
-	Version  int64  `db:"version" structs:"version" json:"version"`               // 版本号
-	TypeCode string `db:"type_code,size:50" structs:"type_code" json:"type_code"` // 流程类型编号
-	XML      string `db:"xml,size:1024" structs:"xml" json:"xml"`                 // XML数据
-	Memo     string `db:"memo,size:255" structs:"memo" json:"memo"`               // 流程备注
-	Flag     int64  `db:"flag" structs:"flag" json:"flag"`                        // 流程标志(1:主流程 2:子流程)
-	ParentID string `db:"parent_id,size:36" structs:"parent_id" json:"parent_id"` // 父级流程内码
-	Status   int    `db:"status" structs:"status" json:"status"`                  // 流程状态(1:正常 2:禁用)
+	FlowID   string `db:"flow_id,size:36" structs:"flow_id" json:"flow_id"`       // 流程内码
+	Name     string `db:"name,size:50" structs:"name" json:"name"`                // 属性名称
+	Value    string `db:"value,size:255" structs:"value" json:"value"`            // 属性值
 	Created  int64  `db:"created" structs:"created" json:"created"`               // 创建时间戳
 	Updated  int64  `db:"updated" structs:"updated" json:"updated"`               // 更新时间戳
 	Deleted  int64  `db:"deleted" structs:"deleted" json:"deleted"`               // 删除时间戳
@@ -109,9 +132,10 @@ type NodeInstance struct {
 	NodeID         string `db:"node_id,size:36" structs:"node_id" json:"node_id"`                            // 节点内码
 	Processor      string `db:"processor,size:36" structs:"processor" json:"processor"`                      // 处理人
 	ProcessTime    int64  `db:"process_time" structs:"process_time" json:"process_time"`                     // 处理时间(秒时间戳)
-	InputData      string `db:"input_data,size:1024" structs:"input_data" json:"input_data"`                 // 输入数据
-	OutData        string `db:"out_data,size:1024" structs:"out_data" json:"out_data"`                       // 输出数据
-	Status         int64  `db:"status" structs:"status" json:"status"`                                       // 处理状态(1:待处理 2:已完成)
+	InputData      string `db:"input_data,size:2147483647" structs:"input_data" json:"input_data"`           // 输入数据
+	OutData        string `db:"out_data,size:2147483647" structs:"out_data" json:"out_data"`                 // 输出数据
+	InputRef       string `db:"input_ref,size:255" structs:"input_ref" json:"input_ref"`                     // 外部存储的输入数据引用
+	Status         int64  `db:"status" structs:"status" json:"status"`                                       // 处理状态(1:待处理 2:已完成 3:已取消)
 	Created        int64  `db:"created" structs:"created" json:"created"`                                    // 创建时间戳
 	Updated        int64  `db:"updated" structs:"updated" json:"updated"`                                    // 更新时间戳
 	Deleted        int64  `db:"deleted" structs:"deleted" json:"deleted"`                                    // 删除时间戳
@@ -123,7 +147,7 @@ type NodeTiming struct {
 	NodeInstanceID string `db:"node_instance_id" structs:"node_instance_id" json:"node_instance_id"` // 节点实例ID
 	Flag           string `db:"flag" structs:"flag" json:"flag"`                                     // 标志
 	Processor      string `db:"processor,size:36" structs:"processor" json:"processor"`              // 处理人
-	Input          string `db:"input,size:1024" structs:"input" json:"input"`                        // 输入数据
+	Input          string `db:"input,size:2147483647" structs:"input" json:"input"`                  // 输入数据
 	ExpiredAt      int64  `db:"expired_at" structs:"expired_at" json:"expired_at"`                   // 过期时间戳
 	Created        int64  `db:"created" structs:"created" json:"created"`                            // 创建时间戳
 	Deleted        int64  `db:"deleted" structs:"deleted" json:"deleted"`                            // 删除时间戳
@@ -204,12 +228,124 @@ type FieldValidation struct {
 	Deleted          int64  `db:"deleted" structs:"deleted" json:"deleted"`                                        // 删除时间戳
 }
 
+// FlowVariable 流程实例变量
+type FlowVariable struct {
+	ID             int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`                          // 唯一标识(自增ID)
+	RecordID       string `db:"record_id,size:36" structs:"record_id" json:"record_id"`                      // 记录内码(uuid)
+	FlowInstanceID string `db:"flow_instance_id,size:36" structs:"flow_instance_id" json:"flow_instance_id"` // 流程实例内码
+	Name           string `db:"name,size:100" structs:"name" json:"name"`                                    // 变量名称
+	TypeCode       string `db:"type_code,size:20" structs:"type_code" json:"type_code"`                      // 变量类型(string,number,bool,json,null)
+	Value          string `db:"value,size:65535" structs:"value" json:"value"`                               // 变量值
+	Created        int64  `db:"created" structs:"created" json:"created"`                                    // 创建时间戳
+	Updated        int64  `db:"updated" structs:"updated" json:"updated"`                                    // 更新时间戳
+	Deleted        int64  `db:"deleted" structs:"deleted" json:"deleted"`                                    // 删除时间戳
+}
+
+// VariableHistory 流程实例变量变更历史
+type VariableHistory struct {
+	ID             int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`                          // 唯一标识(自增ID)
+	RecordID       string `db:"record_id,size:36" structs:"record_id" json:"record_id"`                      // 记录内码(uuid)
+	FlowInstanceID string `db:"flow_instance_id,size:36" structs:"flow_instance_id" json:"flow_instance_id"` // 流程实例内码
+	NodeInstanceID string `db:"node_instance_id,size:36" structs:"node_instance_id" json:"node_instance_id"` // 节点实例内码(通过接口直接修改时为空)
+	Name           string `db:"name,size:100" structs:"name" json:"name"`                                    // 变量名称
+	TypeCode       string `db:"type_code,size:20" structs:"type_code" json:"type_code"`                      // 变量类型
+	OldValue       string `db:"old_value,size:65535" structs:"old_value" json:"old_value"`                   // 变更前的值
+	Value          string `db:"value,size:65535" structs:"value" json:"value"`                               // 变更后的值
+	Operator       string `db:"operator,size:36" structs:"operator" json:"operator"`                         // 操作人
+	Created        int64  `db:"created" structs:"created" json:"created"`                                    // 创建时间戳
+	Deleted        int64  `db:"deleted" structs:"deleted" json:"deleted"`                                    // 删除时间戳
+}
+
+// IdempotentRequest 幂等请求
+type IdempotentRequest struct {
+	ID             int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`                          // 唯一标识(自增ID)
+	RequestKey     string `db:"request_key,size:100" structs:"request_key" json:"request_key"`               // 幂等键(客户端生成)
+	Action         string `db:"action,size:20" structs:"action" json:"action"`                               // 请求动作(start:启动流程 launch:发起流程 handle:处理流程)
+	UserID         string `db:"user_id,size:36" structs:"user_id" json:"user_id"`                            // 请求人
+	FlowInstanceID string `db:"flow_instance_id,size:36" structs:"flow_instance_id" json:"flow_instance_id"` // 流程实例内码
+	NodeInstanceID string `db:"node_instance_id,size:36" structs:"node_instance_id" json:"node_instance_id"` // 节点实例内码
+	Result         string `db:"result,size:2147483647" structs:"result" json:"result"`                       // 处理结果(JSON)
+	Status         int64  `db:"status" structs:"status" json:"status"`                                       // 处理状态(1:处理中 2:已完成)
+	Created        int64  `db:"created" structs:"created" json:"created"`                                    // 创建时间戳
+	Updated        int64  `db:"updated" structs:"updated" json:"updated"`                                    // 更新时间戳
+}
+
+// FlowDeployment 流程部署记录
+type FlowDeployment struct {
+	ID       int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`     // 唯一标识(自增ID)
+	RecordID string `db:"record_id,size:36" structs:"record_id" json:"record_id"` // 记录内码(uuid)
+	FlowID   string `db:"flow_id,size:36" structs:"flow_id" json:"flow_id"`       // 流程内码
+	FlowCode string `db:"flow_code,size:50" structs:"flow_code" json:"flow_code"` // 流程编号
+	Version  int64  `db:"version" structs:"version" json:"version"`               // 版本号
+	Checksum string `db:"checksum,size:64" structs:"checksum" json:"checksum"`    // 流程定义数据的校验和(sha256)
+	Comment  string `db:"comment,size:255" structs:"comment" json:"comment"`      // 部署说明
+	Author   string `db:"author,size:36" structs:"author" json:"author"`          // 部署人
+	Created  int64  `db:"created" structs:"created" json:"created"`               // 创建时间戳
+}
+
+// InstanceLog 流程实例操作日志
+type InstanceLog struct {
+	ID             int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`                          // 唯一标识(自增ID)
+	RecordID       string `db:"record_id,size:36" structs:"record_id" json:"record_id"`                      // 记录内码(uuid)
+	FlowInstanceID string `db:"flow_instance_id,size:36" structs:"flow_instance_id" json:"flow_instance_id"` // 流程实例内码
+	Action         string `db:"action,size:20" structs:"action" json:"action"`                               // 操作类型(migrate:迁移流程版本 move:移动到指定节点)
+	Operator       string `db:"operator,size:36" structs:"operator" json:"operator"`                         // 操作人
+	Data           string `db:"data,size:2147483647" structs:"data" json:"data"`                             // 操作数据(JSON)
+	Created        int64  `db:"created" structs:"created" json:"created"`                                    // 创建时间戳
+}
+
+// ExpressionTrace 表达式执行记录(启用表达式跟踪时记录节点流转过程中执行的表达式)
+type ExpressionTrace struct {
+	ID             int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`                          // 唯一标识(自增ID)
+	RecordID       string `db:"record_id,size:36" structs:"record_id" json:"record_id"`                      // 记录内码(uuid)
+	FlowInstanceID string `db:"flow_instance_id,size:36" structs:"flow_instance_id" json:"flow_instance_id"` // 流程实例内码
+	NodeInstanceID string `db:"node_instance_id,size:36" structs:"node_instance_id" json:"node_instance_id"` // 节点实例内码(流转的源节点实例)
+	RouterID       string `db:"router_id,size:36" structs:"router_id" json:"router_id"`                      // 节点路由内码
+	TargetNodeID   string `db:"target_node_id,size:36" structs:"target_node_id" json:"target_node_id"`       // 目标节点内码
+	Kind           string `db:"kind,size:20" structs:"kind" json:"kind"`                                     // 表达式类型(router:连线条件 assignment:指派表达式)
+	Expression     string `db:"expression,size:65535" structs:"expression" json:"expression"`                // 表达式
+	Params         string `db:"params,size:2147483647" structs:"params" json:"params"`                       // 表达式数据(JSON)
+	Result         string `db:"result,size:65535" structs:"result" json:"result"`                            // 执行结果(JSON)
+	Error          string `db:"error,size:65535" structs:"error" json:"error"`                               // 执行错误
+	Elapsed        int64  `db:"elapsed" structs:"elapsed" json:"elapsed"`                                    // 执行耗时(微秒)
+	Created        int64  `db:"created" structs:"created" json:"created"`                                    // 创建时间戳
+}
+
+// Decision 决策表(DMN)部署记录
+type Decision struct {
+	ID       int64  `db:"id,primarykey,autoincrement" structs:"id" json:"id"`     // 唯一标识(自增ID)
+	RecordID string `db:"record_id,size:36" structs:"record_id" json:"record_id"` // 记录内码(uuid)
+	Code     string `db:"code,size:50" structs:"code" json:"code"`                // 决策表编号(DMN中decision的id)
+	Name     string `db:"name,size:50" structs:"name" json:"name"`                // 决策表名称
+	Version  int64  `db:"version" structs:"version" json:"version"`               // 版本号
+	Data     string `db:"data,size:2147483647" structs:"data" json:"data"`        // DMN文件数据
+	Checksum string `db:"checksum,size:64" structs:"checksum" json:"checksum"`    // DMN文件数据的校验和(sha256)
+	Comment  string `db:"comment,size:255" structs:"comment" json:"comment"`      // 部署说明
+	Author   string `db:"author,size:36" structs:"author" json:"author"`          // 部署人
+	Created  int64  `db:"created" structs:"created" json:"created"`               // 创建时间戳
+}
+
+// FlowDeploymentResult 流程部署记录查询结果
+type FlowDeploymentResult struct {
+	RecordID  string `db:"record_id" structs:"record_id" json:"record_id"` // 记录内码
+	FlowID    string `db:"flow_id" structs:"flow_id" json:"flow_id"`       // 流程内码
+	FlowCode  string `db:"flow_code" structs:"flow_code" json:"flow_code"` // 流程编号
+	FlowName  string `db:"flow_name" structs:"flow_name" json:"flow_name"` // 流程名称
+	Version   int64  `db:"version" structs:"version" json:"version"`       // 版本号
+	Checksum  string `db:"checksum" structs:"checksum" json:"checksum"`    // 流程定义数据的校验和
+	Comment   string `db:"comment" structs:"comment" json:"comment"`       // 部署说明
+	Author    string `db:"author" structs:"author" json:"author"`          // 部署人
+	Activated int    `db:"activated" structs:"activated" json:"activated"` // 是否为启用的版本(1:是 0:否)
+	Created   int64  `db:"created" structs:"created" json:"created"`       // 部署时间戳
+}
+
 // FlowQueryParam 流程查询参数
 type FlowQueryParam struct {
-	Code     string // 流程编号
-	Name     string // 流程名称
-	TypeCode string // 流程类型编号
-	Status   int    // 流程状态(1:正常 2:禁用)
+	Code       string            // 流程编号
+	Name       string            // 流程名称
+	TypeCode   string            // 流程类型编号
+	Status     int               // 流程状态(1:正常 2:禁用)
+	Properties map[string]string // 流程扩展属性(属性名称->属性值)
 }
 
 // FlowQueryResult 流程查询结果
@@ -222,7 +358,7 @@ type FlowQueryResult struct {
 	TypeCode string `db:"type_code,size:50" structs:"type_code" json:"type_code"` // 流程类型编号
 	Status   int    `db:"status" structs:"status" json:"status"`                  // 流程状态(1:正常 2:禁用)
 	Created  int64  `db:"created" structs:"created" json:"created"`               // 创建时间戳
-	Memo     string `db:"memo,size:255" structs:"memo" json:"memo"`               // 流程备注
+	Memo     string `db:"memo,size:65535" structs:"memo" json:"memo"`             // 流程备注
 }
 
 // FlowTodoResult 流程待办结果
@@ -234,6 +370,7 @@ type FlowTodoResult struct {
 	NodeCode       string  `db:"node_code" structs:"node_code" json:"node_code"`                      // 节点编号
 	NodeName       string  `db:"node_name" structs:"node_name" json:"node_name"`                      // 节点名称
 	InputData      string  `db:"input_data" structs:"input_data" json:"input_data"`                   // 输入数据
+	InputRef       string  `db:"input_ref" structs:"input_ref" json:"-"`                              // 外部存储的输入数据引用
 	Launcher       string  `db:"launcher" structs:"launcher" json:"launcher"`                         // 发起人
 	LaunchTime     int64   `db:"launch_time" structs:"launch_time" json:"launch_time"`                // 发起时间
 	FormType       *string `db:"form_type" structs:"form_type" json:"form_type"`                      // 表单类型
@@ -249,6 +386,7 @@ type FlowHistoryResult struct {
 	Processor   string  `db:"processor,size:36" structs:"processor" json:"processor"`      // 处理人
 	ProcessTime int64   `db:"process_time" structs:"process_time" json:"process_time"`     // 处理时间(秒时间戳)
 	InputData   string  `db:"input_data,size:1024" structs:"input_data" json:"input_data"` // 输入数据
+	InputRef    string  `db:"input_ref" structs:"input_ref" json:"-"`                      // 外部存储的输入数据引用
 	OutData     string  `db:"out_data,size:1024" structs:"out_data" json:"out_data"`       // 输出数据
 	Status      int64   `db:"status" structs:"status" json:"status"`                       // 处理状态(1:待处理 2:已完成)
 	FormType    *string `db:"form_type" structs:"form_type" json:"form_type"`              // 表单类型
@@ -292,10 +430,11 @@ type FlowWebInstanceResult struct{
 
 // NodeOperating 节点操作
 type NodeOperating struct {
-	NodeGroup       []*Node
-	RouterGroup     []*NodeRouter
-	AssignmentGroup []*NodeAssignment
-	PropertyGroup   []*NodeProperty
+	NodeGroup         []*Node
+	RouterGroup       []*NodeRouter
+	AssignmentGroup   []*NodeAssignment
+	PropertyGroup     []*NodeProperty
+	FlowPropertyGroup []*FlowProperty
 }
 
 // All 获取所有节点操作的组
@@ -314,6 +453,9 @@ func (a *NodeOperating) All() []interface{} {
 	for _, item := range a.PropertyGroup {
 		group = append(group, item)
 	}
+	for _, item := range a.FlowPropertyGroup {
+		group = append(group, item)
+	}
 
 	return group
 }
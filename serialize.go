@@ -0,0 +1,434 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+// Serializer 流程数据序列化器(与Parser相反，将流程数据转换为流程定义数据)
+type Serializer interface {
+	// 序列化流程数据
+	Serialize(ctx context.Context, result *ParseResult) ([]byte, error)
+}
+
+type xmlSerializerOptions struct {
+	source []byte
+}
+
+// XMLSerializerOption xml序列化器配置
+type XMLSerializerOption func(*xmlSerializerOptions)
+
+// DiagramSourceOption 从原始BPMN文件中复制流程图的坐标数据(BPMN DI)及连线ID
+func DiagramSourceOption(source []byte) XMLSerializerOption {
+	return func(o *xmlSerializerOptions) {
+		o.source = source
+	}
+}
+
+// NewXMLSerializer xml序列化器
+func NewXMLSerializer(opts ...XMLSerializerOption) Serializer {
+	var o xmlSerializerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &xmlSerializer{opts: o}
+}
+
+type xmlSerializer struct {
+	opts xmlSerializerOptions
+}
+
+// BPMN文件中使用的命名空间
+var xmlSerializerNamespaces = [][2]string{
+	{"xmlns:bpmn", bpmnModelNamespace},
+	{"xmlns:bpmndi", "http://www.omg.org/spec/BPMN/20100524/DI"},
+	{"xmlns:di", "http://www.omg.org/spec/DD/20100524/DI"},
+	{"xmlns:dc", "http://www.omg.org/spec/DD/20100524/DC"},
+	{"xmlns:camunda", "http://camunda.org/schema/1.0/bpmn"},
+	{"xmlns:xsi", "http://www.w3.org/2001/XMLSchema-instance"},
+}
+
+// 流程图元素的命名空间前缀
+var diagramElementSpaces = map[string]string{
+	"BPMNDiagram": "bpmndi",
+	"BPMNPlane":   "bpmndi",
+	"BPMNShape":   "bpmndi",
+	"BPMNEdge":    "bpmndi",
+	"BPMNLabel":   "bpmndi",
+	"Bounds":      "dc",
+	"waypoint":    "di",
+}
+
+func (s *xmlSerializer) Serialize(ctx context.Context, result *ParseResult) ([]byte, error) {
+	source := s.loadSource(result.FlowID)
+
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+
+	definitions := doc.CreateElement("bpmn:definitions")
+	for _, ns := range xmlSerializerNamespaces {
+		definitions.CreateAttr(ns[0], ns[1])
+	}
+	definitions.CreateAttr("id", fmt.Sprintf("Definitions_%s", result.FlowID))
+	definitions.CreateAttr("targetNamespace", "http://bpmn.io/schema/bpmn")
+
+	process := definitions.CreateElement("bpmn:process")
+	process.CreateAttr("id", result.FlowID)
+	if result.FlowName != "" {
+		process.CreateAttr("name", result.FlowName)
+	}
+	process.CreateAttr("isExecutable", fmt.Sprint(result.FlowStatus == 1))
+	if result.FlowVersion > 0 {
+		process.CreateAttr("camunda:versionTag", fmt.Sprint(result.FlowVersion))
+	}
+	s.writeProcessMetadata(process, result)
+
+	// 生成连线ID，优先使用原始文件中相同源节点及目标节点的连线ID
+	type flowRef struct {
+		id     string
+		source string
+		router *RouterResult
+	}
+	var (
+		flows    []*flowRef
+		incoming = make(map[string][]string)
+		outgoing = make(map[string][]string)
+		usedIDs  = make(map[string]bool)
+	)
+	for _, node := range result.Nodes {
+		for _, r := range node.Routers {
+			key := node.NodeID + "->" + r.TargetNodeID
+			id := ""
+			if ids := source.flowIDs[key]; len(ids) > 0 {
+				id, source.flowIDs[key] = ids[0], ids[1:]
+			} else {
+				for i := len(flows) + 1; id == "" || usedIDs[id]; i++ {
+					id = fmt.Sprintf("SequenceFlow_%d", i)
+				}
+			}
+			usedIDs[id] = true
+
+			flows = append(flows, &flowRef{id: id, source: node.NodeID, router: r})
+			outgoing[node.NodeID] = append(outgoing[node.NodeID], id)
+			incoming[r.TargetNodeID] = append(incoming[r.TargetNodeID], id)
+		}
+	}
+
+	for _, node := range result.Nodes {
+		err := s.writeNode(process, node, incoming[node.NodeID], outgoing[node.NodeID])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range flows {
+		seq := process.CreateElement("bpmn:sequenceFlow")
+		seq.CreateAttr("id", f.id)
+		seq.CreateAttr("sourceRef", f.source)
+		seq.CreateAttr("targetRef", f.router.TargetNodeID)
+		if f.router.Explain != "" {
+			seq.CreateElement("bpmn:documentation").SetText(f.router.Explain)
+		}
+		if f.router.Expression != "" {
+			exp := seq.CreateElement("bpmn:conditionExpression")
+			exp.CreateAttr("xsi:type", "bpmn:tFormalExpression")
+			exp.SetText(f.router.Expression)
+		}
+	}
+
+	if source.diagram != nil {
+		elementIDs := make(map[string]bool)
+		for _, node := range result.Nodes {
+			elementIDs[node.NodeID] = true
+		}
+		for id := range usedIDs {
+			elementIDs[id] = true
+		}
+		definitions.AddChild(s.copyDiagram(source.diagram, result.FlowID, elementIDs))
+	}
+
+	doc.Indent(2)
+	return doc.WriteToBytes()
+}
+
+// 写入流程说明及扩展属性(流程类别作为名称为category的扩展属性)
+func (s *xmlSerializer) writeProcessMetadata(process *etree.Element, result *ParseResult) {
+	if result.FlowDocumentation != "" {
+		process.CreateElement("bpmn:documentation").SetText(result.FlowDocumentation)
+	}
+
+	if result.FlowCategory == "" && len(result.FlowProperties) == 0 {
+		return
+	}
+
+	properties := process.CreateElement("bpmn:extensionElements").CreateElement("camunda:properties")
+	if result.FlowCategory != "" {
+		property := properties.CreateElement("camunda:property")
+		property.CreateAttr("name", "category")
+		property.CreateAttr("value", result.FlowCategory)
+	}
+	for _, p := range result.FlowProperties {
+		property := properties.CreateElement("camunda:property")
+		property.CreateAttr("name", p.Name)
+		property.CreateAttr("value", p.Value)
+	}
+}
+
+func (s *xmlSerializer) writeNode(process *etree.Element, node *NodeResult, incoming, outgoing []string) error {
+	tag := node.NodeType.String()
+	if node.NodeType == TerminateEvent {
+		tag = EndEvent.String()
+	}
+
+	element := process.CreateElement("bpmn:" + tag)
+	element.CreateAttr("id", node.NodeID)
+	if node.NodeName != "" {
+		element.CreateAttr("name", node.NodeName)
+	}
+
+	switch len(node.CandidateExpressions) {
+	case 0:
+	case 1:
+		element.CreateAttr("camunda:candidateUsers", node.CandidateExpressions[0])
+	default:
+		return fmt.Errorf("节点(%s)包含多个候选人表达式，无法转换为BPMN", node.NodeID)
+	}
+
+	form := node.FormResult
+	if form != nil && form.ID != "" {
+		element.CreateAttr("camunda:formKey", form.ID)
+	}
+
+	if (form != nil && len(form.Fields) > 0) || len(node.Properties) > 0 {
+		extension := element.CreateElement("bpmn:extensionElements")
+		if form != nil && len(form.Fields) > 0 {
+			s.writeFormData(extension, form)
+		}
+		if len(node.Properties) > 0 {
+			properties := extension.CreateElement("camunda:properties")
+			for _, p := range node.Properties {
+				property := properties.CreateElement("camunda:property")
+				property.CreateAttr("name", p.Name)
+				property.CreateAttr("value", p.Value)
+			}
+		}
+	}
+
+	for _, id := range incoming {
+		element.CreateElement("bpmn:incoming").SetText(id)
+	}
+	for _, id := range outgoing {
+		element.CreateElement("bpmn:outgoing").SetText(id)
+	}
+
+	if node.NodeType == TerminateEvent {
+		element.CreateElement("bpmn:terminateEventDefinition")
+	}
+	return nil
+}
+
+func (s *xmlSerializer) writeFormData(extension *etree.Element, form *NodeFormResult) {
+	formData := extension.CreateElement("camunda:formData")
+	for _, f := range form.Fields {
+		field := formData.CreateElement("camunda:formField")
+		field.CreateAttr("id", f.ID)
+		if f.Label != "" {
+			field.CreateAttr("label", f.Label)
+		}
+		if f.Type != "" {
+			field.CreateAttr("type", f.Type)
+		}
+		if f.DefaultValue != "" {
+			field.CreateAttr("defaultValue", f.DefaultValue)
+		}
+
+		if len(f.Properties) > 0 {
+			properties := field.CreateElement("camunda:properties")
+			for _, p := range f.Properties {
+				property := properties.CreateElement("camunda:property")
+				property.CreateAttr("id", p.ID)
+				property.CreateAttr("value", p.Value)
+			}
+		}
+
+		if len(f.Validations) > 0 {
+			validation := field.CreateElement("camunda:validation")
+			for _, v := range f.Validations {
+				constraint := validation.CreateElement("camunda:constraint")
+				constraint.CreateAttr("name", v.Name)
+				if v.Config != "" {
+					constraint.CreateAttr("config", v.Config)
+				}
+			}
+		}
+
+		for _, v := range f.Values {
+			value := field.CreateElement("camunda:value")
+			value.CreateAttr("id", v.ID)
+			value.CreateAttr("name", v.Name)
+		}
+	}
+}
+
+type xmlSource struct {
+	flowIDs map[string][]string // 源节点->目标节点 映射到连线ID
+	diagram *etree.Element
+}
+
+// 加载原始BPMN文件中的连线ID及流程图
+func (s *xmlSerializer) loadSource(processID string) *xmlSource {
+	source := &xmlSource{flowIDs: make(map[string][]string)}
+	if len(s.opts.source) == 0 {
+		return source
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(s.opts.source); err != nil {
+		return source
+	}
+
+	root := doc.Root()
+	if root == nil {
+		return source
+	}
+
+	processes := selectBPMNElements(root, "process")
+	for _, process := range processes {
+		if id := process.SelectAttr("id"); id == nil || id.Value != processID {
+			continue
+		}
+
+		for _, seq := range selectBPMNElements(process, "sequenceFlow") {
+			id, sourceRef, targetRef := seq.SelectAttr("id"), seq.SelectAttr("sourceRef"), seq.SelectAttr("targetRef")
+			if id == nil || sourceRef == nil || targetRef == nil {
+				continue
+			}
+			key := sourceRef.Value + "->" + targetRef.Value
+			source.flowIDs[key] = append(source.flowIDs[key], id.Value)
+		}
+	}
+
+	var diagrams []*etree.Element
+	for _, e := range root.ChildElements() {
+		if e.Tag == "BPMNDiagram" {
+			diagrams = append(diagrams, e)
+		}
+	}
+
+	for _, diagram := range diagrams {
+		if plane := diagram.SelectElement("BPMNPlane"); plane != nil {
+			if ref := plane.SelectAttr("bpmnElement"); ref != nil && ref.Value == processID {
+				source.diagram = diagram
+				return source
+			}
+		}
+	}
+
+	// 协作图中流程图的平面指向协作元素，只有一个流程时也可以使用
+	if len(diagrams) > 0 && len(processes) == 1 {
+		source.diagram = diagrams[0]
+	}
+	return source
+}
+
+// 复制流程图，移除不属于当前流程的元素并统一命名空间前缀
+func (s *xmlSerializer) copyDiagram(diagram *etree.Element, processID string, elementIDs map[string]bool) *etree.Element {
+	diagram = diagram.Copy()
+	s.normalizeDiagramSpace(diagram)
+
+	if plane := diagram.SelectElement("BPMNPlane"); plane != nil {
+		if ref := plane.SelectAttr("bpmnElement"); ref != nil {
+			ref.Value = processID
+		}
+
+		for _, e := range plane.ChildElements() {
+			ref := e.SelectAttr("bpmnElement")
+			if ref == nil || !elementIDs[ref.Value] {
+				plane.RemoveChild(e)
+			}
+		}
+	}
+	return diagram
+}
+
+func (s *xmlSerializer) normalizeDiagramSpace(element *etree.Element) {
+	if space, ok := diagramElementSpaces[element.Tag]; ok {
+		element.Space = space
+	}
+	for _, e := range element.ChildElements() {
+		s.normalizeDiagramSpace(e)
+	}
+}
+
+// NewJSONSerializer json序列化器(格式参考doc/json_flow.md)
+func NewJSONSerializer() Serializer {
+	return &jsonSerializer{}
+}
+
+type jsonSerializer struct {
+}
+
+func (s *jsonSerializer) Serialize(ctx context.Context, result *ParseResult) ([]byte, error) {
+	executable := result.FlowStatus == 1
+	item := &jsonFlow{
+		ID:            result.FlowID,
+		Name:          result.FlowName,
+		Version:       result.FlowVersion,
+		Executable:    &executable,
+		Category:      result.FlowCategory,
+		Documentation: result.FlowDocumentation,
+	}
+	for _, p := range result.FlowProperties {
+		item.Properties = append(item.Properties, &jsonProperty{Name: p.Name, Value: p.Value})
+	}
+
+	for _, node := range result.Nodes {
+		n := &jsonNode{
+			ID:         node.NodeID,
+			Name:       node.NodeName,
+			Type:       node.NodeType.String(),
+			Candidates: node.CandidateExpressions,
+		}
+
+		for _, p := range node.Properties {
+			n.Properties = append(n.Properties, &jsonProperty{Name: p.Name, Value: p.Value})
+		}
+
+		if form := node.FormResult; form != nil && (form.ID != "" || len(form.Fields) > 0) {
+			n.Form = &jsonForm{ID: form.ID}
+			for _, f := range form.Fields {
+				field := &jsonField{
+					ID:           f.ID,
+					Type:         f.Type,
+					Label:        f.Label,
+					DefaultValue: f.DefaultValue,
+				}
+				for _, v := range f.Values {
+					field.Values = append(field.Values, &jsonOption{ID: v.ID, Name: v.Name})
+				}
+				for _, v := range f.Validations {
+					field.Validations = append(field.Validations, &jsonValidation{Name: v.Name, Config: v.Config})
+				}
+				for _, v := range f.Properties {
+					field.Properties = append(field.Properties, &jsonFieldProp{ID: v.ID, Value: v.Value})
+				}
+				n.Form.Fields = append(n.Form.Fields, field)
+			}
+		}
+
+		for _, r := range node.Routers {
+			n.Routers = append(n.Routers, &jsonRouter{
+				Target:     r.TargetNodeID,
+				Explain:    r.Explain,
+				Expression: r.Expression,
+			})
+		}
+
+		item.Nodes = append(item.Nodes, n)
+	}
+
+	return json.MarshalIndent(item, "", "  ")
+}
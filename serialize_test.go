@@ -0,0 +1,101 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	for _, name := range []string{"test_data/route.bpmn", "test_data/form_test.bpmn", "test_data/parallel_test.bpmn"} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := NewXMLParser().Parse(context.Background(), data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serializers := map[string]Serializer{
+			"xml":  NewXMLSerializer(DiagramSourceOption(data)),
+			"json": NewJSONSerializer(),
+		}
+		for format, serializer := range serializers {
+			buf, err := serializer.Serialize(context.Background(), want)
+			if err != nil {
+				t.Fatalf("%s %s: %v", name, format, err)
+			}
+
+			parser := NewXMLParser()
+			if format == "json" {
+				parser = NewJSONParser()
+			}
+			got, err := parser.Parse(context.Background(), buf)
+			if err != nil {
+				t.Fatalf("%s %s: %v\n%s", name, format, err, buf)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %s: round trip mismatch\n%s", name, format, buf)
+			}
+
+			if format == "xml" && !bytes.Contains(buf, []byte("<bpmndi:BPMNShape")) {
+				t.Errorf("%s: diagram shapes were not copied", name)
+			}
+		}
+	}
+}
+
+func TestSerializeProcessMetadata(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:camunda="http://camunda.org/schema/1.0/bpmn">
+  <bpmn:process id="leave" name="请假" isExecutable="true">
+    <bpmn:documentation> 员工请假审批 </bpmn:documentation>
+    <bpmn:extensionElements>
+      <camunda:properties>
+        <camunda:property name="category" value="hr" />
+        <camunda:property name="owner" value="admin" />
+      </camunda:properties>
+    </bpmn:extensionElements>
+    <bpmn:startEvent id="start" />
+    <bpmn:endEvent id="end" />
+    <bpmn:sequenceFlow id="f1" sourceRef="start" targetRef="end" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+	want, err := NewXMLParser().Parse(context.Background(), []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want.FlowCategory != "hr" || want.FlowDocumentation != "员工请假审批" {
+		t.Errorf("Parse() category = %q, documentation = %q", want.FlowCategory, want.FlowDocumentation)
+	}
+	if len(want.FlowProperties) != 1 || want.FlowProperties[0].Name != "owner" || want.FlowProperties[0].Value != "admin" {
+		t.Errorf("Parse() properties = %v, want [owner=admin]", want.FlowProperties)
+	}
+
+	for _, serializer := range []Serializer{NewXMLSerializer(), NewJSONSerializer()} {
+		buf, err := serializer.Serialize(context.Background(), want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parser := NewXMLParser()
+		if DetectFormat(buf) == FormatJSON {
+			parser = NewJSONParser()
+		}
+		got, err := parser.Parse(context.Background(), buf)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, buf)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip mismatch\n%s", buf)
+		}
+	}
+}
@@ -17,6 +17,7 @@ type serverOptions struct {
 	prefix      string
 	staticRoot  string
 	middlewares []gear.Middleware
+	operator    func(ctx *gear.Context) string
 }
 
 // ServerOption 流程服务配置
@@ -43,6 +44,13 @@ func ServerMiddlewareOption(middlewares ...gear.Middleware) ServerOption {
 	}
 }
 
+// ServerOperatorOption 从请求上下文中获取当前操作人(例如由认证中间件设定的用户)，用于记录流程实例的操作日志
+func ServerOperatorOption(operator func(ctx *gear.Context) string) ServerOption {
+	return func(opts *serverOptions) {
+		opts.operator = operator
+	}
+}
+
 // Server 流程管理服务
 type Server struct {
 	opts   serverOptions
@@ -119,10 +127,14 @@ func newRouterMiddleware(srv *Server) gear.Handler {
 	})
 
 	api := new(API).Init(srv.engine)
+	api.operator = srv.opts.operator
 	router.Get("/flow/page", api.QueryFlowPage)
 	router.Get("/flow/:id", api.GetFlow)
+	router.Get("/flow/:id/export", api.ExportFlow)
 	router.Delete("/flow/:id", api.DeleteFlow)
 	router.Post("/flow", api.SaveFlow)
+	router.Post("/instance/:id/move", api.MoveToNode)
+	router.Get("/node_instance/:id/explain", api.ExplainStep)
 
 	return router
 }
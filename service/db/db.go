@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 	"github.com/LyricTian/retry"
@@ -36,7 +37,18 @@ func (l *dbLogger) Printf(format string, v ...interface{}) {
 	l.logger.Printf(format, v...)
 }
 
+// Dialect 数据库方言
+type Dialect string
+
+const (
+	// MySQL MySQL数据库
+	MySQL Dialect = "mysql"
+	// Postgres PostgreSQL数据库
+	Postgres Dialect = "postgres"
+)
+
 type options struct {
+	dialect      Dialect       // 数据库方言
 	dsn          string        // 连接串
 	trace        bool          // 追踪调试
 	maxLifetime  time.Duration // 设置连接可以被重新使用的最大时间量
@@ -47,6 +59,13 @@ type options struct {
 // Option 配置项
 type Option func(*options)
 
+// SetDialect 设置数据库方言(默认为MySQL)
+func SetDialect(dialect Dialect) Option {
+	return func(o *options) {
+		o.dialect = dialect
+	}
+}
+
 // SetDSN 设置连接串
 func SetDSN(dsn string) Option {
 	return func(o *options) {
@@ -85,11 +104,25 @@ func SetMaxIdleConns(maxIdleConns int) Option {
 // DB 数据库
 type DB struct {
 	*gorp.DbMap
+	dialect Dialect
 }
 
 // NewMySQL 创建MySQL数据库实例
 func NewMySQL(opts ...Option) (*sql.DB, bool, error) {
+	db, _, trace, err := Open(append(opts, SetDialect(MySQL))...)
+	return db, trace, err
+}
+
+// NewPostgres 创建PostgreSQL数据库实例
+func NewPostgres(opts ...Option) (*sql.DB, bool, error) {
+	db, _, trace, err := Open(append(opts, SetDialect(Postgres))...)
+	return db, trace, err
+}
+
+// Open 根据配置的方言创建数据库实例
+func Open(opts ...Option) (*sql.DB, Dialect, bool, error) {
 	o := &options{
+		dialect:      MySQL,
 		maxLifetime:  time.Hour * 2,
 		maxOpenConns: 150,
 		maxIdleConns: 50,
@@ -99,9 +132,9 @@ func NewMySQL(opts ...Option) (*sql.DB, bool, error) {
 		opt(o)
 	}
 
-	db, err := sql.Open("mysql", o.dsn)
+	db, err := sql.Open(string(o.dialect), o.dsn)
 	if err != nil {
-		return nil, o.trace, err
+		return nil, o.dialect, o.trace, err
 	}
 
 	// 尝试发送Ping包
@@ -115,24 +148,175 @@ func NewMySQL(opts ...Option) (*sql.DB, bool, error) {
 		return time.Second
 	})
 	if err != nil {
-		return nil, o.trace, err
+		return nil, o.dialect, o.trace, err
 	}
 
 	db.SetMaxOpenConns(o.maxOpenConns)
 	db.SetMaxIdleConns(o.maxIdleConns)
 	db.SetConnMaxLifetime(o.maxLifetime)
 
-	return db, o.trace, nil
+	return db, o.dialect, o.trace, nil
 }
 
 // NewMySQLWithDB 创建DB
 func NewMySQLWithDB(db *sql.DB, trace bool) *DB {
-	dbMap := &gorp.DbMap{Db: db, Dialect: gorp.MySQLDialect{Encoding: "UTF8", Engine: "InnoDB"}}
+	return NewWithDB(db, MySQL, trace)
+}
+
+// NewPostgresWithDB 创建PostgreSQL的DB
+func NewPostgresWithDB(db *sql.DB, trace bool) *DB {
+	return NewWithDB(db, Postgres, trace)
+}
+
+// TextSize 文本字段长度：字符串字段的size为TextSize时创建为TEXT类型，超过TextSize时创建为LONGTEXT类型(PostgreSQL均为TEXT类型)
+const TextSize = 65535
+
+// MySQL方言(按字段长度创建文本字段)
+type mysqlDialect struct {
+	gorp.MySQLDialect
+}
+
+func (d mysqlDialect) ToSqlType(val reflect.Type, maxsize int, isAutoIncr bool) string {
+	if val.Kind() == reflect.String && maxsize > TextSize {
+		return "longtext"
+	} else if val.Kind() == reflect.String && maxsize == TextSize {
+		return "text"
+	}
+	return d.MySQLDialect.ToSqlType(val, maxsize, isAutoIncr)
+}
+
+// PostgreSQL方言(按字段长度创建文本字段)
+type postgresDialect struct {
+	gorp.PostgresDialect
+}
+
+func (d postgresDialect) ToSqlType(val reflect.Type, maxsize int, isAutoIncr bool) string {
+	if val.Kind() == reflect.String && maxsize >= TextSize {
+		return "text"
+	}
+	return d.PostgresDialect.ToSqlType(val, maxsize, isAutoIncr)
+}
+
+// NewWithDB 根据方言创建DB
+func NewWithDB(db *sql.DB, dialect Dialect, trace bool) *DB {
+	dbMap := &gorp.DbMap{Db: db}
+	switch dialect {
+	case Postgres:
+		dbMap.Dialect = postgresDialect{gorp.PostgresDialect{}}
+	default:
+		dialect = MySQL
+		dbMap.Dialect = mysqlDialect{gorp.MySQLDialect{Encoding: "UTF8", Engine: "InnoDB"}}
+	}
+
 	if trace {
 		dbMap.TraceOn("[db]", new(dbLogger).Init())
 	}
 
-	return &DB{dbMap}
+	return &DB{DbMap: dbMap, dialect: dialect}
+}
+
+// GetDialect 获取数据库方言
+func (m *DB) GetDialect() Dialect {
+	return m.dialect
+}
+
+// Rebind 将查询语句中的?占位符转换为当前方言的占位符
+func (m *DB) Rebind(query string) string {
+	if m.dialect == MySQL {
+		return query
+	}
+
+	var (
+		buf    bytes.Buffer
+		n      int
+		quoted bool
+	)
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			buf.WriteString(m.DbMap.Dialect.BindVar(n))
+			n++
+			continue
+		}
+		buf.WriteByte(c)
+	}
+
+	return buf.String()
+}
+
+// JSONField 获取JSON字段中指定键的文本值(字段为空字符串时为NULL)
+func (m *DB) JSONField(column, key string) string {
+	if m.dialect == Postgres {
+		return fmt.Sprintf("(NULLIF(%s,'')::json->>'%s')", column, key)
+	}
+	return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(NULLIF(%s,''),'$.%s'))", column, key)
+}
+
+// JSONNumberCond 获取JSON字段中指定键与数值比较的条件(按JSON值比较，与字符串类型的值不相等；字段为空字符串时为NULL)
+func (m *DB) JSONNumberCond(column, key, op string, value int64) string {
+	if m.dialect == Postgres {
+		return fmt.Sprintf("(NULLIF(%s,'')::jsonb->'%s') %s '%d'::jsonb", column, key, op, value)
+	}
+	return fmt.Sprintf("JSON_EXTRACT(NULLIF(%s,''),'$.%s') %s %d", column, key, op, value)
+}
+
+// Select 查询数据列表
+func (m *DB) Select(i interface{}, query string, args ...interface{}) ([]interface{}, error) {
+	return m.DbMap.Select(i, m.Rebind(query), args...)
+}
+
+// SelectOne 查询单条数据
+func (m *DB) SelectOne(holder interface{}, query string, args ...interface{}) error {
+	return m.DbMap.SelectOne(holder, m.Rebind(query), args...)
+}
+
+// SelectInt 查询数值
+func (m *DB) SelectInt(query string, args ...interface{}) (int64, error) {
+	return m.DbMap.SelectInt(m.Rebind(query), args...)
+}
+
+// Exec 执行SQL
+func (m *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return m.DbMap.Exec(m.Rebind(query), args...)
+}
+
+// Begin 开启事物
+func (m *DB) Begin() (*Tx, error) {
+	tran, err := m.DbMap.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Transaction: tran, db: m}, nil
+}
+
+// Tx 数据库事物
+type Tx struct {
+	*gorp.Transaction
+	db *DB
+}
+
+// Select 查询数据列表
+func (t *Tx) Select(i interface{}, query string, args ...interface{}) ([]interface{}, error) {
+	return t.Transaction.Select(i, t.db.Rebind(query), args...)
+}
+
+// SelectOne 查询单条数据
+func (t *Tx) SelectOne(holder interface{}, query string, args ...interface{}) error {
+	return t.Transaction.SelectOne(holder, t.db.Rebind(query), args...)
+}
+
+// SelectInt 查询数值
+func (t *Tx) SelectInt(query string, args ...interface{}) (int64, error) {
+	return t.Transaction.SelectInt(t.db.Rebind(query), args...)
+}
+
+// Exec 执行SQL
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Transaction.Exec(t.db.Rebind(query), args...)
 }
 
 // Close 关闭数据库连接
@@ -161,28 +345,63 @@ func (m *DB) InsertSQL(table string, info M) (string, []interface{}) {
 	return q, vals
 }
 
-// InsertM 插入数据
+// UpsertSQL 获取插入或更新SQL(keys为唯一索引的字段，与已有数据冲突时更新updates中的字段)
+func (m *DB) UpsertSQL(table string, keys []string, info M, updates ...string) (string, []interface{}) {
+	cols := make([]string, 0, len(info))
+	for k := range info {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+
+	vals := make([]interface{}, len(cols))
+	for i, k := range cols {
+		vals[i] = info[k]
+	}
+
+	sets := make([]string, len(updates))
+	for i, k := range updates {
+		if m.dialect == Postgres {
+			sets[i] = fmt.Sprintf("%s=EXCLUDED.%s", k, k)
+		} else {
+			sets[i] = fmt.Sprintf("%s=VALUES(%s)", k, k)
+		}
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s)", table, strings.Join(cols, ","), strings.Repeat(",?", len(cols))[1:])
+	if m.dialect == Postgres {
+		q = fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s", q, strings.Join(keys, ","), strings.Join(sets, ","))
+	} else {
+		q = fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s", q, strings.Join(sets, ","))
+	}
+	return q, vals
+}
+
+// InsertM 插入数据(PostgreSQL不支持LastInsertId，通过RETURNING id获取自增ID)
 func (m *DB) InsertM(table string, info M) (int64, error) {
 	q, vals := m.InsertSQL(table, info)
+	if m.dialect == Postgres {
+		return m.SelectInt(q+" RETURNING id", vals...)
+	}
+
 	result, err := m.Exec(q, vals...)
 	if err != nil {
 		return 0, err
 	}
-	lastInsertID, _ := result.LastInsertId()
-
-	return lastInsertID, nil
+	return result.LastInsertId()
 }
 
 // InsertMWithTran 使用事物插入数据
 func (m *DB) InsertMWithTran(tran *gorp.Transaction, table string, info M) (int64, error) {
 	q, vals := m.InsertSQL(table, info)
-	result, err := tran.Exec(q, vals...)
+	if m.dialect == Postgres {
+		return tran.SelectInt(m.Rebind(q+" RETURNING id"), vals...)
+	}
+
+	result, err := tran.Exec(m.Rebind(q), vals...)
 	if err != nil {
 		return 0, err
 	}
-	lastInsertID, _ := result.LastInsertId()
-
-	return lastInsertID, nil
+	return result.LastInsertId()
 }
 
 // UpdateSQL 获取更新SQL
@@ -230,7 +449,7 @@ func (m *DB) UpdateByPK(table string, pk, info M) (int64, error) {
 // UpdateByPKWithTran 使用事物更新表数据
 func (m *DB) UpdateByPKWithTran(tran *gorp.Transaction, table string, pk, info M) (int64, error) {
 	q, vals := m.UpdateSQL(table, pk, info)
-	result, err := tran.Exec(q, vals...)
+	result, err := tran.Exec(m.Rebind(q), vals...)
 	if err != nil {
 		return 0, err
 	}
@@ -280,7 +499,7 @@ func (m *DB) DeleteByPK(table string, pk M) (int64, error) {
 // DeleteByPKWithTran 使用事物删除表数据
 func (m *DB) DeleteByPKWithTran(tran *gorp.Transaction, table string, pk M) (int64, error) {
 	q, vals := m.DeleteSQL(table, pk)
-	result, err := tran.Exec(q, vals...)
+	result, err := tran.Exec(m.Rebind(q), vals...)
 	if err != nil {
 		return 0, err
 	}
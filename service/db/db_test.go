@@ -0,0 +1,174 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 记录执行的语句：查询返回自增ID 7，执行结果不支持LastInsertId(同lib/pq)
+type insertDriver struct{}
+
+var insertQueries []string
+
+func init() {
+	sql.Register("dbinsert", insertDriver{})
+}
+
+func (insertDriver) Open(name string) (driver.Conn, error) { return insertConn{}, nil }
+
+type insertConn struct{}
+
+func (insertConn) Prepare(query string) (driver.Stmt, error) {
+	insertQueries = append(insertQueries, query)
+	return insertStmt{}, nil
+}
+func (insertConn) Close() error              { return nil }
+func (insertConn) Begin() (driver.Tx, error) { return insertConn{}, nil }
+func (insertConn) Commit() error             { return nil }
+func (insertConn) Rollback() error           { return nil }
+
+type insertStmt struct{}
+
+func (insertStmt) Close() error  { return nil }
+func (insertStmt) NumInput() int { return -1 }
+func (insertStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (insertStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &insertRows{}, nil
+}
+
+type insertRows struct{ done bool }
+
+func (*insertRows) Columns() []string { return []string{"id"} }
+func (*insertRows) Close() error      { return nil }
+func (r *insertRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(7)
+	return nil
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{MySQL, "SELECT * FROM t WHERE a=? AND b=?", "SELECT * FROM t WHERE a=? AND b=?"},
+		{Postgres, "SELECT * FROM t WHERE a=? AND b=?", "SELECT * FROM t WHERE a=$1 AND b=$2"},
+		{Postgres, "SELECT * FROM t WHERE a='?' AND b=?", "SELECT * FROM t WHERE a='?' AND b=$1"},
+		{Postgres, "SELECT * FROM t WHERE a='it''s?' AND b IN(?,?)", "SELECT * FROM t WHERE a='it''s?' AND b IN($1,$2)"},
+		{Postgres, "SELECT * FROM t", "SELECT * FROM t"},
+	}
+
+	for _, tt := range tests {
+		if got := NewWithDB(nil, tt.dialect, false).Rebind(tt.query); got != tt.want {
+			t.Errorf("%s Rebind(%q) = %q, want %q", tt.dialect, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestJSONField(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{MySQL, "JSON_UNQUOTE(JSON_EXTRACT(NULLIF(input_data,''),'$.title'))"},
+		{Postgres, "(NULLIF(input_data,'')::json->>'title')"},
+	}
+
+	for _, tt := range tests {
+		if got := NewWithDB(nil, tt.dialect, false).JSONField("input_data", "title"); got != tt.want {
+			t.Errorf("%s JSONField = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestJSONNumberCond(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		// MySQL按JSON值与数值比较(与字符串"2"不相等)
+		{MySQL, "JSON_EXTRACT(NULLIF(input_data,''),'$.status') != 2"},
+		{Postgres, "(NULLIF(input_data,'')::jsonb->'status') != '2'::jsonb"},
+	}
+
+	for _, tt := range tests {
+		if got := NewWithDB(nil, tt.dialect, false).JSONNumberCond("input_data", "status", "!=", 2); got != tt.want {
+			t.Errorf("%s JSONNumberCond = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestUpsertSQL(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{MySQL, "INSERT INTO t(a,b,c) VALUES(?,?,?) ON DUPLICATE KEY UPDATE c=VALUES(c)"},
+		{Postgres, "INSERT INTO t(a,b,c) VALUES(?,?,?) ON CONFLICT (a,b) DO UPDATE SET c=EXCLUDED.c"},
+	}
+
+	for _, tt := range tests {
+		query, args := NewWithDB(nil, tt.dialect, false).UpsertSQL("t", []string{"a", "b"}, M{"c": 3, "a": 1, "b": 2}, "c")
+		if query != tt.want {
+			t.Errorf("%s UpsertSQL = %q, want %q", tt.dialect, query, tt.want)
+		}
+		if len(args) != 3 || args[0] != 1 || args[1] != 2 || args[2] != 3 {
+			t.Errorf("%s UpsertSQL args = %v", tt.dialect, args)
+		}
+	}
+}
+
+func TestInsertM(t *testing.T) {
+	sqlDB, err := sql.Open("dbinsert", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	insertQueries = nil
+	id, err := NewWithDB(sqlDB, Postgres, false).InsertM("t", M{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	} else if id != 7 {
+		t.Errorf("Postgres InsertM id = %d, want 7", id)
+	}
+	if len(insertQueries) != 1 || !strings.HasSuffix(insertQueries[0], "VALUES($1) RETURNING id") {
+		t.Errorf("Postgres InsertM queries = %v", insertQueries)
+	}
+
+	// 驱动不支持LastInsertId时返回错误
+	_, err = NewWithDB(sqlDB, MySQL, false).InsertM("t", M{"a": 1})
+	if err == nil || !strings.Contains(err.Error(), "LastInsertId") {
+		t.Errorf("MySQL InsertM err = %v, want LastInsertId error", err)
+	}
+}
+
+func TestTextColumnType(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		size    int
+		want    string
+	}{
+		{MySQL, TextSize, "text"},
+		{MySQL, 2147483647, "longtext"},
+		{Postgres, TextSize, "text"},
+		{Postgres, 2147483647, "text"},
+		{Postgres, 36, "varchar(36)"},
+	}
+
+	for _, tt := range tests {
+		if got := NewWithDB(nil, tt.dialect, false).Dialect.ToSqlType(reflect.TypeOf(""), tt.size, false); got != tt.want {
+			t.Errorf("%s ToSqlType(string, %d) = %q, want %q", tt.dialect, tt.size, got, tt.want)
+		}
+	}
+}
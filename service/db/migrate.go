@@ -0,0 +1,156 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaVersionTableName 数据库版本表名
+const SchemaVersionTableName = "f_schema_version"
+
+// SchemaVersion 数据库版本
+type SchemaVersion struct {
+	Version     int64  `db:"version,primarykey" structs:"version" json:"version"`           // 版本号
+	Description string `db:"description,size:255" structs:"description" json:"description"` // 迁移说明
+	Applied     int64  `db:"applied" structs:"applied" json:"applied"`                      // 执行时间戳
+}
+
+// Migration 数据库迁移
+type Migration struct {
+	Version     int64                // 版本号(只能递增，已发布的迁移不能再修改)
+	Description string               // 迁移说明
+	Statements  map[Dialect][]string // 按方言区分的升级语句
+	Up          func(m *DB) error    // 升级操作(需要保证可重复执行)
+}
+
+// Migrate 按版本号顺序执行尚未执行的数据库迁移
+func (m *DB) Migrate(migrations ...Migration) error {
+	m.AddTableWithName(SchemaVersion{}, SchemaVersionTableName)
+	err := m.CreateTablesIfNotExists()
+	if err != nil {
+		return errors.Wrapf(err, "创建数据库版本表发生错误")
+	}
+
+	var items []*SchemaVersion
+	_, err = m.Select(&items, fmt.Sprintf("SELECT * FROM %s", SchemaVersionTableName))
+	if err != nil {
+		return errors.Wrapf(err, "查询数据库版本发生错误")
+	}
+
+	applied := make(map[int64]bool)
+	for _, item := range items {
+		applied[item.Version] = true
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for _, item := range migrations {
+		if applied[item.Version] {
+			continue
+		}
+
+		err = m.ExecDialect(item.Statements)
+		if err == nil && item.Up != nil {
+			err = item.Up(m)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "执行数据库迁移(%d:%s)发生错误", item.Version, item.Description)
+		}
+
+		err = m.Insert(&SchemaVersion{
+			Version:     item.Version,
+			Description: item.Description,
+			Applied:     time.Now().Unix(),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "记录数据库版本(%d)发生错误", item.Version)
+		}
+	}
+
+	return nil
+}
+
+// ExecDialect 执行当前方言对应的SQL语句
+func (m *DB) ExecDialect(statements map[Dialect][]string) error {
+	for _, query := range statements[m.dialect] {
+		_, err := m.Exec(query)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 获取索引名称(PostgreSQL的索引名称在模式内唯一，需要增加表名前缀)
+func (m *DB) indexName(table, name string) string {
+	if m.dialect == Postgres {
+		return fmt.Sprintf("%s_%s", table, name)
+	}
+	return name
+}
+
+// IndexExists 检查索引是否存在
+func (m *DB) IndexExists(table, name string) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema=DATABASE() AND table_name=? AND index_name=?"
+	if m.dialect == Postgres {
+		query = "SELECT COUNT(*) FROM pg_indexes WHERE schemaname=current_schema() AND tablename=? AND indexname=?"
+	}
+
+	n, err := m.SelectInt(query, table, m.indexName(table, name))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// CreateIndex 创建索引(索引已存在时忽略)
+func (m *DB) CreateIndex(table, name string, unique bool, columns ...string) error {
+	exists, err := m.IndexExists(table, name)
+	if err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	query := "CREATE INDEX"
+	if unique {
+		query = "CREATE UNIQUE INDEX"
+	}
+	query = fmt.Sprintf("%s %s ON %s (%s)", query, m.indexName(table, name), table, strings.Join(columns, ","))
+
+	_, err = m.Exec(query)
+	return err
+}
+
+// ColumnExists 检查字段是否存在
+func (m *DB) ColumnExists(table, column string) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema=DATABASE() AND table_name=? AND column_name=?"
+	if m.dialect == Postgres {
+		query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema=current_schema() AND table_name=? AND column_name=?"
+	}
+
+	n, err := m.SelectInt(query, table, column)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// AddColumn 增加字段(字段已存在时忽略)
+func (m *DB) AddColumn(table, column, definition string) error {
+	exists, err := m.ColumnExists(table, column)
+	if err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	_, err = m.Exec(fmt.Sprintf("ALTER TABLE %s ADD %s %s", table, column, definition))
+	return err
+}
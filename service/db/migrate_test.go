@@ -0,0 +1,232 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 测试用的数据库驱动：记录执行的语句，并模拟数据库版本表、索引及字段的查询
+type fakeDB struct {
+	mu       sync.Mutex
+	execs    []string
+	versions [][]driver.Value
+	indexes  map[string]bool
+	columns  map[string]bool
+}
+
+var (
+	fakeLock sync.Mutex
+	fakeDBs  = make(map[string]*fakeDB)
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+func openFakeDB(t *testing.T, dialect Dialect) (*DB, *fakeDB) {
+	fake := &fakeDB{
+		indexes: make(map[string]bool),
+		columns: make(map[string]bool),
+	}
+
+	fakeLock.Lock()
+	name := fmt.Sprintf("%s_%d", t.Name(), len(fakeDBs))
+	fakeDBs[name] = fake
+	fakeLock.Unlock()
+
+	db, err := sql.Open("fakedb", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWithDB(db, dialect, false), fake
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeLock.Lock()
+	defer fakeLock.Unlock()
+	return &fakeConn{db: fakeDBs[name]}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	q := strings.ToLower(s.query)
+	fields := strings.Fields(s.query)
+	switch {
+	case strings.HasPrefix(q, "insert") && strings.Contains(q, SchemaVersionTableName):
+		db.versions = append(db.versions, args)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(q, "create index"), strings.HasPrefix(q, "create unique index"):
+		db.indexes[fields[len(fields)-4]] = true
+	case strings.HasPrefix(q, "alter table") && fields[3] == "ADD":
+		db.columns[fields[2]+"."+fields[4]] = true
+	}
+
+	if !strings.HasPrefix(q, "create table if not exists") {
+		db.execs = append(db.execs, s.query)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch {
+	case strings.Contains(s.query, SchemaVersionTableName):
+		return &fakeRows{cols: []string{"version", "description", "applied"}, rows: db.versions}, nil
+	case strings.Contains(s.query, "index"):
+		return countRows(db.indexes[fmt.Sprint(args[1])]), nil
+	case strings.Contains(s.query, "information_schema.columns"):
+		return countRows(db.columns[fmt.Sprint(args[0])+"."+fmt.Sprint(args[1])]), nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", s.query)
+}
+
+func countRows(exists bool) *fakeRows {
+	var n int64
+	if exists {
+		n = 1
+	}
+	return &fakeRows{cols: []string{"count"}, rows: [][]driver.Value{{n}}}
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+func TestMigrate(t *testing.T) {
+	for _, dialect := range []Dialect{MySQL, Postgres} {
+		t.Run(string(dialect), func(t *testing.T) {
+			m, fake := openFakeDB(t, dialect)
+
+			var ups []int64
+			migrations := func() []Migration {
+				return []Migration{
+					{
+						Version:     2,
+						Description: "修改字段",
+						Statements: map[Dialect][]string{
+							MySQL:    {"ALTER TABLE t MODIFY COLUMN a TEXT"},
+							Postgres: {"ALTER TABLE t ALTER COLUMN a TYPE TEXT"},
+						},
+					},
+					{
+						Version:     1,
+						Description: "创建索引",
+						Up: func(m *DB) error {
+							ups = append(ups, 1)
+							err := m.CreateIndex("t", "a", false, "a")
+							if err != nil {
+								return err
+							}
+							return m.AddColumn("t", "b", "INT")
+						},
+					},
+				}
+			}
+
+			for i := 0; i < 2; i++ {
+				if err := m.Migrate(migrations()...); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if len(ups) != 1 {
+				t.Errorf("Up called %d times, want 1", len(ups))
+			}
+			if len(fake.versions) != 2 || fake.versions[0][0] != int64(1) || fake.versions[1][0] != int64(2) {
+				t.Errorf("versions = %v, want [1 2]", fake.versions)
+			}
+
+			index := "a"
+			if dialect == Postgres {
+				index = "t_a"
+			}
+			want := []string{
+				fmt.Sprintf("CREATE INDEX %s ON t (a)", index),
+				"ALTER TABLE t ADD b INT",
+				migrations()[0].Statements[dialect][0],
+			}
+			if strings.Join(fake.execs, ";") != strings.Join(want, ";") {
+				t.Errorf("execs = %v, want %v", fake.execs, want)
+			}
+		})
+	}
+}
+
+func TestMigrateExistingSchema(t *testing.T) {
+	m, fake := openFakeDB(t, MySQL)
+	fake.indexes["a"] = true
+	fake.columns["t.b"] = true
+
+	err := m.Migrate(Migration{
+		Version: 1,
+		Up: func(m *DB) error {
+			err := m.CreateIndex("t", "a", false, "a")
+			if err != nil {
+				return err
+			}
+			return m.AddColumn("t", "b", "INT")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.execs) != 0 {
+		t.Errorf("execs = %v, want none", fake.execs)
+	}
+	if len(fake.versions) != 1 {
+		t.Errorf("versions = %v, want [1]", fake.versions)
+	}
+}
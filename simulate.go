@@ -0,0 +1,361 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/antlinker/flow/schema"
+)
+
+// 模拟运行时允许经过的最大节点数(避免流程中的循环导致无法结束)
+const maxSimulateSteps = 1000
+
+// 模拟节点的状态
+const (
+	SimulateTodo = "todo" // 待处理
+	SimulateDone = "done" // 已完成
+)
+
+// SimulationStep 模拟运行经过的节点
+type SimulationStep struct {
+	NodeID     string   `json:"node_id"`    // 节点编号
+	NodeName   string   `json:"node_name"`  // 节点名称
+	NodeType   string   `json:"node_type"`  // 节点类型
+	Candidates []string `json:"candidates"` // 候选人(人工任务)
+	Status     string   `json:"status"`     // 节点状态(todo:待处理 done:已完成)
+}
+
+// SimulationError 模拟运行中的表达式错误
+type SimulationError struct {
+	NodeID     string `json:"node_id"`    // 节点编号
+	Expression string `json:"expression"` // 表达式
+	Message    string `json:"message"`    // 错误信息
+}
+
+// SimulationResult 模拟运行结果
+type SimulationResult struct {
+	Steps  []*SimulationStep      `json:"steps"`  // 按顺序经过的节点
+	Ended  bool                   `json:"ended"`  // 流程是否结束
+	Vars   map[string]interface{} `json:"vars"`   // 流程实例变量
+	Errors []*SimulationError     `json:"errors"` // 表达式错误(条件表达式出错时视为不满足，指派表达式出错时不产生候选人)
+}
+
+// Todo 获取模拟结束时仍待处理的节点
+func (r *SimulationResult) Todo() []*SimulationStep {
+	var steps []*SimulationStep
+	for _, step := range r.Steps {
+		if step.Status == SimulateTodo {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// SimulateOption 模拟运行配置
+type SimulateOption func(*simulateOptions)
+
+type simulateOptions struct {
+	launcher string
+}
+
+// SimulateLauncherOption 设定模拟运行的发起人(表达式中的launcher变量)
+func SimulateLauncherOption(launcher string) SimulateOption {
+	return func(o *simulateOptions) {
+		o.launcher = launcher
+	}
+}
+
+// Simulate 在内存中模拟运行流程(不写入数据库)
+// result 流程解析结果
+// startInput 发起流程的输入数据
+// stepInputs 人工任务的输入数据(节点编号->输入数据)，没有输入数据的人工任务将停留在待处理状态
+func (e *Engine) Simulate(ctx context.Context, result *ParseResult, startInput []byte, stepInputs map[string][]byte, opts ...SimulateOption) (*SimulationResult, error) {
+	execer, err := e.resultExecer(result)
+	if err != nil {
+		return nil, err
+	}
+	return newSimulator(execer, result, opts...).run(e.exprContext(ctx), startInput, stepInputs)
+}
+
+// SimulateFlow 根据流程编号加载启用的流程版本并模拟运行
+func (e *Engine) SimulateFlow(ctx context.Context, flowCode string, startInput []byte, stepInputs map[string][]byte, opts ...SimulateOption) (*SimulationResult, error) {
+	flow, err := e.flowBll.GetFlowByCode(flowCode)
+	if err != nil {
+		return nil, err
+	} else if flow == nil {
+		return nil, ErrNotFound
+	}
+
+	result, err := e.loadParseResult(flow)
+	if err != nil {
+		return nil, err
+	}
+	return e.Simulate(ctx, result, startInput, stepInputs, opts...)
+}
+
+type simulateTask struct {
+	node *NodeResult
+	step *SimulationStep
+}
+
+// 模拟器，按照NodeRouter的流转规则在内存中运行流程
+type simulator struct {
+	ctx          context.Context
+	execer       Execer
+	result       *ParseResult
+	nodes        map[string]*NodeResult
+	flowInstance *schema.FlowInstance
+	todos        []*simulateTask
+	history      map[string]*historyNode
+	out          *SimulationResult
+}
+
+func newSimulator(execer Execer, result *ParseResult, opts ...SimulateOption) *simulator {
+	var o simulateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := &simulator{
+		execer:  execer,
+		result:  result,
+		nodes:   make(map[string]*NodeResult),
+		history: make(map[string]*historyNode),
+		flowInstance: &schema.FlowInstance{
+			FlowID:     result.FlowID,
+			Status:     1,
+			Launcher:   o.launcher,
+			LaunchTime: time.Now().Unix(),
+		},
+		out: &SimulationResult{
+			Vars: make(map[string]interface{}),
+		},
+	}
+	for _, node := range result.Nodes {
+		s.nodes[node.NodeID] = node
+	}
+	return s
+}
+
+func (s *simulator) run(ctx context.Context, startInput []byte, stepInputs map[string][]byte) (*SimulationResult, error) {
+	s.ctx = ctx
+
+	var start *NodeResult
+	for _, node := range s.result.Nodes {
+		if node.NodeType == StartEvent {
+			start = node
+			break
+		}
+	}
+	if start == nil {
+		return nil, fmt.Errorf("流程(%s)缺少开始事件", s.result.FlowID)
+	}
+
+	err := s.route(start, s.addStep(start, nil), startInput, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// 依次处理有输入数据的待办节点
+	for !s.out.Ended {
+		i := s.nextTodo(stepInputs)
+		if i < 0 {
+			break
+		}
+
+		task := s.todos[i]
+		s.todos = append(s.todos[:i], s.todos[i+1:]...)
+
+		err = s.route(task.node, task.step, stepInputs[task.node.NodeID], nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.out, nil
+}
+
+// 查找有输入数据的待办节点
+func (s *simulator) nextTodo(stepInputs map[string][]byte) int {
+	for i, task := range s.todos {
+		if _, ok := stepInputs[task.node.NodeID]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *simulator) addStep(node *NodeResult, candidates []string) *SimulationStep {
+	step := &SimulationStep{
+		NodeID:     node.NodeID,
+		NodeName:   node.NodeName,
+		NodeType:   node.NodeType.String(),
+		Candidates: candidates,
+		Status:     SimulateTodo,
+	}
+	s.out.Steps = append(s.out.Steps, step)
+	return step
+}
+
+// 节点流转(对应NodeRouter.Next)
+func (s *simulator) route(node *NodeResult, step *SimulationStep, input []byte, parent *NodeResult) error {
+	if len(s.out.Steps) > maxSimulateSteps {
+		return fmt.Errorf("模拟运行超过%d个节点，流程可能存在循环", maxSimulateSteps)
+	}
+
+	// 人工任务停留在待处理状态(开始事件后的人工任务由发起人自动完成)
+	if node.NodeType == UserTask && parent != nil && parent.NodeType != StartEvent {
+		s.todos = append(s.todos, &simulateTask{node: node, step: step})
+		return nil
+	}
+
+	step.Status = SimulateDone
+	s.addHistory(node, input)
+	if parent == nil {
+		var v map[string]interface{}
+		if json.Unmarshal(input, &v) == nil {
+			for key, val := range v {
+				s.out.Vars[key] = val
+			}
+		}
+	}
+
+	if node.NodeType == BusinessRuleTask {
+		s.execBusinessRule(node)
+	}
+
+	// 如果下一节点是并行网关并且还有其它待办节点，则停止流转
+	if node.NodeType == UserTask && parent == nil && len(s.todos) > 0 {
+		for _, r := range node.Routers {
+			target, ok := s.nodes[r.TargetNodeID]
+			if ok && target.NodeType == ParallelGateway && s.allow(node, r, input) {
+				return nil
+			}
+		}
+	}
+
+	switch node.NodeType {
+	case EndEvent:
+		s.out.Ended = len(s.todos) == 0
+		return nil
+	case TerminateEvent:
+		s.out.Ended = true
+		return nil
+	}
+
+	for _, r := range node.Routers {
+		if !s.allow(node, r, input) {
+			continue
+		}
+
+		target, ok := s.nodes[r.TargetNodeID]
+		if !ok {
+			return fmt.Errorf("节点(%s)的目标节点(%s)不存在", node.NodeID, r.TargetNodeID)
+		}
+
+		err := s.route(target, s.addStep(target, s.candidates(node, target, input)), input, node)
+		if err != nil {
+			return err
+		}
+		if s.out.Ended {
+			break
+		}
+	}
+	return nil
+}
+
+// 执行业务规则任务的决策表，并将结果写入模拟运行的流程实例变量
+func (s *simulator) execBusinessRule(node *NodeResult) {
+	code := propertyValue(node.Properties, DecisionRefProperty)
+	out, err := decide(s.ctx, code, s.out.Vars)
+	if err != nil {
+		s.addError(node.NodeID, code, err)
+		return
+	}
+	s.out.Vars[resultVariable(code, propertyValue(node.Properties, ResultVariableProperty))] = out
+}
+
+// 计算连线条件
+func (s *simulator) allow(node *NodeResult, r *RouterResult, input []byte) bool {
+	if r.Expression == "" {
+		return true
+	}
+
+	allow, err := s.execer.ExecReturnBool(s.ctx, []byte(r.Expression), s.expData(node, input))
+	if err != nil {
+		s.addError(node.NodeID, r.Expression, err)
+		return false
+	}
+	return allow
+}
+
+// 计算节点候选人
+func (s *simulator) candidates(node, target *NodeResult, input []byte) []string {
+	var candidates []string
+	for _, exp := range target.CandidateExpressions {
+		ss, err := s.execer.ExecReturnStringSlice(s.ctx, []byte(exp), s.expData(node, input))
+		if err != nil {
+			s.addError(target.NodeID, exp, err)
+			continue
+		}
+		candidates = append(candidates, ss...)
+	}
+	return candidates
+}
+
+func (s *simulator) addError(nodeID, exp string, err error) {
+	for _, e := range s.out.Errors {
+		if e.NodeID == nodeID && e.Expression == exp {
+			return
+		}
+	}
+	s.out.Errors = append(s.out.Errors, &SimulationError{
+		NodeID:     nodeID,
+		Expression: exp,
+		Message:    err.Error(),
+	})
+}
+
+// 记录已完成的节点(与NodeRouter中的history一致，模拟运行时处理人为空)
+func (s *simulator) addHistory(node *NodeResult, input []byte) {
+	var output interface{}
+	json.Unmarshal(input, &output)
+
+	var count int
+	if h, ok := s.history[node.NodeID]; ok {
+		count = h.Count
+	}
+
+	var seq int
+	for _, step := range s.out.Steps {
+		if step.Status == SimulateDone {
+			seq++
+		}
+	}
+
+	s.history[node.NodeID] = &historyNode{
+		NodeName: node.NodeName,
+		Output:   output,
+		Seq:      seq,
+		Count:    count + 1,
+	}
+}
+
+// 获取表达式数据(与NodeRouter.getExpData一致)
+func (s *simulator) expData(node *NodeResult, input []byte) []byte {
+	var v map[string]interface{}
+	json.Unmarshal(input, &v)
+
+	r := map[string]interface{}{
+		"input":    v,
+		"vars":     s.out.Vars,
+		"flow":     s.flowInstance,
+		"node":     &schema.NodeInstance{NodeID: node.NodeID, InputData: string(input), Status: 1},
+		"history":  s.history,
+		"launcher": newLauncher(s.flowInstance),
+	}
+	b, _ := json.Marshal(r)
+	return b
+}
@@ -0,0 +1,215 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试用的表达式执行器：条件表达式为流程实例变量名(以!开头表示取反)，指派表达式直接作为候选人
+type simulateExecer struct{}
+
+func (simulateExecer) ExecReturnBool(ctx context.Context, exp, params []byte) (bool, error) {
+	var data struct {
+		Vars map[string]interface{} `json:"vars"`
+	}
+	if err := json.Unmarshal(params, &data); err != nil {
+		return false, err
+	}
+
+	name := string(exp)
+	not := strings.HasPrefix(name, "!")
+	name = strings.TrimPrefix(name, "!")
+
+	v, ok := data.Vars[name].(bool)
+	if !ok {
+		return false, errors.New("undefined: " + name)
+	}
+	return v != not, nil
+}
+
+func (simulateExecer) ExecReturnStringSlice(ctx context.Context, exp, params []byte) ([]string, error) {
+	return []string{string(exp)}, nil
+}
+
+func (simulateExecer) ExecReturnValue(ctx context.Context, exp, params []byte) (interface{}, error) {
+	return string(exp), nil
+}
+
+func (simulateExecer) ExecReturnInt(ctx context.Context, exp, params []byte) (int, error) {
+	return 0, errors.New("not supported")
+}
+
+func (simulateExecer) ExecReturnTime(ctx context.Context, exp, params []byte) (time.Time, error) {
+	return time.Time{}, errors.New("not supported")
+}
+
+func (simulateExecer) ExecReturnMap(ctx context.Context, exp, params []byte) (map[string]interface{}, error) {
+	return nil, errors.New("not supported")
+}
+
+func (simulateExecer) Check(exp []byte, kind ExpKind) error {
+	return nil
+}
+
+const simulateFlow = `{
+  "id": "simulate",
+  "nodes": [
+    {"id": "start", "type": "startEvent", "routers": [{"target": "apply"}]},
+    {"id": "apply", "type": "userTask", "candidates": ["launcher"], "routers": [{"target": "check"}]},
+    {"id": "check", "type": "exclusiveGateway", "routers": [
+      {"target": "manager", "expression": "leader"},
+      {"target": "fork", "expression": "!leader"}
+    ]},
+    {"id": "manager", "type": "userTask", "candidates": ["boss"], "routers": [{"target": "end"}]},
+    {"id": "fork", "type": "parallelGateway", "routers": [{"target": "hr"}, {"target": "finance"}]},
+    {"id": "hr", "type": "userTask", "candidates": ["hr"], "routers": [{"target": "join"}]},
+    {"id": "finance", "type": "userTask", "candidates": ["finance"], "routers": [{"target": "join"}]},
+    {"id": "join", "type": "parallelGateway", "routers": [{"target": "end"}]},
+    {"id": "end", "type": "endEvent"}
+  ]
+}`
+
+func simulate(t *testing.T, startInput string, stepInputs map[string][]byte) *SimulationResult {
+	result, err := NewJSONParser().Parse(context.Background(), []byte(simulateFlow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := newSimulator(simulateExecer{}, result).run(context.Background(), []byte(startInput), stepInputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func simulatePath(out *SimulationResult) string {
+	var path []string
+	for _, step := range out.Steps {
+		path = append(path, step.NodeID+":"+step.Status)
+	}
+	return strings.Join(path, ",")
+}
+
+func TestSimulate(t *testing.T) {
+	tests := []struct {
+		name       string
+		startInput string
+		stepInputs map[string][]byte
+		path       string
+		ended      bool
+	}{
+		{
+			name:       "waitManager",
+			startInput: `{"leader":true}`,
+			path:       "start:done,apply:done,check:done,manager:todo",
+		},
+		{
+			name:       "manager",
+			startInput: `{"leader":true}`,
+			stepInputs: map[string][]byte{"manager": []byte(`{}`)},
+			path:       "start:done,apply:done,check:done,manager:done,end:done",
+			ended:      true,
+		},
+		{
+			name:       "parallelWait",
+			startInput: `{"leader":false}`,
+			stepInputs: map[string][]byte{"hr": []byte(`{}`)},
+			path:       "start:done,apply:done,check:done,fork:done,hr:done,finance:todo",
+		},
+		{
+			name:       "parallel",
+			startInput: `{"leader":false}`,
+			stepInputs: map[string][]byte{"hr": []byte(`{}`), "finance": []byte(`{}`)},
+			path:       "start:done,apply:done,check:done,fork:done,hr:done,finance:done,join:done,end:done",
+			ended:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := simulate(t, tt.startInput, tt.stepInputs)
+			if path := simulatePath(out); path != tt.path {
+				t.Errorf("path = %s, want %s", path, tt.path)
+			}
+			if out.Ended != tt.ended {
+				t.Errorf("ended = %v, want %v", out.Ended, tt.ended)
+			}
+			if len(out.Errors) > 0 {
+				t.Errorf("errors = %v", out.Errors)
+			}
+		})
+	}
+}
+
+func TestSimulateCandidates(t *testing.T) {
+	out := simulate(t, `{"leader":true}`, nil)
+
+	todo := out.Todo()
+	if len(todo) != 1 || todo[0].NodeID != "manager" {
+		t.Fatalf("Todo() = %v, want manager", todo)
+	}
+	if c := todo[0].Candidates; len(c) != 1 || c[0] != "boss" {
+		t.Errorf("candidates = %v, want [boss]", c)
+	}
+}
+
+func TestSimulateExpressionError(t *testing.T) {
+	out := simulate(t, `{}`, nil)
+
+	if len(out.Errors) != 2 {
+		t.Fatalf("errors = %v, want 2 errors", out.Errors)
+	}
+	for _, e := range out.Errors {
+		if e.NodeID != "check" {
+			t.Errorf("error node = %s, want check", e.NodeID)
+		}
+	}
+	if path := simulatePath(out); path != "start:done,apply:done,check:done" {
+		t.Errorf("path = %s", path)
+	}
+}
+
+// 指派表达式为launcher时返回表达式数据中的发起人
+type launcherExecer struct {
+	simulateExecer
+}
+
+func (launcherExecer) ExecReturnStringSlice(ctx context.Context, exp, params []byte) ([]string, error) {
+	var data struct {
+		Launcher launcherData `json:"launcher"`
+	}
+	if err := json.Unmarshal(params, &data); err != nil {
+		return nil, err
+	}
+
+	if string(exp) == "launcher" {
+		return []string{data.Launcher.ID}, nil
+	}
+	return []string{string(exp)}, nil
+}
+
+func TestSimulateLauncher(t *testing.T) {
+	result, err := NewJSONParser().Parse(context.Background(), []byte(simulateFlow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := newSimulator(launcherExecer{}, result, SimulateLauncherOption("T001")).run(context.Background(), []byte(`{"leader":true}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range out.Steps {
+		if step.NodeID == "apply" {
+			if len(step.Candidates) != 1 || step.Candidates[0] != "T001" {
+				t.Errorf("apply candidates = %v, want [T001]", step.Candidates)
+			}
+			return
+		}
+	}
+	t.Errorf("steps = %s, want apply", simulatePath(out))
+}
@@ -0,0 +1,129 @@
+package flow
+
+import (
+	"github.com/antlinker/flow/schema"
+)
+
+// 表达式执行记录的类型
+const (
+	TraceRouter     = "router"     // 连线条件
+	TraceAssignment = "assignment" // 指派表达式
+)
+
+// StepExplain 节点实例的流转说明
+type StepExplain struct {
+	NodeInstanceID string           `json:"node_instance_id"` // 节点实例内码
+	NodeCode       string           `json:"node_code"`        // 节点编号
+	NodeName       string           `json:"node_name"`        // 节点名称
+	Status         int64            `json:"status"`           // 节点实例状态(1:待处理 2:已完成 3:已取消)
+	Traced         bool             `json:"traced"`           // 是否有表达式执行记录(未启用表达式跟踪时为false)
+	Routers        []*RouterExplain `json:"routers"`          // 节点的连线
+}
+
+// RouterExplain 连线的流转说明
+type RouterExplain struct {
+	RouterID       string                    `json:"router_id"`        // 节点路由内码
+	TargetNodeID   string                    `json:"target_node_id"`   // 目标节点内码
+	TargetNodeCode string                    `json:"target_node_code"` // 目标节点编号
+	TargetNodeName string                    `json:"target_node_name"` // 目标节点名称
+	Expression     string                    `json:"expression"`       // 连线条件
+	Evaluated      bool                      `json:"evaluated"`        // 连线条件是否有执行记录
+	Taken          bool                      `json:"taken"`            // 是否流向了目标节点
+	Condition      *schema.ExpressionTrace   `json:"condition"`        // 连线条件的执行记录
+	Assignments    []*schema.ExpressionTrace `json:"assignments"`      // 目标节点指派表达式的执行记录
+}
+
+// 根据节点的连线及表达式执行记录生成流转说明
+// 同一连线有多次执行记录时(流转失败后重试)，以最后一次连线条件的执行记录为准
+func newStepExplain(nodeInstance *schema.NodeInstance, node *schema.Node, routers []*schema.NodeRouter, traces []*schema.ExpressionTrace) *StepExplain {
+	explain := &StepExplain{
+		NodeInstanceID: nodeInstance.RecordID,
+		NodeCode:       node.Code,
+		NodeName:       node.Name,
+		Status:         nodeInstance.Status,
+		Traced:         len(traces) > 0,
+	}
+
+	items := make(map[string]*RouterExplain)
+	for _, r := range routers {
+		item := &RouterExplain{
+			RouterID:     r.RecordID,
+			TargetNodeID: r.TargetNodeID,
+			Expression:   r.Expression,
+		}
+		items[r.RecordID] = item
+		explain.Routers = append(explain.Routers, item)
+	}
+
+	for _, t := range traces {
+		item, ok := items[t.RouterID]
+		if !ok {
+			continue
+		}
+
+		switch t.Kind {
+		case TraceRouter:
+			item.Evaluated = true
+			item.Condition = t
+			item.Assignments = nil
+		case TraceAssignment:
+			item.Assignments = append(item.Assignments, t)
+		}
+	}
+
+	for _, item := range explain.Routers {
+		if item.Condition != nil {
+			item.Taken = item.Condition.Error == "" && item.Condition.Result == "true"
+		} else {
+			item.Taken = item.Expression == "" && nodeInstance.Status == 2
+		}
+
+		for _, t := range item.Assignments {
+			if t.Error != "" {
+				item.Taken = false
+			}
+		}
+	}
+
+	return explain
+}
+
+// ExplainStep 查询节点实例的流转说明(每条连线的条件、执行结果及是否流向了目标节点)
+// 需要启用表达式跟踪(ExpressionTraceOption)才能查看连线条件及指派表达式的执行记录
+func (e *Engine) ExplainStep(nodeInstanceID string) (*StepExplain, error) {
+	nodeInstance, err := e.flowBll.GetNodeInstance(nodeInstanceID)
+	if err != nil {
+		return nil, err
+	} else if nodeInstance == nil {
+		return nil, ErrNotFound
+	}
+
+	node, err := e.flowBll.GetNode(nodeInstance.NodeID)
+	if err != nil {
+		return nil, err
+	} else if node == nil {
+		return nil, ErrNotFound
+	}
+
+	routers, err := e.flowBll.QueryNodeRouters(node.RecordID)
+	if err != nil {
+		return nil, err
+	}
+
+	traces, err := e.flowBll.QueryExpressionTraces(nodeInstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	explain := newStepExplain(nodeInstance, node, routers, traces)
+	for _, item := range explain.Routers {
+		target, err := e.flowBll.GetNode(item.TargetNodeID)
+		if err != nil {
+			return nil, err
+		} else if target != nil {
+			item.TargetNodeCode = target.Code
+			item.TargetNodeName = target.Name
+		}
+	}
+	return explain, nil
+}
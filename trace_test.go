@@ -0,0 +1,52 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/antlinker/flow/schema"
+)
+
+func TestNewStepExplain(t *testing.T) {
+	nodeInstance := &schema.NodeInstance{RecordID: "ni1", Status: 2}
+	node := &schema.Node{Code: "gateway", Name: "金额判断"}
+	routers := []*schema.NodeRouter{
+		{RecordID: "r1", TargetNodeID: "n1", Expression: "input.amount > 10000"},
+		{RecordID: "r2", TargetNodeID: "n2", Expression: "input.amount <= 10000"},
+		{RecordID: "r3", TargetNodeID: "n3"},
+		{RecordID: "r4", TargetNodeID: "n4", Expression: "input.amount >"},
+	}
+	traces := []*schema.ExpressionTrace{
+		{RouterID: "r1", Kind: TraceRouter, Result: "true"},
+		{RouterID: "r1", Kind: TraceAssignment, Result: `["u1"]`},
+		{RouterID: "r2", Kind: TraceRouter, Result: "false"},
+		{RouterID: "r3", Kind: TraceAssignment, Result: `["u2"]`},
+		{RouterID: "r4", Kind: TraceRouter, Error: "syntax error"},
+	}
+
+	explain := newStepExplain(nodeInstance, node, routers, traces)
+	if !explain.Traced || explain.NodeCode != "gateway" || len(explain.Routers) != 4 {
+		t.Fatalf("explain = %+v", explain)
+	}
+
+	want := []struct {
+		evaluated   bool
+		taken       bool
+		assignments int
+	}{
+		{true, true, 1},
+		{true, false, 0},
+		{false, true, 1},
+		{true, false, 0},
+	}
+	for i, w := range want {
+		r := explain.Routers[i]
+		if r.Evaluated != w.evaluated || r.Taken != w.taken || len(r.Assignments) != w.assignments {
+			t.Errorf("routers[%d] = %+v, want %+v", i, r, w)
+		}
+	}
+
+	explain = newStepExplain(nodeInstance, node, routers, nil)
+	if explain.Traced || explain.Routers[0].Taken || !explain.Routers[2].Taken {
+		t.Errorf("explain without traces = %+v", explain)
+	}
+}
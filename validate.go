@@ -0,0 +1,261 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Severity 诊断级别
+type Severity string
+
+const (
+	// SeverityError 错误(流程不能被创建)
+	SeverityError Severity = "error"
+	// SeverityWarning 警告(流程可以被创建，但可能存在问题)
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic 流程校验的诊断信息
+type Diagnostic struct {
+	FlowID   string   `json:"flow_id"`  // 流程ID
+	NodeID   string   `json:"node_id"`  // 节点ID(流程级别的诊断为空)
+	Severity Severity `json:"severity"` // 诊断级别
+	Message  string   `json:"message"`  // 诊断信息
+}
+
+func (d Diagnostic) String() string {
+	if d.NodeID == "" {
+		return fmt.Sprintf("[%s] %s: %s", d.Severity, d.FlowID, d.Message)
+	}
+	return fmt.Sprintf("[%s] %s/%s: %s", d.Severity, d.FlowID, d.NodeID, d.Message)
+}
+
+// ValidationError 流程校验错误
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			msgs = append(msgs, d.String())
+		}
+	}
+	return fmt.Sprintf("流程校验失败：%s", strings.Join(msgs, "; "))
+}
+
+// HasError 检查诊断信息中是否存在错误级别的诊断
+func HasError(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateXML 解析并校验BPMN文件，解析失败时返回一条错误级别的诊断
+func ValidateXML(data []byte) []Diagnostic {
+	result, err := NewXMLParser().Parse(context.Background(), data)
+	if err != nil {
+		return []Diagnostic{{Severity: SeverityError, Message: err.Error()}}
+	}
+	return Validate(result)
+}
+
+// Validate 校验流程数据
+func Validate(result *ParseResult) []Diagnostic {
+	v := &validator{result: result}
+	v.validate()
+	return v.diagnostics
+}
+
+// CheckExpressions 使用表达式执行器检查流程中所有的连线条件及指派表达式
+func CheckExpressions(execer Execer, result *ParseResult) []Diagnostic {
+	if execer == nil {
+		return nil
+	}
+
+	var diagnostics []Diagnostic
+	add := func(nodeID, exp string, err error) {
+		diagnostics = append(diagnostics, Diagnostic{
+			FlowID:   result.FlowID,
+			NodeID:   nodeID,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("表达式( %s )检查失败：%v", exp, err),
+		})
+	}
+
+	for _, node := range result.Nodes {
+		for _, r := range node.Routers {
+			if r.Expression == "" {
+				continue
+			}
+			if err := execer.Check([]byte(r.Expression), ExpCondition); err != nil {
+				add(node.NodeID, r.Expression, err)
+			}
+		}
+		for _, exp := range node.CandidateExpressions {
+			if err := execer.Check([]byte(exp), ExpCandidate); err != nil {
+				add(node.NodeID, exp, err)
+			}
+		}
+	}
+	return diagnostics
+}
+
+type validator struct {
+	result      *ParseResult
+	diagnostics []Diagnostic
+}
+
+func (v *validator) errorf(nodeID, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		FlowID:   v.result.FlowID,
+		NodeID:   nodeID,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) warnf(nodeID, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		FlowID:   v.result.FlowID,
+		NodeID:   nodeID,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validate() {
+	if v.result.FlowID == "" {
+		v.errorf("", "流程缺少ID")
+	}
+	if len(v.result.Nodes) == 0 {
+		v.errorf("", "流程没有任何节点")
+		return
+	}
+
+	nodes := make(map[string]*NodeResult)
+	var starts []*NodeResult
+	hasEnd := false
+
+	for _, node := range v.result.Nodes {
+		if node.NodeID == "" {
+			v.errorf("", "节点(%s)缺少ID", node.NodeType)
+			continue
+		} else if _, ok := nodes[node.NodeID]; ok {
+			v.errorf(node.NodeID, "节点ID重复")
+			continue
+		}
+		nodes[node.NodeID] = node
+
+		switch node.NodeType {
+		case StartEvent:
+			starts = append(starts, node)
+		case EndEvent, TerminateEvent:
+			hasEnd = true
+		}
+	}
+
+	if len(starts) == 0 {
+		v.errorf("", "流程缺少开始事件")
+	}
+	if !hasEnd {
+		v.errorf("", "流程缺少结束事件")
+	}
+
+	for _, node := range v.result.Nodes {
+		if node.NodeID == "" {
+			continue
+		}
+		v.validateNode(node, nodes)
+	}
+
+	v.validateReachable(starts, nodes)
+}
+
+func (v *validator) validateNode(node *NodeResult, nodes map[string]*NodeResult) {
+	for _, r := range node.Routers {
+		if r.TargetNodeID == "" {
+			v.errorf(node.NodeID, "连线缺少目标节点")
+		} else if _, ok := nodes[r.TargetNodeID]; !ok {
+			v.errorf(node.NodeID, "连线的目标节点(%s)不存在", r.TargetNodeID)
+		}
+	}
+
+	switch node.NodeType {
+	case EndEvent, TerminateEvent:
+		if len(node.Routers) > 0 {
+			v.warnf(node.NodeID, "结束事件的流出连线将被忽略")
+		}
+		return
+	}
+
+	if len(node.Routers) == 0 {
+		v.errorf(node.NodeID, "节点没有流出连线，流程将无法结束")
+		return
+	}
+
+	switch node.NodeType {
+	case UserTask:
+		if len(node.CandidateExpressions) == 0 {
+			v.warnf(node.NodeID, "人工任务未设定候选人")
+		}
+	case BusinessRuleTask:
+		if propertyValue(node.Properties, DecisionRefProperty) == "" {
+			v.errorf(node.NodeID, "业务规则任务未设定决策表(%s)", DecisionRefProperty)
+		}
+	case ExclusiveGateway:
+		if len(node.Routers) < 2 {
+			return
+		}
+
+		var n int
+		for _, r := range node.Routers {
+			if strings.TrimSpace(r.Expression) == "" {
+				n++
+			}
+		}
+		if n > 1 {
+			v.errorf(node.NodeID, "排他网关存在%d条未设定条件的连线", n)
+		} else if n == 1 {
+			v.warnf(node.NodeID, "排他网关中未设定条件的连线始终会被执行")
+		}
+	}
+}
+
+// 检查是否存在从开始事件无法到达的节点
+func (v *validator) validateReachable(starts []*NodeResult, nodes map[string]*NodeResult) {
+	if len(starts) == 0 {
+		return
+	}
+
+	visited := make(map[string]bool)
+	queue := append([]*NodeResult{}, starts...)
+	for _, node := range starts {
+		visited[node.NodeID] = true
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for _, r := range node.Routers {
+			target, ok := nodes[r.TargetNodeID]
+			if !ok || visited[target.NodeID] {
+				continue
+			}
+			visited[target.NodeID] = true
+			queue = append(queue, target)
+		}
+	}
+
+	for _, node := range v.result.Nodes {
+		if node.NodeID != "" && !visited[node.NodeID] {
+			v.errorf(node.NodeID, "节点无法从开始事件到达")
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const validateTpl = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:camunda="http://camunda.org/schema/1.0/bpmn">
+  <bpmn:process id="Process_1" name="校验" isExecutable="true">
+%s
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestValidateTestData(t *testing.T) {
+	files, err := filepath.Glob("test_data/*.bpmn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, d := range ValidateXML(data) {
+			if d.Severity == SeverityError {
+				t.Errorf("%s: %s", name, d.String())
+			}
+		}
+	}
+}
+
+func TestValidateXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		nodeID  string
+		message string
+	}{
+		{"malformed", `<bpmn:startEvent id="s"`, "", "无效的BPMN文件"},
+		{"missingTarget", `<bpmn:startEvent id="s" /><bpmn:sequenceFlow sourceRef="s" />`, "", "缺少sourceRef或targetRef"},
+		{"danglingTarget", `<bpmn:startEvent id="s" /><bpmn:endEvent id="e" />
+			<bpmn:sequenceFlow id="f1" sourceRef="s" targetRef="x" />`, "s", "目标节点(x)不存在"},
+		{"noStart", `<bpmn:userTask id="u" camunda:candidateUsers="a" /><bpmn:endEvent id="e" />
+			<bpmn:sequenceFlow id="f1" sourceRef="u" targetRef="e" />`, "", "缺少开始事件"},
+		{"unreachable", `<bpmn:startEvent id="s" /><bpmn:endEvent id="e" /><bpmn:endEvent id="e2" />
+			<bpmn:sequenceFlow id="f1" sourceRef="s" targetRef="e" />`, "e2", "无法从开始事件到达"},
+		{"gateway", `<bpmn:startEvent id="s" /><bpmn:exclusiveGateway id="g" /><bpmn:endEvent id="e" />
+			<bpmn:sequenceFlow id="f1" sourceRef="s" targetRef="g" />
+			<bpmn:sequenceFlow id="f2" sourceRef="g" targetRef="e" />
+			<bpmn:sequenceFlow id="f3" sourceRef="g" targetRef="e" />`, "g", "未设定条件的连线"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := ValidateXML([]byte(strings.Replace(validateTpl, "%s", tt.body, 1)))
+			for _, d := range diagnostics {
+				if d.Severity == SeverityError && d.NodeID == tt.nodeID && strings.Contains(d.Message, tt.message) {
+					return
+				}
+			}
+			t.Errorf("ValidateXML() = %v, want error on %q containing %q", diagnostics, tt.nodeID, tt.message)
+		})
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	body := `<bpmn:startEvent id="s" /><bpmn:userTask id="u" camunda:candidateUsers="a" /><bpmn:endEvent id="e" />
+		<bpmn:sequenceFlow id="f1" sourceRef="s" targetRef="u" />
+		<bpmn:sequenceFlow id="f2" sourceRef="u" targetRef="e" />`
+
+	diagnostics := ValidateXML([]byte(strings.Replace(validateTpl, "%s", body, 1)))
+	if len(diagnostics) != 0 {
+		t.Errorf("ValidateXML() = %v, want no diagnostics", diagnostics)
+	}
+}
+
+// 测试用的表达式执行器：包含undefined的表达式检查失败
+type checkExecer struct {
+	simulateExecer
+}
+
+func (checkExecer) Check(exp []byte, kind ExpKind) error {
+	if strings.Contains(string(exp), "undefined") {
+		return errors.New("变量undefined未定义")
+	}
+	return nil
+}
+
+func TestCheckExpressions(t *testing.T) {
+	result := &ParseResult{
+		FlowID: "check",
+		Nodes: []*NodeResult{
+			{NodeID: "gateway", NodeType: ExclusiveGateway, Routers: []*RouterResult{
+				{TargetNodeID: "a", Expression: "input.day > 3"},
+				{TargetNodeID: "b", Expression: "undefined.day <= 3"},
+			}},
+			{NodeID: "a", NodeType: UserTask, CandidateExpressions: []string{"undefined"}},
+			{NodeID: "b", NodeType: UserTask, CandidateExpressions: []string{"flow.launcher"}},
+		},
+	}
+
+	diagnostics := CheckExpressions(checkExecer{}, result)
+	if len(diagnostics) != 2 {
+		t.Fatalf("diagnostics = %v, want 2", diagnostics)
+	}
+	if d := diagnostics[0]; d.FlowID != "check" || d.NodeID != "gateway" || d.Severity != SeverityError {
+		t.Errorf("diagnostics[0] = %v", d)
+	}
+	if d := diagnostics[1]; d.NodeID != "a" {
+		t.Errorf("diagnostics[1] = %v", d)
+	}
+}
+
+// 检查测试数据中的所有表达式(sqlctx模块由flow.Init注册)
+func TestCheckExpressionsTestData(t *testing.T) {
+	// 仅用于解析测试的占位表达式
+	placeholders := map[string]bool{
+		"这是一段测试用的expression": true,
+		"负责人a;负责人b":          true,
+	}
+
+	files, err := filepath.Glob("test_data/*.bpmn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execer := NewQLangExecer()
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := NewXMLParser().(MultiParser).ParseAll(context.Background(), data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		for _, result := range results {
+			reported := make(map[string]bool)
+			for _, d := range CheckExpressions(execer, result) {
+				var placeholder bool
+				for exp := range placeholders {
+					if strings.HasPrefix(d.Message, "表达式( "+exp+" )") {
+						placeholder = true
+						reported[exp] = true
+					}
+				}
+				if !placeholder {
+					t.Errorf("%s: %s", name, d.String())
+				}
+			}
+
+			for _, node := range result.Nodes {
+				exps := node.CandidateExpressions
+				for _, r := range node.Routers {
+					exps = append(exps, r.Expression)
+				}
+				for _, exp := range exps {
+					if placeholders[exp] && !reported[exp] {
+						t.Errorf("%s: 占位表达式(%s)应检查失败", name, exp)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package flow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchOptions 流程定义目录监听选项
+type WatchOptions struct {
+	Debounce time.Duration // 合并文件变更事件的间隔(默认500毫秒)
+	Author   string        // 部署人(记录到部署记录中)
+}
+
+// DirWatcher 流程定义目录监听
+type DirWatcher struct {
+	engine  *Engine
+	dir     string
+	opts    WatchOptions
+	watcher *fsnotify.Watcher
+	lock    sync.Mutex
+	timers  map[string]*time.Timer
+	deploy  func(name string) error
+}
+
+// WatchDir 加载目录中所有的流程定义文件(*.bpmn、*.json)及决策表文件(*.dmn)，并在文件变更时重新部署
+// 流程定义中设定了版本号时，仅部署不存在的版本；未设定版本号时，文件内容与最近的部署不同则自动递增版本号部署
+// 文件的解析、校验及部署错误通过Logger输出，不影响引擎运行
+func (e *Engine) WatchDir(dir string, opts WatchOptions) (*DirWatcher, error) {
+	if opts.Debounce <= 0 {
+		opts.Debounce = 500 * time.Millisecond
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	err = watcher.Add(dir)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	w := &DirWatcher{
+		engine:  e,
+		dir:     dir,
+		opts:    opts,
+		watcher: watcher,
+		timers:  make(map[string]*time.Timer),
+	}
+	w.deploy = w.deployFile
+
+	var names []string
+	for _, pattern := range []string{"*.bpmn", "*.json", "*.dmn"} {
+		items, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+		names = append(names, items...)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		w.reload(name)
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Close 停止监听
+func (w *DirWatcher) Close() error {
+	w.lock.Lock()
+	for name, timer := range w.timers {
+		timer.Stop()
+		delete(w.timers, name)
+	}
+	w.lock.Unlock()
+
+	return w.watcher.Close()
+}
+
+func (w *DirWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !isFlowFile(event.Name) {
+				continue
+			}
+			// 编辑器通过重命名临时文件保存时，目标文件产生Create或Rename事件
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) != 0 {
+				w.schedule(event.Name)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.engine.errorf("监听流程目录(%s)发生错误：%v", w.dir, err)
+		}
+	}
+}
+
+// 合并短时间内的多次变更(编辑器保存文件时通常会产生多个事件)
+func (w *DirWatcher) schedule(name string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if timer, ok := w.timers[name]; ok {
+		timer.Reset(w.opts.Debounce)
+		return
+	}
+
+	w.timers[name] = time.AfterFunc(w.opts.Debounce, func() {
+		w.lock.Lock()
+		delete(w.timers, name)
+		w.lock.Unlock()
+
+		w.reload(name)
+	})
+}
+
+func (w *DirWatcher) reload(name string) {
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		// 文件已被重命名或删除，等待新文件的Create事件
+		return
+	}
+
+	// 与引擎的其他部署过程互斥，避免并发部署计算出相同的版本号
+	w.engine.deployLock.Lock()
+	defer w.engine.deployLock.Unlock()
+
+	err := w.deploy(name)
+	if err != nil {
+		w.engine.errorf("加载流程文件(%s)发生错误：%v", name, err)
+	}
+}
+
+func (w *DirWatcher) deployFile(name string) error {
+	e := w.engine
+
+	data, err := e.parseFile(name)
+	if err != nil {
+		return err
+	}
+
+	if isDecisionFile(name) {
+		_, err = e.deployDecisions(data, DeployOptions{
+			Comment: fmt.Sprintf("从文件%s部署", filepath.Base(name)),
+			Author:  w.opts.Author,
+		})
+		return err
+	}
+
+	results, format, err := e.parseFlows(data, FormatAuto)
+	if err != nil {
+		return err
+	}
+
+	var xml string
+	if format == FormatBPMN {
+		xml = string(data)
+	}
+	sum := checksum(data)
+
+	for _, result := range results {
+		latest, err := e.flowBll.GetLatestDeployment(result.FlowID)
+		if err != nil {
+			return err
+		}
+
+		if result.FlowVersion > 0 {
+			exists, err := e.flowBll.GetFlowByCodeAndVersion(result.FlowID, result.FlowVersion)
+			if err != nil {
+				return err
+			} else if exists != nil {
+				if latest != nil && latest.Version == result.FlowVersion && latest.Checksum != sum {
+					e.errorf("流程(%s)的版本(%d)已存在，忽略文件(%s)的变更", result.FlowID, result.FlowVersion, name)
+				}
+				continue
+			}
+		} else if latest != nil && latest.Checksum == sum {
+			continue
+		}
+
+		_, err = e.deployFlow(result, xml, sum, DeployOptions{
+			Activate: true,
+			Comment:  fmt.Sprintf("从文件%s部署", filepath.Base(name)),
+			Author:   w.opts.Author,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isFlowFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".bpmn" || ext == ".json" || ext == ".dmn"
+}
+
+func isDecisionFile(name string) bool {
+	return strings.ToLower(filepath.Ext(name)) == ".dmn"
+}
@@ -0,0 +1,123 @@
+package flow
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 记录部署的文件及最大并发部署数
+type watchRecorder struct {
+	lock    sync.Mutex
+	names   []string
+	running int
+	max     int
+}
+
+func (r *watchRecorder) deploy(name string) error {
+	r.lock.Lock()
+	r.names = append(r.names, filepath.Base(name))
+	r.running++
+	if r.running > r.max {
+		r.max = r.running
+	}
+	r.lock.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	r.lock.Lock()
+	r.running--
+	r.lock.Unlock()
+	return nil
+}
+
+func (r *watchRecorder) wait(t *testing.T, n int) []string {
+	for i := 0; i < 100; i++ {
+		r.lock.Lock()
+		names := append([]string(nil), r.names...)
+		r.lock.Unlock()
+		if len(names) >= n {
+			return names
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("deployed files timeout, want %d", n)
+	return nil
+}
+
+func newTestWatcher(t *testing.T, dir string, r *watchRecorder) *DirWatcher {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = watcher.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &DirWatcher{
+		engine:  &Engine{},
+		dir:     dir,
+		opts:    WatchOptions{Debounce: 20 * time.Millisecond},
+		watcher: watcher,
+		timers:  make(map[string]*time.Timer),
+		deploy:  r.deploy,
+	}
+	go w.run()
+	return w
+}
+
+func TestWatchDirRename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &watchRecorder{}
+	w := newTestWatcher(t, dir, r)
+	defer w.Close()
+
+	// 先写入临时文件，再重命名为流程定义文件(原子保存)
+	tmp := filepath.Join(dir, "leave.bpmn.tmp")
+	if err = ioutil.WriteFile(tmp, []byte("<definitions/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Rename(tmp, filepath.Join(dir, "leave.bpmn")); err != nil {
+		t.Fatal(err)
+	}
+
+	names := r.wait(t, 1)
+	if names[0] != "leave.bpmn" {
+		t.Errorf("deployed files = %v", names)
+	}
+}
+
+func TestWatchDirSerialized(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &watchRecorder{}
+	w := newTestWatcher(t, dir, r)
+	defer w.Close()
+
+	for _, name := range []string{"a.json", "b.json", "c.dmn"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r.wait(t, 3)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.max != 1 {
+		t.Errorf("concurrent deployments = %d, want 1", r.max)
+	}
+}